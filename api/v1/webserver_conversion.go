@@ -0,0 +1,493 @@
+package v1
+
+import (
+	"encoding/json"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+const (
+	// The deprecated webhook secrets of v1alpha1 have no v1 field, they are kept in annotations
+	// so a WebServer read and updated through v1 doesn't lose them.
+	genericWebhookSecretAnnotation = "web.servers.org/v1alpha1-generic-webhook-secret"
+	githubWebhookSecretAnnotation  = "web.servers.org/v1alpha1-github-webhook-secret"
+	// The imagePullSecret and the pod build of a v1 imageStream have no v1alpha1 field, they are kept in
+	// annotations of the v1alpha1 WebServer so a WebServer converted back to v1 doesn't lose them.
+	imagePullSecretAnnotation = "web.servers.org/v1-image-pull-secret"
+	podBuildAnnotation        = "web.servers.org/v1-pod-build"
+)
+
+// ConvertTo converts this WebServer to the Hub version (v1alpha1).
+func (src *WebServer) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.WebServer)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	genericWebhookSecret := dst.Annotations[genericWebhookSecretAnnotation]
+	githubWebhookSecret := dst.Annotations[githubWebhookSecretAnnotation]
+	delete(dst.Annotations, genericWebhookSecretAnnotation)
+	delete(dst.Annotations, githubWebhookSecretAnnotation)
+	delete(dst.Annotations, imagePullSecretAnnotation)
+	delete(dst.Annotations, podBuildAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	dst.Spec = v1alpha1.WebServerSpec{
+		ApplicationName:      spec.ApplicationName,
+		Replicas:             spec.Replicas,
+		UseSessionClustering: spec.UseSessionClustering,
+		UseInsightsClient:    spec.UseInsightsClient,
+		TLSConfig: v1alpha1.TLSConfig{
+			TLSSecret:               spec.TLS.Secret,
+			TLSPassword:             spec.TLS.Password,
+			CertificateVerification: spec.TLS.CertificateVerification,
+			RouteHostname:           routeHostname(spec),
+			CertManager:             certManagerTo(spec.TLS.CertManager),
+			CertificateReload:       spec.TLS.CertificateReload,
+			Jolokia:                 (*v1alpha1.JolokiaSpec)(spec.TLS.Jolokia),
+		},
+		EnvironmentVariables: spec.EnvironmentVariables,
+		PersistentLogsConfig: v1alpha1.PersistentLogs(spec.PersistentLogsConfig),
+		PodResources:         spec.PodResources,
+		SecurityContext:      spec.SecurityContext,
+		Volume:               (*v1alpha1.VolumeSpec)(spec.Volume),
+		IsNotJWS:             spec.Distribution == DistributionTomcat,
+		UpdateStrategy:       (*v1alpha1.UpdateStrategy)(spec.UpdateStrategy),
+		Rollout:              (*v1alpha1.RolloutSpec)(spec.Rollout),
+		Exposure:             kubernetesExposureTo(spec.Exposure.Kubernetes),
+		Route:                routeTo(&spec.Exposure.Route),
+		TomcatConfig:         tomcatConfigTo(spec.TomcatConfig),
+		Connectors:           connectorsTo(spec.Connectors),
+		SessionClustering:    (*v1alpha1.SessionClusteringSpec)(spec.SessionClustering),
+		SessionStore:         (*v1alpha1.SessionStoreSpec)(spec.SessionStore),
+	}
+
+	var health *v1alpha1.WebServerHealthCheckSpec
+	if spec.HealthCheck != nil {
+		health = &v1alpha1.WebServerHealthCheckSpec{
+			ServerReadinessScript: spec.HealthCheck.ReadinessScript,
+			ServerLivenessScript:  spec.HealthCheck.LivenessScript,
+		}
+	}
+
+	build := spec.Source.Build
+	if spec.Source.ImageStream != nil {
+		webImageStream := &v1alpha1.WebImageStreamSpec{
+			ImageStreamName:      spec.Source.ImageStream.Name,
+			ImageStreamNamespace: spec.Source.ImageStream.Namespace,
+			WebServerHealthCheck: health,
+		}
+		if build != nil {
+			webImageStream.WebSources = &v1alpha1.WebSourcesSpec{
+				SourceRepositoryURL: build.Repository.URL,
+				SourceRepositoryRef: build.Repository.Ref,
+				ContextDir:          build.Repository.ContextDir,
+			}
+			params := &v1alpha1.WebSourcesParamsSpec{
+				GenericWebhookSecret: genericWebhookSecret,
+				GithubWebhookSecret:  githubWebhookSecret,
+			}
+			if build.S2I != nil {
+				webImageStream.WebSources.SourceRepositorySecret = build.S2I.RepositorySecret
+				webImageStream.WebSources.WebhookSecrets = (*v1alpha1.WebhookSecrets)(build.S2I.WebhookSecrets)
+				params.MavenMirrorURL = build.S2I.MavenMirrorURL
+				params.ArtifactDir = build.S2I.ArtifactDir
+			}
+			if *params != (v1alpha1.WebSourcesParamsSpec{}) {
+				webImageStream.WebSources.WebSourcesParams = params
+			}
+			if build.Pod != nil {
+				podBuild, err := json.Marshal(build.Pod)
+				if err != nil {
+					return err
+				}
+				metav1.SetMetaDataAnnotation(&dst.ObjectMeta, podBuildAnnotation, string(podBuild))
+			}
+		}
+		if spec.Source.ImagePullSecret != "" {
+			metav1.SetMetaDataAnnotation(&dst.ObjectMeta, imagePullSecretAnnotation, spec.Source.ImagePullSecret)
+		}
+		dst.Spec.WebImageStream = webImageStream
+	} else {
+		webImage := &v1alpha1.WebImageSpec{
+			ApplicationImage:     spec.Source.Image,
+			ImagePullSecret:      spec.Source.ImagePullSecret,
+			WebServerHealthCheck: health,
+		}
+		if build != nil && build.Pod != nil {
+			webImage.WebApp = &v1alpha1.WebAppSpec{
+				Name:                       build.Pod.WarName,
+				SourceRepositoryURL:        build.Repository.URL,
+				SourceRepositoryRef:        build.Repository.Ref,
+				SourceRepositoryContextDir: build.Repository.ContextDir,
+				WebAppWarImage:             build.Pod.OutputImage,
+				WebAppWarImagePushSecret:   build.Pod.PushSecret,
+				Builder: &v1alpha1.BuilderSpec{
+					Image:                  build.Pod.BuilderImage,
+					ApplicationBuildScript: build.Pod.BuildScript,
+					BackoffLimit:           build.Pod.BackoffLimit,
+					Strategy:               build.Pod.Strategy,
+					ActiveDeadlineSeconds:  build.Pod.ActiveDeadlineSeconds,
+					Shipwright:             (*v1alpha1.ShipwrightSpec)(build.Pod.Shipwright),
+				},
+			}
+		}
+		dst.Spec.WebImage = webImage
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = v1alpha1.WebServerStatus{
+		Replicas:           status.Replicas,
+		Hosts:              status.Hosts,
+		ScalingdownPods:    status.ScalingdownPods,
+		Selector:           status.Selector,
+		ObservedGeneration: status.ObservedGeneration,
+		Conditions:         status.Conditions,
+		Rollout:            (*v1alpha1.RolloutStatus)(status.Rollout),
+		LastReadyRevision:  (*v1alpha1.Revision)(status.LastReadyRevision),
+		UpdatingRevision:   (*v1alpha1.Revision)(status.UpdatingRevision),
+		RolledBackRevision: (*v1alpha1.Revision)(status.RolledBackRevision),
+		MembershipProvider: status.MembershipProvider,
+	}
+	for _, pod := range status.Pods {
+		dst.Status.Pods = append(dst.Status.Pods, v1alpha1.PodStatus(pod))
+	}
+	for _, build := range status.Builds {
+		dst.Status.Builds = append(dst.Status.Builds, v1alpha1.BuildRecord(build))
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *WebServer) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.WebServer)
+	spec := src.Spec.DeepCopy()
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	imagePullSecret := dst.Annotations[imagePullSecretAnnotation]
+	podBuild := dst.Annotations[podBuildAnnotation]
+	delete(dst.Annotations, imagePullSecretAnnotation)
+	delete(dst.Annotations, podBuildAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	distribution := DistributionJWS
+	if spec.IsNotJWS {
+		distribution = DistributionTomcat
+	}
+	exposure, tlsEnabled := exposureFromRouteHostname(spec.TLSConfig.RouteHostname)
+	exposure.Kubernetes = kubernetesExposureFrom(spec.Exposure)
+	routeFrom(spec.Route, &exposure.Route)
+	dst.Spec = WebServerSpec{
+		ApplicationName:      spec.ApplicationName,
+		Replicas:             spec.Replicas,
+		Distribution:         distribution,
+		UseSessionClustering: spec.UseSessionClustering,
+		UseInsightsClient:    spec.UseInsightsClient,
+		Exposure:             exposure,
+		TLS: TLSSpec{
+			Enabled:                 tlsEnabled,
+			Secret:                  spec.TLSConfig.TLSSecret,
+			Password:                spec.TLSConfig.TLSPassword,
+			CertificateVerification: spec.TLSConfig.CertificateVerification,
+			CertManager:             certManagerFrom(spec.TLSConfig.CertManager),
+			CertificateReload:       spec.TLSConfig.CertificateReload,
+			Jolokia:                 (*JolokiaSpec)(spec.TLSConfig.Jolokia),
+		},
+		EnvironmentVariables: spec.EnvironmentVariables,
+		PersistentLogsConfig: PersistentLogs(spec.PersistentLogsConfig),
+		PodResources:         spec.PodResources,
+		SecurityContext:      spec.SecurityContext,
+		Volume:               (*VolumeSpec)(spec.Volume),
+		UpdateStrategy:       (*UpdateStrategy)(spec.UpdateStrategy),
+		Rollout:              (*RolloutSpec)(spec.Rollout),
+		TomcatConfig:         tomcatConfigFrom(spec.TomcatConfig),
+		Connectors:           connectorsFrom(spec.Connectors),
+		SessionClustering:    (*SessionClusteringSpec)(spec.SessionClustering),
+		SessionStore:         (*SessionStoreSpec)(spec.SessionStore),
+	}
+
+	var health *v1alpha1.WebServerHealthCheckSpec
+	if webImage := spec.WebImage; webImage != nil {
+		dst.Spec.Source.Image = webImage.ApplicationImage
+		dst.Spec.Source.ImagePullSecret = webImage.ImagePullSecret
+		health = webImage.WebServerHealthCheck
+		if webApp := webImage.WebApp; webApp != nil {
+			pod := &PodBuildSpec{
+				WarName:     webApp.Name,
+				OutputImage: webApp.WebAppWarImage,
+				PushSecret:  webApp.WebAppWarImagePushSecret,
+			}
+			if webApp.Builder != nil {
+				pod.BuilderImage = webApp.Builder.Image
+				pod.BuildScript = webApp.Builder.ApplicationBuildScript
+				pod.BackoffLimit = webApp.Builder.BackoffLimit
+				pod.Strategy = webApp.Builder.Strategy
+				pod.ActiveDeadlineSeconds = webApp.Builder.ActiveDeadlineSeconds
+				pod.Shipwright = (*ShipwrightSpec)(webApp.Builder.Shipwright)
+			}
+			dst.Spec.Source.Build = &BuildSpec{
+				Repository: RepositorySpec{
+					URL:        webApp.SourceRepositoryURL,
+					Ref:        webApp.SourceRepositoryRef,
+					ContextDir: webApp.SourceRepositoryContextDir,
+				},
+				Pod: pod,
+			}
+		}
+	}
+	if webImageStream := spec.WebImageStream; webImageStream != nil {
+		dst.Spec.Source.ImageStream = &ImageStreamSource{
+			Name:      webImageStream.ImageStreamName,
+			Namespace: webImageStream.ImageStreamNamespace,
+		}
+		dst.Spec.Source.ImagePullSecret = imagePullSecret
+		if health == nil {
+			health = webImageStream.WebServerHealthCheck
+		}
+		if webSources := webImageStream.WebSources; webSources != nil {
+			s2i := &S2IBuildSpec{
+				RepositorySecret: webSources.SourceRepositorySecret,
+				WebhookSecrets:   (*WebhookSecrets)(webSources.WebhookSecrets),
+			}
+			if params := webSources.WebSourcesParams; params != nil {
+				s2i.MavenMirrorURL = params.MavenMirrorURL
+				s2i.ArtifactDir = params.ArtifactDir
+				if params.GenericWebhookSecret != "" {
+					metav1.SetMetaDataAnnotation(&dst.ObjectMeta, genericWebhookSecretAnnotation, params.GenericWebhookSecret)
+				}
+				if params.GithubWebhookSecret != "" {
+					metav1.SetMetaDataAnnotation(&dst.ObjectMeta, githubWebhookSecretAnnotation, params.GithubWebhookSecret)
+				}
+			}
+			dst.Spec.Source.Build = &BuildSpec{
+				Repository: RepositorySpec{
+					URL:        webSources.SourceRepositoryURL,
+					Ref:        webSources.SourceRepositoryRef,
+					ContextDir: webSources.ContextDir,
+				},
+				S2I: s2i,
+			}
+			if podBuild != "" {
+				pod := &PodBuildSpec{}
+				if err := json.Unmarshal([]byte(podBuild), pod); err != nil {
+					return err
+				}
+				dst.Spec.Source.Build.Pod = pod
+				if *s2i == (S2IBuildSpec{}) {
+					dst.Spec.Source.Build.S2I = nil
+				}
+			}
+		}
+	}
+	if health != nil {
+		dst.Spec.HealthCheck = &HealthCheckSpec{
+			ReadinessScript: health.ServerReadinessScript,
+			LivenessScript:  health.ServerLivenessScript,
+		}
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = WebServerStatus{
+		Replicas:           status.Replicas,
+		Hosts:              status.Hosts,
+		ScalingdownPods:    status.ScalingdownPods,
+		Selector:           status.Selector,
+		ObservedGeneration: status.ObservedGeneration,
+		Conditions:         status.Conditions,
+		Rollout:            (*RolloutStatus)(status.Rollout),
+		LastReadyRevision:  (*Revision)(status.LastReadyRevision),
+		UpdatingRevision:   (*Revision)(status.UpdatingRevision),
+		RolledBackRevision: (*Revision)(status.RolledBackRevision),
+		MembershipProvider: status.MembershipProvider,
+	}
+	for _, pod := range status.Pods {
+		dst.Status.Pods = append(dst.Status.Pods, PodStatus(pod))
+	}
+	for _, build := range status.Builds {
+		dst.Status.Builds = append(dst.Status.Builds, BuildRecord(build))
+	}
+	return nil
+}
+
+// routeHostname builds the v1alpha1 routeHostname: [tls[:hostname]], NONE or a hostname.
+func routeHostname(spec *WebServerSpec) string {
+	host := spec.Exposure.Route.Host
+	switch {
+	case spec.TLS.Enabled && host != "":
+		return "tls:" + host
+	case spec.TLS.Enabled:
+		return "tls"
+	case spec.Exposure.Route.Disabled:
+		return "NONE"
+	default:
+		return host
+	}
+}
+
+// exposureFromRouteHostname parses the v1alpha1 routeHostname the way the operator does,
+// it returns the route and whether TLS is enabled.
+func exposureFromRouteHostname(routeHostname string) (ExposureSpec, bool) {
+	switch {
+	case routeHostname == "NONE":
+		return ExposureSpec{Route: RouteSpec{Disabled: true}}, false
+	case strings.HasPrefix(routeHostname, "tls"):
+		return ExposureSpec{Route: RouteSpec{Host: strings.TrimPrefix(strings.TrimPrefix(routeHostname, "tls"), ":")}}, true
+	default:
+		return ExposureSpec{Route: RouteSpec{Host: routeHostname}}, false
+	}
+}
+
+// kubernetesExposureTo converts the Kubernetes exposure, the nested gateway reference prevents a type conversion.
+func kubernetesExposureTo(exposure *KubernetesExposureSpec) *v1alpha1.ExposureSpec {
+	if exposure == nil {
+		return nil
+	}
+	return &v1alpha1.ExposureSpec{
+		Type:             exposure.Type,
+		Host:             exposure.Host,
+		NodePort:         exposure.NodePort,
+		IngressClassName: exposure.IngressClassName,
+		Annotations:      exposure.Annotations,
+		Gateway:          (*v1alpha1.GatewayReference)(exposure.Gateway),
+	}
+}
+
+// kubernetesExposureFrom converts the v1alpha1 exposure to the Kubernetes exposure.
+func kubernetesExposureFrom(exposure *v1alpha1.ExposureSpec) *KubernetesExposureSpec {
+	if exposure == nil {
+		return nil
+	}
+	return &KubernetesExposureSpec{
+		Type:             exposure.Type,
+		Host:             exposure.Host,
+		NodePort:         exposure.NodePort,
+		IngressClassName: exposure.IngressClassName,
+		Annotations:      exposure.Annotations,
+		Gateway:          (*GatewayReference)(exposure.Gateway),
+	}
+}
+
+// routeTo converts the TLS termination and the annotations of the route, nil when none is set.
+func routeTo(route *RouteSpec) *v1alpha1.RouteSpec {
+	converted := &v1alpha1.RouteSpec{
+		Termination:                   route.Termination,
+		CertificateSecret:             route.CertificateSecret,
+		DestinationCASecret:           route.DestinationCASecret,
+		InsecureEdgeTerminationPolicy: route.InsecureEdgeTerminationPolicy,
+		Annotations:                   route.Annotations,
+	}
+	if converted.Termination == "" && converted.CertificateSecret == "" && converted.DestinationCASecret == "" &&
+		converted.InsecureEdgeTerminationPolicy == "" && converted.Annotations == nil {
+		return nil
+	}
+	return converted
+}
+
+// routeFrom sets the TLS termination and the annotations of the v1alpha1 route on the route.
+func routeFrom(route *v1alpha1.RouteSpec, dst *RouteSpec) {
+	if route == nil {
+		return
+	}
+	dst.Termination = route.Termination
+	dst.CertificateSecret = route.CertificateSecret
+	dst.DestinationCASecret = route.DestinationCASecret
+	dst.InsecureEdgeTerminationPolicy = route.InsecureEdgeTerminationPolicy
+	dst.Annotations = route.Annotations
+}
+
+// certManagerTo converts the cert-manager Certificate, the nested issuer reference prevents a type conversion.
+func certManagerTo(certManager *CertManagerSpec) *v1alpha1.CertManagerSpec {
+	if certManager == nil {
+		return nil
+	}
+	return &v1alpha1.CertManagerSpec{
+		IssuerRef:   v1alpha1.IssuerReference(certManager.IssuerRef),
+		DNSNames:    certManager.DNSNames,
+		Duration:    certManager.Duration,
+		RenewBefore: certManager.RenewBefore,
+	}
+}
+
+// certManagerFrom converts the v1alpha1 cert-manager Certificate.
+func certManagerFrom(certManager *v1alpha1.CertManagerSpec) *CertManagerSpec {
+	if certManager == nil {
+		return nil
+	}
+	return &CertManagerSpec{
+		IssuerRef:   IssuerReference(certManager.IssuerRef),
+		DNSNames:    certManager.DNSNames,
+		Duration:    certManager.Duration,
+		RenewBefore: certManager.RenewBefore,
+	}
+}
+
+// tomcatConfigTo converts the configuration files of tomcat, the nested files prevent a type conversion.
+func tomcatConfigTo(tomcatConfig *TomcatConfigSpec) *v1alpha1.TomcatConfigSpec {
+	if tomcatConfig == nil {
+		return nil
+	}
+	return &v1alpha1.TomcatConfigSpec{
+		ServerXml:      (*v1alpha1.TomcatConfigFile)(tomcatConfig.ServerXml),
+		ContextXml:     (*v1alpha1.TomcatConfigFile)(tomcatConfig.ContextXml),
+		WebXml:         (*v1alpha1.TomcatConfigFile)(tomcatConfig.WebXml),
+		TomcatUsersXml: (*v1alpha1.TomcatConfigFile)(tomcatConfig.TomcatUsersXml),
+	}
+}
+
+// tomcatConfigFrom converts the v1alpha1 configuration files of tomcat.
+func tomcatConfigFrom(tomcatConfig *v1alpha1.TomcatConfigSpec) *TomcatConfigSpec {
+	if tomcatConfig == nil {
+		return nil
+	}
+	return &TomcatConfigSpec{
+		ServerXml:      (*TomcatConfigFile)(tomcatConfig.ServerXml),
+		ContextXml:     (*TomcatConfigFile)(tomcatConfig.ContextXml),
+		WebXml:         (*TomcatConfigFile)(tomcatConfig.WebXml),
+		TomcatUsersXml: (*TomcatConfigFile)(tomcatConfig.TomcatUsersXml),
+	}
+}
+
+// connectorsTo converts the tuning of the connectors, the embedded ConnectorSpec of HTTPS prevents a type conversion.
+func connectorsTo(connectors *ConnectorsSpec) *v1alpha1.ConnectorsSpec {
+	if connectors == nil {
+		return nil
+	}
+	converted := &v1alpha1.ConnectorsSpec{
+		HTTP: (*v1alpha1.ConnectorSpec)(connectors.HTTP),
+		AJP:  (*v1alpha1.AJPConnectorSpec)(connectors.AJP),
+	}
+	if https := connectors.HTTPS; https != nil {
+		converted.HTTPS = &v1alpha1.HTTPSConnectorSpec{
+			ConnectorSpec: v1alpha1.ConnectorSpec(https.ConnectorSpec),
+			Ciphers:       https.Ciphers,
+			Protocols:     https.Protocols,
+		}
+	}
+	return converted
+}
+
+// connectorsFrom converts the v1alpha1 tuning of the connectors.
+func connectorsFrom(connectors *v1alpha1.ConnectorsSpec) *ConnectorsSpec {
+	if connectors == nil {
+		return nil
+	}
+	converted := &ConnectorsSpec{
+		HTTP: (*ConnectorSpec)(connectors.HTTP),
+		AJP:  (*AJPConnectorSpec)(connectors.AJP),
+	}
+	if https := connectors.HTTPS; https != nil {
+		converted.HTTPS = &HTTPSConnectorSpec{
+			ConnectorSpec: ConnectorSpec(https.ConnectorSpec),
+			Ciphers:       https.Ciphers,
+			Protocols:     https.Protocols,
+		}
+	}
+	return converted
+}
@@ -0,0 +1,4 @@
+package v1alpha1
+
+// Hub marks this type as a conversion hub, the other versions of WebServer are converted from and to v1alpha1.
+func (*WebServer) Hub() {}
@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultWebAppName is the name of the war built from the sources when webApp.name is not set,
+// the application is deployed in the root context.
+const DefaultWebAppName = "ROOT.war"
+
+const (
+	// DefaultRolloutStepSeconds is the number of seconds the canary pods must stay ready at each step
+	DefaultRolloutStepSeconds int32 = 60
+	// DefaultRolloutProgressDeadlineSeconds is the number of seconds for the canary pods to become ready at each step
+	DefaultRolloutProgressDeadlineSeconds int32 = 600
+)
+
+// DefaultRolloutSteps returns the traffic percentages of a canary rollout without steps.
+func DefaultRolloutSteps() []int32 {
+	return []int32{10, 50}
+}
+
+// DefaultSecurityContext returns the SecurityContext of the application container when the WebServer doesn't set one.
+func DefaultSecurityContext() *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	runAsNonRoot := true
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{
+				"ALL",
+			},
+		},
+		RunAsNonRoot: &runAsNonRoot,
+	}
+}
+
+// SetDefaults fills in the fields of the WebServer the operator would otherwise default when deploying it,
+// it is used by the defaulting webhook so the stored WebServer shows what is deployed.
+//
+// The replicas are defaulted to 1 by the schema of the CRD, 0 being a valid value. The probes and the route
+// hostname aren't defaulted: without the scripts the probes are HTTP GET /health on port 8080 and without
+// routeHostname the Route gets the hostname generated by OpenShift, neither can be expressed in the spec.
+func SetDefaults(webServer *WebServer) {
+	if webServer.Spec.WebImage != nil && webServer.Spec.WebImage.WebApp != nil && webServer.Spec.WebImage.WebApp.Name == "" {
+		webServer.Spec.WebImage.WebApp.Name = DefaultWebAppName
+	}
+	if webServer.Spec.SecurityContext == nil {
+		webServer.Spec.SecurityContext = DefaultSecurityContext()
+	}
+	if rollout := webServer.Spec.Rollout; rollout != nil {
+		if rollout.Strategy == RolloutStrategyCanary && len(rollout.Steps) == 0 {
+			rollout.Steps = DefaultRolloutSteps()
+		}
+		if rollout.StepSeconds == nil {
+			stepSeconds := DefaultRolloutStepSeconds
+			rollout.StepSeconds = &stepSeconds
+		}
+		if rollout.ProgressDeadlineSeconds == nil {
+			progressDeadlineSeconds := DefaultRolloutProgressDeadlineSeconds
+			rollout.ProgressDeadlineSeconds = &progressDeadlineSeconds
+		}
+	}
+	if exposure := webServer.Spec.Exposure; exposure != nil && exposure.Type == "" {
+		exposure.Type = ExposureLoadBalancer
+	}
+	if route := webServer.Spec.Route; route != nil && route.Termination != "" && route.InsecureEdgeTerminationPolicy == "" {
+		// What the router does without a policy
+		route.InsecureEdgeTerminationPolicy = InsecureEdgeTerminationPolicyNone
+	}
+}
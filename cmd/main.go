@@ -28,6 +28,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -42,6 +43,7 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	webserversorgv1 "github.com/web-servers/jws-operator/api/v1"
 	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
 
 	buildv1 "github.com/openshift/api/build/v1"
@@ -51,6 +53,7 @@ import (
 
 	buildclient "github.com/openshift/client-go/build/clientset/versioned"
 	"github.com/web-servers/jws-operator/internal/controller"
+	webhookv1 "github.com/web-servers/jws-operator/internal/webhook/v1"
 	webhookv1alpha1 "github.com/web-servers/jws-operator/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
@@ -68,6 +71,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(webserversorgv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(webserversorgv1.AddToScheme(scheme))
 
 	// Adding the openshift stuff
 	utilruntime.Must(routev1.AddToScheme(scheme))
@@ -213,6 +217,11 @@ func main() {
 			DefaultNamespaces: map[string]cache.Config{
 				os.Getenv("WATCH_NAMESPACE"): {},
 			},
+			// Only the pods of the WebServers are cached, without WATCH_NAMESPACE the cache would hold all the
+			// pods of the cluster
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Pod{}: {Label: controller.PodCacheSelector()},
+			},
 		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
@@ -262,7 +271,9 @@ func main() {
 	if err := (&controller.WebServerReconciler{
 		Client:      mgr.GetClient(),
 		Scheme:      mgr.GetScheme(),
+		APIReader:   mgr.GetAPIReader(),
 		BuildClient: ocBuildClient,
+		Recorder:    mgr.GetEventRecorderFor("jws-operator"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WebServer")
 		os.Exit(1)
@@ -273,6 +284,10 @@ func main() {
 			setupLog.Error(err, "unable to create webhook", "webhook", "WebServer")
 			os.Exit(1)
 		}
+		if err := webhookv1.SetupWebServerWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "WebServer")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -0,0 +1,94 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// tomcat-config merges the fragments of the operator into the configuration files of tomcat of the image when the
+// pods of a WebServer start. An init container of the operator image installs it in a volume shared with tomcat:
+//
+//	tomcat-config install <dir>
+//	tomcat-config merge <conf>/server.xml <fragment>
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/web-servers/jws-operator/internal/serverxml"
+)
+
+func main() {
+	var err error
+	switch {
+	case len(os.Args) == 3 && os.Args[1] == "install":
+		err = install(os.Args[2])
+	case len(os.Args) == 4 && os.Args[1] == "merge":
+		err = merge(os.Args[2], os.Args[3])
+	default:
+		err = fmt.Errorf("usage: %s install <dir> | merge <file> <fragment>", filepath.Base(os.Args[0]))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// install copies the executable into dir, the image of tomcat may have no shell tool to run in its place.
+func install(dir string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	source, err := os.Open(executable)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+	destination, err := os.OpenFile(filepath.Join(dir, filepath.Base(executable)), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		_ = destination.Close()
+		return err
+	}
+	return destination.Close()
+}
+
+// merge adds the elements of the fragment to the configuration file, the name of the file tells where they go.
+// The default file of tomcat is used when the image has none.
+func merge(file, fragment string) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		root, defaultErr := serverxml.DefaultFile(filepath.Base(file))
+		if defaultErr != nil {
+			return err
+		}
+		data, err = root.Render(), nil
+	}
+	if err != nil {
+		return err
+	}
+	elements, err := os.ReadFile(fragment)
+	if err != nil {
+		return err
+	}
+	merged, err := serverxml.Merge(filepath.Base(file), data, elements)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return os.WriteFile(file, merged, 0644)
+}
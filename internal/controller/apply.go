@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	kbappsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// fieldManager is the field manager used by the operator for server-side apply
+	fieldManager = "jws-operator"
+)
+
+// patchApply sends the desired state of resource with server-side apply, the operator takes back
+// the ownership of the fields it sets and leaves alone the fields it doesn't set.
+// On success resource contains the state returned by the API server.
+func (r *WebServerReconciler) patchApply(ctx context.Context, resource client.Object) error {
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		return err
+	}
+	resource.GetObjectKind().SetGroupVersionKind(gvk)
+	resource.SetResourceVersion("")
+	resource.SetManagedFields(nil)
+	return r.Patch(ctx, resource, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// applyResource applies the generated resource and records an Event when it is created.
+// The reconciliation is requeued after a creation.
+func (r *WebServerReconciler) applyResource(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource client.Object) (ctrl.Result, error) {
+	gvk, err := apiutil.GVKForObject(resource, r.Scheme)
+	if err != nil {
+		log.Error(err, "Unknown kind for "+resource.GetName())
+		return reconcile.Result{}, err
+	}
+	kind := gvk.Kind
+	name := resource.GetName()
+	namespace := resource.GetNamespace()
+
+	var found client.Object
+	if _, ok := resource.(*unstructured.Unstructured); ok {
+		// The kinds without Go types (Gateway API routes) aren't registered in the scheme
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(gvk)
+		found = object
+	} else {
+		object, err := r.Scheme.New(gvk)
+		if err != nil {
+			log.Error(err, "Unknown kind for "+name)
+			return reconcile.Result{}, err
+		}
+		found = object.(client.Object)
+	}
+	err = r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get "+kind+": "+name)
+		return reconcile.Result{}, err
+	}
+	created := errors.IsNotFound(err)
+
+	if created {
+		log.Info("Creating a new " + kind + ": " + name + " Namespace: " + namespace)
+	}
+	err = r.patchApply(ctx, resource)
+	if err != nil {
+		log.Error(err, "Failed to apply "+kind+": "+name+" Namespace: "+namespace)
+		if created {
+			r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create "+kind+" "+name+": "+err.Error())
+		} else {
+			r.recordWarning(webServer, eventReasonUpdateFailed, "Failed to update "+kind+" "+name+": "+err.Error())
+		}
+		return reconcile.Result{}, err
+	}
+	if created {
+		// Resource created successfully - return and requeue
+		r.recordEvent(webServer, eventReasonCreated, "Created "+kind+" "+name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+// replicasManagedByAutoscaler returns true while a HorizontalPodAutoscaler scales the Deployment or the
+// StatefulSet, in that case the operator doesn't apply the replicas to avoid fighting with it. The other changes
+// of the replicas through the scale subresource (kubectl scale...) are undone like the other changes of the spec,
+// the WebServer can be scaled instead.
+func (r *WebServerReconciler) replicasManagedByAutoscaler(ctx context.Context, kind string, resource metav1.Object) (bool, error) {
+	autoscalers := &autoscalingv2.HorizontalPodAutoscalerList{}
+	err := r.List(ctx, autoscalers, client.InNamespace(resource.GetNamespace()))
+	if err != nil {
+		log.Error(err, "Failed to list the HorizontalPodAutoscalers of "+kind+" "+resource.GetName())
+		return false, err
+	}
+	for _, autoscaler := range autoscalers.Items {
+		target := autoscaler.Spec.ScaleTargetRef
+		if target.Kind == kind && target.Name == resource.GetName() && strings.HasPrefix(target.APIVersion, kbappsv1.GroupName+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// webServerForAutoscaler maps a HorizontalPodAutoscaler to the WebServer of the Deployment or StatefulSet it
+// scales, the replicas are applied again when it is deleted.
+func (r *WebServerReconciler) webServerForAutoscaler(ctx context.Context, obj client.Object) []reconcile.Request {
+	autoscaler, ok := obj.(*autoscalingv2.HorizontalPodAutoscaler)
+	if !ok {
+		return nil
+	}
+	var target client.Object
+	switch autoscaler.Spec.ScaleTargetRef.Kind {
+	case "Deployment":
+		target = &kbappsv1.Deployment{}
+	case "StatefulSet":
+		target = &kbappsv1.StatefulSet{}
+	default:
+		return nil
+	}
+	err := r.Get(ctx, types.NamespacedName{Name: autoscaler.Spec.ScaleTargetRef.Name, Namespace: autoscaler.Namespace}, target)
+	if err != nil {
+		return nil
+	}
+	return r.webServerForObject(ctx, target)
+}
+
+// keepContainerImage copies the image of the named container from the found pod template, it is used when
+// the image is set by an OpenShift image trigger.
+func keepContainerImage(template *corev1.PodTemplateSpec, found *corev1.PodTemplateSpec, name string) {
+	for _, foundContainer := range found.Spec.Containers {
+		if foundContainer.Name != name {
+			continue
+		}
+		for i := range template.Spec.Containers {
+			if template.Spec.Containers[i].Name == name {
+				template.Spec.Containers[i].Image = foundContainer.Image
+			}
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// buildAttemptAnnotation records on the build Job the attempt of its first Pod, on the BuildRun its attempt, at
+	// building the hash of their webserver-hash label
+	buildAttemptAnnotation = "web.servers.org/build-attempt"
+	// defaultBuildBackoffLimit is the number of retries of a failed build when the builder doesn't set it
+	defaultBuildBackoffLimit = 3
+	// defaultBuildActiveDeadlineSeconds stops the builds running for more than 30 minutes
+	defaultBuildActiveDeadlineSeconds = 1800
+	// buildBackoffBase delays the first retry of a failed BuildRun, the delay doubles with each failure up to
+	// buildBackoffMax like the retries of the Pods of a Job
+	buildBackoffBase = 10 * time.Second
+	buildBackoffMax  = 6 * time.Minute
+	// maxBuildRecords is the number of builds recorded in the status
+	maxBuildRecords = 5
+	// buildLogLines is the number of lines of the log of a failed build recorded in the status
+	buildLogLines = 20
+	// defaultBuildahImage builds and pushes the image of the Buildah strategy as a non-root user, the operator uses
+	// the image of its RELATED_IMAGE_BUILDAH environment variable when it is set
+	defaultBuildahImage = "quay.io/buildah/stable:v1.38"
+)
+
+// buildahImage returns the image of the container building the image with the Buildah strategy.
+func buildahImage() string {
+	if image := os.Getenv("RELATED_IMAGE_BUILDAH"); image != "" {
+		return image
+	}
+	return defaultBuildahImage
+}
+
+// buildStrategy returns how the application image is built, Script when the builder doesn't set it.
+func buildStrategy(webServer *webserversv1alpha1.WebServer) string {
+	if strategy := webServer.Spec.WebImage.WebApp.Builder.Strategy; strategy != "" {
+		return strategy
+	}
+	return webserversv1alpha1.BuildStrategyScript
+}
+
+// buildsWebApp returns true when the application image is built from the sources of the webApp.
+func buildsWebApp(webServer *webserversv1alpha1.WebServer) bool {
+	webApp := webServer.Spec.WebImage.WebApp
+	if webApp == nil || webApp.SourceRepositoryURL == "" || webApp.Builder == nil {
+		return false
+	}
+	return webApp.Builder.Image != "" || buildStrategy(webServer) == webserversv1alpha1.BuildStrategyShipwright
+}
+
+// usesBuildFiles returns true when the build Job mounts the build script and the Dockerfile of the ConfigMap
+// webserver-bd-<name>.
+func usesBuildFiles(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" || buildStrategy(webServer) == webserversv1alpha1.BuildStrategyBuildah
+}
+
+// buildBackoffLimit returns the number of retries of a failed build.
+func buildBackoffLimit(webServer *webserversv1alpha1.WebServer) int32 {
+	if limit := webServer.Spec.WebImage.WebApp.Builder.BackoffLimit; limit != nil {
+		return *limit
+	}
+	return defaultBuildBackoffLimit
+}
+
+// buildActiveDeadlineSeconds returns how long a build may run.
+func buildActiveDeadlineSeconds(webServer *webserversv1alpha1.WebServer) int64 {
+	if deadline := webServer.Spec.WebImage.WebApp.Builder.ActiveDeadlineSeconds; deadline != nil {
+		return *deadline
+	}
+	return defaultBuildActiveDeadlineSeconds
+}
+
+// buildBackoff returns the delay before the retry of the failed attempt.
+func buildBackoff(attempt int32) time.Duration {
+	delay := buildBackoffBase
+	for i := int32(1); i < attempt && delay < buildBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, buildBackoffMax)
+}
+
+// lastBuild returns the record of the last attempt at building hash, nil when it wasn't built yet.
+func lastBuild(webServer *webserversv1alpha1.WebServer, hash string) *webserversv1alpha1.BuildRecord {
+	var last *webserversv1alpha1.BuildRecord
+	for i, record := range webServer.Status.Builds {
+		if record.Hash == hash && (last == nil || record.Attempt > last.Attempt) {
+			last = &webServer.Status.Builds[i]
+		}
+	}
+	return last
+}
+
+// buildRecord returns the record of an attempt at building hash, it is added at the head of the records when it
+// doesn't exist and the oldest records are dropped.
+func buildRecord(webServer *webserversv1alpha1.WebServer, hash string, attempt int32) *webserversv1alpha1.BuildRecord {
+	for i, record := range webServer.Status.Builds {
+		if record.Hash == hash && record.Attempt == attempt {
+			return &webServer.Status.Builds[i]
+		}
+	}
+	webApp := webServer.Spec.WebImage.WebApp
+	record := webserversv1alpha1.BuildRecord{
+		Hash:       hash,
+		Attempt:    attempt,
+		Phase:      string(corev1.PodPending),
+		Repository: webApp.SourceRepositoryURL,
+		Ref:        webApp.SourceRepositoryRef,
+	}
+	builds := append([]webserversv1alpha1.BuildRecord{record}, webServer.Status.Builds...)
+	if len(builds) > maxBuildRecords {
+		builds = builds[:maxBuildRecords]
+	}
+	webServer.Status.Builds = builds
+	return &webServer.Status.Builds[0]
+}
+
+// buildAttempt returns the attempt recorded on the build Job or the BuildRun, the build Jobs created before the
+// records are the first.
+func buildAttempt(object metav1.Object) int32 {
+	attempt, err := strconv.ParseInt(object.GetAnnotations()[buildAttemptAnnotation], 10, 32)
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return int32(attempt)
+}
+
+// nextBuildAttempt returns the attempt of a new build of hash: the last one again when it didn't fail, the retry
+// of the last one otherwise.
+func nextBuildAttempt(webServer *webserversv1alpha1.WebServer, hash string) int32 {
+	last := lastBuild(webServer, hash)
+	switch {
+	case last == nil:
+		return 1
+	case last.Phase == string(corev1.PodFailed):
+		return last.Attempt + 1
+	}
+	return last.Attempt
+}
+
+// buildGaveUp returns true when the BuildRuns of hash failed more than the backoff limit allows, they are retried
+// once the WebServer changes.
+func buildGaveUp(webServer *webserversv1alpha1.WebServer, hash string) bool {
+	last := lastBuild(webServer, hash)
+	return last != nil && last.Phase == string(corev1.PodFailed) && last.Attempt > buildBackoffLimit(webServer)
+}
+
+// buildWebApp builds the application image from the sources with the strategy of the builder, it returns true once
+// the image of the current WebServer is pushed.
+func (r *WebServerReconciler) buildWebApp(ctx context.Context, webServer *webserversv1alpha1.WebServer) (bool, ctrl.Result, error) {
+	// The previous versions of the operator built the application in a bare Pod, without the WebServer label
+	// it isn't in the cache of the pods
+	legacyPod := &corev1.Pod{}
+	legacyPod.Name = webServer.Spec.ApplicationName + "-build"
+	legacyPod.Namespace = webServer.Namespace
+	if err := r.deleteForWebServerFrom(ctx, r.APIReader, webServer, legacyPod, "Pod"); err != nil {
+		return false, ctrl.Result{}, err
+	}
+
+	if buildStrategy(webServer) == webserversv1alpha1.BuildStrategyShipwright {
+		return r.buildWithShipwright(ctx, webServer)
+	}
+
+	// Create a ConfigMap for the build script and the Dockerfile
+	if usesBuildFiles(webServer) {
+		configMap := r.generateConfigMapForCustomBuildScript(webServer)
+		result, err := r.applyResource(ctx, webServer, configMap)
+		if err != nil || result != (ctrl.Result{}) {
+			return false, result, err
+		}
+	}
+	return r.buildWithJob(ctx, webServer)
+}
+
+// buildWithJob runs the build in a Job retrying the failed Pods up to the backoff limit within the active deadline,
+// the Job of a previous version of the WebServer is deleted with its Pods.
+func (r *WebServerReconciler) buildWithJob(ctx context.Context, webServer *webserversv1alpha1.WebServer) (bool, ctrl.Result, error) {
+	job := r.generateBuildJob(webServer)
+	currentHash := job.Labels["webserver-hash"]
+	err := r.Get(ctx, client.ObjectKeyFromObject(job), job)
+	if errors.IsNotFound(err) {
+		result, err := r.startBuildJob(ctx, webServer, job)
+		return false, result, err
+	} else if err != nil {
+		log.Error(err, "Failed to get build Job: "+job.Name)
+		return false, ctrl.Result{}, err
+	}
+	if job.DeletionTimestamp != nil {
+		// The deletion of the build Job triggers the next reconciliation
+		return false, ctrl.Result{}, nil
+	}
+
+	if job.Labels["webserver-hash"] != currentHash {
+		// The Pods of the Job aren't deleted with it by default
+		err = r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete build Job: "+job.Name)
+			return false, ctrl.Result{}, err
+		}
+		// The deletion of the build Job triggers the next reconciliation
+		log.Info("Webserver hash changed: Delete build Job")
+		r.recordEvent(webServer, eventReasonRebuilding, "WebServer changed, rebuilding the application with a new build Job")
+		return false, ctrl.Result{}, nil
+	}
+
+	return r.checkBuildJob(ctx, webServer, job)
+}
+
+// startBuildJob records the first attempt of the build Job and creates it, the attempts of a Job replacing a
+// failed one follow its last attempt.
+func (r *WebServerReconciler) startBuildJob(ctx context.Context, webServer *webserversv1alpha1.WebServer, job *batchv1.Job) (ctrl.Result, error) {
+	hash := job.Labels["webserver-hash"]
+	attempt := nextBuildAttempt(webServer, hash)
+	if err := r.recordBuildStart(ctx, webServer, hash, attempt); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if job.Annotations == nil {
+		job.Annotations = make(map[string]string)
+	}
+	job.Annotations[buildAttemptAnnotation] = strconv.Itoa(int(attempt))
+	log.Info("Creating a new build Job: " + job.Name + " Namespace: " + job.Namespace)
+	if err := r.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create a new Job: "+job.Name+" Namespace: "+job.Namespace)
+		r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create build Job "+job.Name+": "+err.Error())
+		return ctrl.Result{}, err
+	}
+	r.recordEvent(webServer, eventReasonBuildStarted, "Started build Job "+job.Name)
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// recordBuildStart records a new attempt at building hash.
+func (r *WebServerReconciler) recordBuildStart(ctx context.Context, webServer *webserversv1alpha1.WebServer, hash string, attempt int32) error {
+	webApp := webServer.Spec.WebImage.WebApp
+	record := buildRecord(webServer, hash, attempt)
+	*record = webserversv1alpha1.BuildRecord{
+		Hash:       hash,
+		Attempt:    attempt,
+		Phase:      string(corev1.PodPending),
+		Repository: webApp.SourceRepositoryURL,
+		Ref:        webApp.SourceRepositoryRef,
+	}
+	return r.updateStatus(ctx, webServer)
+}
+
+// checkBuildJob records the Pods of the build Job in the status and reports the build in the BuildSucceeded
+// condition, it returns true once the application is built. A failed Job is kept until the WebServer changes or
+// it is deleted.
+func (r *WebServerReconciler) checkBuildJob(ctx context.Context, webServer *webserversv1alpha1.WebServer, job *batchv1.Job) (bool, ctrl.Result, error) {
+	pods := &corev1.PodList{}
+	err := r.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.ControllerUidLabel: string(job.UID)},
+	)
+	if err != nil {
+		log.Error(err, "unable to list the Pods of build Job "+job.Name)
+		return false, ctrl.Result{}, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+
+	hash := job.Labels["webserver-hash"]
+	first := buildAttempt(job)
+	previous := webServer.Status.DeepCopy().Builds
+	// Only the last Pods fit in the records
+	for i := max(0, len(pods.Items)-maxBuildRecords); i < len(pods.Items); i++ {
+		updateBuildRecord(buildRecord(webServer, hash, first+int32(i)), &pods.Items[i])
+	}
+	last := lastBuild(webServer, hash)
+
+	changed := false
+	built := false
+	switch failed := jobCondition(job, batchv1.JobFailed); {
+	case jobCondition(job, batchv1.JobComplete) != nil:
+		message := "Application built by Job " + job.Name
+		if r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionTrue, reasonBuildCompleted, message) {
+			r.recordEvent(webServer, eventReasonBuildSucceeded, message)
+			changed = true
+		}
+		built = true
+	case failed != nil:
+		reason := reasonBuildBackoffLimitExceeded
+		if failed.Reason == batchv1.JobReasonDeadlineExceeded {
+			reason = reasonBuildDeadlineExceeded
+		}
+		if last != nil && last.Phase != string(corev1.PodFailed) {
+			// The Pods still running when the deadline is exceeded are deleted
+			last.Phase = string(corev1.PodFailed)
+			last.FailureReason = failed.Reason
+			completionTime := failed.LastTransitionTime
+			last.CompletionTime = &completionTime
+		}
+		message := "Application build failed, giving up until the WebServer changes: " + failed.Message
+		if last != nil && last.FailureReason != failed.Reason {
+			message += ": " + last.FailureReason
+		}
+		log.Info(message)
+		if r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reason, message) {
+			r.recordWarning(webServer, eventReasonBuildFailed, "Build Job "+job.Name+" failed: "+message)
+			changed = true
+		}
+	case last == nil || last.Phase == string(corev1.PodPending):
+		log.Info("Application build pending")
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reasonBuildPending, "Application build pending")
+	case last.Phase == string(corev1.PodFailed):
+		// The Job creates the Pod of the next attempt after its backoff delay
+		message := fmt.Sprintf("Application build failed (attempt %d of %d), retrying: %s", last.Attempt, first+*job.Spec.BackoffLimit, last.FailureReason)
+		log.Info(message)
+		if r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reasonBuildFailed, message) {
+			r.recordWarning(webServer, eventReasonBuildFailed, "Build Job "+job.Name+" failed: "+message)
+			changed = true
+		}
+	case last.Phase == string(corev1.PodRunning) || last.Phase == string(corev1.PodSucceeded):
+		log.Info("Application is still being built")
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reasonBuildRunning, "Application is still being built")
+	default:
+		log.Info("Unknown build pod status")
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionUnknown, reasonBuildUnknown, "Unknown build pod status")
+	}
+	if changed || !equality.Semantic.DeepEqual(previous, webServer.Status.Builds) {
+		if err := r.updateStatus(ctx, webServer); err != nil {
+			return false, ctrl.Result{}, err
+		}
+	}
+	return built, ctrl.Result{}, nil
+}
+
+// jobCondition returns the condition of the Job when it is true.
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// updateBuildRecord copies the state of the build Pod to its record: the times, the commit and the digest
+// reported by the build containers in their termination message, and why a failed build failed with the end of
+// its log.
+func updateBuildRecord(record *webserversv1alpha1.BuildRecord, buildPod *corev1.Pod) {
+	record.Phase = string(buildPod.Status.Phase)
+	if buildPod.Status.StartTime != nil {
+		record.StartTime = buildPod.Status.StartTime
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, buildPod.Status.InitContainerStatuses...), buildPod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		terminated := status.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		finishedAt := terminated.FinishedAt
+		record.CompletionTime = &finishedAt
+		commit, digest, logTail := parseTerminationMessage(terminated.Message)
+		if commit != "" {
+			record.Commit = commit
+		}
+		if digest != "" {
+			record.ImageDigest = digest
+		}
+		if buildPod.Status.Phase == corev1.PodFailed && terminated.ExitCode != 0 {
+			record.FailureReason = fmt.Sprintf("%s (exit code %d)", terminated.Reason, terminated.ExitCode)
+			record.LogTail = logTail
+		}
+	}
+	if buildPod.Status.Phase == corev1.PodFailed && record.FailureReason == "" {
+		// The Pod failed without running the build, like an eviction
+		record.FailureReason = strings.TrimSpace(buildPod.Status.Reason + " " + buildPod.Status.Message)
+		if record.CompletionTime == nil {
+			now := metav1.Now()
+			record.CompletionTime = &now
+		}
+	}
+}
+
+// parseTerminationMessage splits the termination message of a build container: the build script reports the
+// commit and the digest of the image with commit=<sha> and digest=<digest> lines, buildah writes the bare digest,
+// the other lines are the end of the log of a failed build (terminationMessagePolicy FallbackToLogsOnError).
+func parseTerminationMessage(message string) (commit, digest, logTail string) {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "commit="):
+			commit = strings.TrimSpace(strings.TrimPrefix(line, "commit="))
+		case strings.HasPrefix(line, "digest="):
+			digest = strings.TrimSpace(strings.TrimPrefix(line, "digest="))
+		case strings.HasPrefix(line, "sha256:"):
+			digest = strings.TrimSpace(line)
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > buildLogLines {
+		lines = lines[len(lines)-buildLogLines:]
+	}
+	return commit, digest, strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+// logLines returns the lines "line <from>" to "line <to>" of a build log.
+func logLines(from, to int) string {
+	lines := []string{}
+	for i := from; i <= to; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseTerminationMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		commit  string
+		digest  string
+		logTail string
+	}{
+		{"of the build script", "commit=0a1b2c\ndigest=sha256:3d4e5f\n", "0a1b2c", "sha256:3d4e5f", ""},
+		{"of buildah", "sha256:3d4e5f", "", "sha256:3d4e5f", ""},
+		{"of a failed build", "Cloning\nBUILD FAILURE\n", "", "", "Cloning\nBUILD FAILURE"},
+		{"of a failed build with a long log", logLines(1, 30) + "\n", "", "", logLines(11, 30)},
+		{"without message", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			commit, digest, logTail := parseTerminationMessage(tt.message)
+			g.Expect(commit).To(Equal(tt.commit))
+			g.Expect(digest).To(Equal(tt.digest))
+			g.Expect(logTail).To(Equal(tt.logTail))
+		})
+	}
+}
+
+func TestBuildBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int32
+		delay   time.Duration
+	}{
+		{"after the first attempt", 1, 10 * time.Second},
+		{"after the second attempt", 2, 20 * time.Second},
+		{"after the sixth attempt", 6, 320 * time.Second},
+		{"up to the maximum", 7, 6 * time.Minute},
+		{"after many attempts", 40, 6 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NewWithT(t).Expect(buildBackoff(tt.attempt)).To(Equal(tt.delay))
+		})
+	}
+}
+
+func TestBuildAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		builds  []webserversorgv1alpha1.BuildRecord
+		attempt int32
+		gaveUp  bool
+	}{
+		{"without build", nil, 1, false},
+		{"while the build runs", []webserversorgv1alpha1.BuildRecord{
+			{Hash: "hash", Attempt: 1, Phase: string(corev1.PodRunning)},
+		}, 1, false},
+		{"after a failed build", []webserversorgv1alpha1.BuildRecord{
+			{Hash: "hash", Attempt: 2, Phase: string(corev1.PodFailed)},
+			{Hash: "hash", Attempt: 1, Phase: string(corev1.PodFailed)},
+		}, 3, false},
+		{"after the backoff limit", []webserversorgv1alpha1.BuildRecord{
+			{Hash: "hash", Attempt: 3, Phase: string(corev1.PodFailed)},
+		}, 4, true},
+		{"after the builds of another hash", []webserversorgv1alpha1.BuildRecord{
+			{Hash: "other", Attempt: 3, Phase: string(corev1.PodFailed)},
+		}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			backoffLimit := int32(2)
+			webServer := &webserversorgv1alpha1.WebServer{
+				Spec: webserversorgv1alpha1.WebServerSpec{
+					WebImage: &webserversorgv1alpha1.WebImageSpec{
+						WebApp: &webserversorgv1alpha1.WebAppSpec{
+							Builder: &webserversorgv1alpha1.BuilderSpec{BackoffLimit: &backoffLimit},
+						},
+					},
+				},
+				Status: webserversorgv1alpha1.WebServerStatus{Builds: tt.builds},
+			}
+			g.Expect(nextBuildAttempt(webServer, "hash")).To(Equal(tt.attempt))
+			g.Expect(buildGaveUp(webServer, "hash")).To(Equal(tt.gaveUp))
+		})
+	}
+}
+
+// newBuildRecord returns a pending build record and a build pod started at 10:00.
+func newBuildRecord() (*webserversorgv1alpha1.BuildRecord, *corev1.Pod) {
+	startTime := metav1.NewTime(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC))
+	record := &webserversorgv1alpha1.BuildRecord{Hash: "hash", Attempt: 1, Phase: string(corev1.PodPending)}
+	buildPod := &corev1.Pod{Status: corev1.PodStatus{StartTime: &startTime}}
+	return record, buildPod
+}
+
+func TestBuildRecordSucceeded(t *testing.T) {
+	g := NewWithT(t)
+	record, buildPod := newBuildRecord()
+	finishedAt := metav1.NewTime(time.Date(2025, 1, 1, 10, 5, 0, 0, time.UTC))
+	buildPod.Status.Phase = corev1.PodSucceeded
+	buildPod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			Message:    "commit=0a1b2c\n",
+			FinishedAt: *buildPod.Status.StartTime,
+		}},
+	}}
+	buildPod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			Message:    "sha256:3d4e5f",
+			FinishedAt: finishedAt,
+		}},
+	}}
+	updateBuildRecord(record, buildPod)
+	g.Expect(record.Phase).To(Equal(string(corev1.PodSucceeded)))
+	g.Expect(record.StartTime).To(Equal(buildPod.Status.StartTime))
+	g.Expect(record.CompletionTime).To(Equal(&finishedAt))
+	g.Expect(record.Commit).To(Equal("0a1b2c"))
+	g.Expect(record.ImageDigest).To(Equal("sha256:3d4e5f"))
+	g.Expect(record.FailureReason).To(BeEmpty())
+}
+
+func TestBuildRecordFailed(t *testing.T) {
+	g := NewWithT(t)
+	record, buildPod := newBuildRecord()
+	finishedAt := metav1.NewTime(time.Date(2025, 1, 1, 10, 5, 0, 0, time.UTC))
+	buildPod.Status.Phase = corev1.PodFailed
+	buildPod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode:   1,
+			Reason:     "Error",
+			Message:    "commit=0a1b2c\nCompiling\nBUILD FAILURE\n",
+			FinishedAt: finishedAt,
+		}},
+	}}
+	updateBuildRecord(record, buildPod)
+	g.Expect(record.Phase).To(Equal(string(corev1.PodFailed)))
+	g.Expect(record.Commit).To(Equal("0a1b2c"))
+	g.Expect(record.FailureReason).To(Equal("Error (exit code 1)"))
+	g.Expect(record.LogTail).To(Equal("Compiling\nBUILD FAILURE"))
+	g.Expect(record.CompletionTime).To(Equal(&finishedAt))
+}
+
+func TestBuildRecordEvicted(t *testing.T) {
+	g := NewWithT(t)
+	record, buildPod := newBuildRecord()
+	buildPod.Status.Phase = corev1.PodFailed
+	buildPod.Status.Reason = "Evicted"
+	buildPod.Status.Message = "The node was low on resource: ephemeral-storage."
+	updateBuildRecord(record, buildPod)
+	g.Expect(record.FailureReason).To(Equal("Evicted The node was low on resource: ephemeral-storage."))
+	g.Expect(record.CompletionTime).NotTo(BeNil())
+	g.Expect(record.LogTail).To(BeEmpty())
+}
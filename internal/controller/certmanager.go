@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// hasCertManager checks if the Certificate kind of cert-manager is registered in the cluster.
+func hasCertManager(c *rest.Config) bool {
+	return CustomResourceDefinitionExists(certificateGVK, c)
+}
+
+// useCertManager returns true when the certificate of tomcat is issued by cert-manager.
+func (r *WebServerReconciler) useCertManager(webServer *webserversv1alpha1.WebServer) bool {
+	return r.hasCertManager && webServer.Spec.TLSConfig.CertManager != nil
+}
+
+// tlsSecretName returns the Secret mounted in /tls: tlsSecret, or the Secret of the cert-manager Certificate.
+func (r *WebServerReconciler) tlsSecretName(webServer *webserversv1alpha1.WebServer) string {
+	if webServer.Spec.TLSConfig.TLSSecret == "" && r.useCertManager(webServer) {
+		return webServer.Spec.ApplicationName + "-tls"
+	}
+	return webServer.Spec.TLSConfig.TLSSecret
+}
+
+// applyCertificate applies the cert-manager Certificate of tomcat, it returns true once the Certificate has been
+// issued: the pods can't start before its Secret exists.
+func (r *WebServerReconciler) applyCertificate(ctx context.Context, webServer *webserversv1alpha1.WebServer) (bool, ctrl.Result, error) {
+	certificate := r.generateCertificate(webServer)
+	result, err := r.applyResource(ctx, webServer, certificate)
+	if err != nil || result != (ctrl.Result{}) {
+		return false, result, err
+	}
+	_, issued, _ := unstructured.NestedInt64(certificate.Object, "status", "revision")
+	return issued, reconcile.Result{}, nil
+}
+
+// generateCertificate requests the certificate of tomcat from the issuer, by default for the names of the
+// application Service and the host of the route.
+func (r *WebServerReconciler) generateCertificate(webServer *webserversv1alpha1.WebServer) *unstructured.Unstructured {
+	certManager := webServer.Spec.TLSConfig.CertManager
+	issuerRef := map[string]interface{}{
+		"name": certManager.IssuerRef.Name,
+	}
+	if certManager.IssuerRef.Kind != "" {
+		issuerRef["kind"] = certManager.IssuerRef.Kind
+	}
+	if certManager.IssuerRef.Group != "" {
+		issuerRef["group"] = certManager.IssuerRef.Group
+	}
+	dnsNames := certManager.DNSNames
+	if len(dnsNames) == 0 {
+		dnsNames = certificateDNSNames(webServer)
+	}
+	names := make([]interface{}, len(dnsNames))
+	for i, name := range dnsNames {
+		names[i] = name
+	}
+	spec := map[string]interface{}{
+		"secretName": r.tlsSecretName(webServer),
+		"issuerRef":  issuerRef,
+		"dnsNames":   names,
+	}
+	if certManager.Duration != nil {
+		spec["duration"] = certManager.Duration.Duration.String()
+	}
+	if certManager.RenewBefore != nil {
+		spec["renewBefore"] = certManager.RenewBefore.Duration.String()
+	}
+
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(webServer.Spec.ApplicationName)
+	certificate.SetNamespace(webServer.Namespace)
+	certificate.SetLabels(r.generateLabelsForWeb(webServer))
+
+	err := controllerutil.SetControllerReference(webServer, certificate, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return certificate
+}
+
+// certificateDNSNames returns the names of the application Service and the hosts of the application.
+func certificateDNSNames(webServer *webserversv1alpha1.WebServer) []string {
+	service := webServer.Spec.ApplicationName + "." + webServer.Namespace + ".svc"
+	names := []string{webServer.Spec.ApplicationName, service, service + ".cluster.local"}
+	if host := strings.TrimPrefix(strings.TrimPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls"), ":"); host != "" && host != "NONE" {
+		names = append(names, host)
+	}
+	if webServer.Spec.Exposure != nil && webServer.Spec.Exposure.Host != "" {
+		names = append(names, webServer.Spec.Exposure.Host)
+	}
+	return names
+}
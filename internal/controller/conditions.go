@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Reasons used in the WebServer status conditions
+const (
+	reasonReconciling               = "Reconciling"
+	reasonReconcileSucceeded        = "ReconcileSucceeded"
+	reasonReconcileFailed           = "ReconcileFailed"
+	reasonReplicasReady             = "ReplicasReady"
+	reasonReplicasNotReady          = "ReplicasNotReady"
+	reasonPodsFailed                = "PodsFailed"
+	reasonAsExpected                = "AsExpected"
+	reasonBuildPending              = "BuildPending"
+	reasonBuildRunning              = "BuildRunning"
+	reasonBuildFailed               = "BuildFailed"
+	reasonBuildCompleted            = "BuildCompleted"
+	reasonBuildUnknown              = "BuildUnknown"
+	reasonBuildBackoffLimitExceeded = "BuildBackoffLimitExceeded"
+	reasonBuildDeadlineExceeded     = "BuildDeadlineExceeded"
+	reasonInvalidSpec               = "InvalidSpec"
+	reasonRevisionNotReady          = "RevisionNotReady"
+	reasonWebServerChanged          = "WebServerChanged"
+)
+
+// setCondition adds or updates a condition in the WebServer status, it returns true if the condition has changed.
+func (r *WebServerReconciler) setCondition(webServer *webserversv1alpha1.WebServer, conditionType string, status metav1.ConditionStatus, reason, message string) bool {
+	return meta.SetStatusCondition(&webServer.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: webServer.Generation,
+	})
+}
+
+// updateStatus writes the status of the WebServer, a conflict is not considered as an error
+// because the next reconciliation will compute the status again.
+func (r *WebServerReconciler) updateStatus(ctx context.Context, webServer *webserversv1alpha1.WebServer) error {
+	if err := r.Status().Update(ctx, webServer); err != nil {
+		if errors.IsConflict(err) {
+			log.V(1).Info(err.Error())
+			return nil
+		}
+		log.Error(err, "Failed to update the status of WebServer")
+		return err
+	}
+	return nil
+}
+
+// reconcileStep reports the outcome of an intermediate reconciliation step in the status conditions.
+// An error sets ReconcileError and Degraded, a requeue without error means the step is still progressing.
+func (r *WebServerReconciler) reconcileStep(ctx context.Context, webServer *webserversv1alpha1.WebServer, step string, result ctrl.Result, err error) (ctrl.Result, error) {
+	changed := false
+	if err != nil {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionReconcileError, metav1.ConditionTrue, reasonReconcileFailed, step+": "+err.Error())
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonReconcileFailed, step+": "+err.Error()) || changed
+	} else {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionProgressing, metav1.ConditionTrue, reasonReconciling, "Waiting for "+step)
+	}
+	if webServer.Status.ObservedGeneration != webServer.Generation {
+		webServer.Status.ObservedGeneration = webServer.Generation
+		changed = true
+	}
+	if changed {
+		if statusErr := r.updateStatus(ctx, webServer); statusErr != nil && err == nil {
+			return result, statusErr
+		}
+	}
+	return result, err
+}
+
+// invalidSpec reports a WebServer that can't be reconciled as specified, the problem is recorded in a
+// Warning Event and in the Degraded condition. There is no point retrying until the WebServer is changed.
+func (r *WebServerReconciler) invalidSpec(ctx context.Context, webServer *webserversv1alpha1.WebServer, message string) (ctrl.Result, error) {
+	log.Info("Invalid WebServer: " + message)
+	r.recordWarning(webServer, reasonInvalidSpec, message)
+	changed := r.setCondition(webServer, webserversv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonInvalidSpec, message)
+	changed = r.setCondition(webServer, webserversv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonInvalidSpec, message) || changed
+	changed = r.setCondition(webServer, webserversv1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonInvalidSpec, message) || changed
+	if webServer.Status.ObservedGeneration != webServer.Generation {
+		webServer.Status.ObservedGeneration = webServer.Generation
+		changed = true
+	}
+	if changed {
+		return ctrl.Result{}, r.updateStatus(ctx, webServer)
+	}
+	return ctrl.Result{}, nil
+}
+
+// setFinalConditions computes the conditions once all the resources have been reconciled, the ready replicas are
+// compared to the replicas desired by the WebServer or by its HorizontalPodAutoscaler.
+// It returns true if the status needs to be updated.
+func (r *WebServerReconciler) setFinalConditions(webServer *webserversv1alpha1.WebServer, desiredReplicas, readyReplicas int32, requeue bool) bool {
+	changed := r.setCondition(webServer, webserversv1alpha1.ConditionReconcileError, metav1.ConditionFalse, reasonReconcileSucceeded, "")
+
+	if readyReplicas >= desiredReplicas {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionAvailable, metav1.ConditionTrue, reasonReplicasReady, "All the requested replicas are ready") || changed
+	} else {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady, "Not all the requested replicas are ready") || changed
+	}
+
+	if requeue || readyReplicas != desiredReplicas {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionProgressing, metav1.ConditionTrue, reasonReplicasNotReady, "Waiting for the pods to be ready") || changed
+	} else {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonReconcileSucceeded, "") || changed
+	}
+
+	failedPods := 0
+	for _, pod := range webServer.Status.Pods {
+		if pod.State == webserversv1alpha1.PodStateFailed {
+			failedPods++
+		}
+	}
+	if failedPods > 0 {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonPodsFailed, "Some pods have failed") || changed
+	} else {
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionDegraded, metav1.ConditionFalse, reasonAsExpected, "") || changed
+	}
+
+	if webServer.Status.ObservedGeneration != webServer.Generation {
+		webServer.Status.ObservedGeneration = webServer.Generation
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+// newConditionsTest returns a WebServer at generation 2 whose status was computed at generation 1.
+func newConditionsTest() *webserversorgv1alpha1.WebServer {
+	return &webserversorgv1alpha1.WebServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Generation: 2},
+		Spec: webserversorgv1alpha1.WebServerSpec{
+			ApplicationName: "demo",
+			Replicas:        2,
+			WebImage:        &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/demo/app:1"},
+		},
+		Status: webserversorgv1alpha1.WebServerStatus{ObservedGeneration: 1},
+	}
+}
+
+// expectCondition checks the status, the reason and the generation of a condition.
+func expectCondition(g *WithT, webServer *webserversorgv1alpha1.WebServer, conditionType string, status metav1.ConditionStatus, reason string) {
+	condition := meta.FindStatusCondition(webServer.Status.Conditions, conditionType)
+	g.Expect(condition).NotTo(BeNil(), conditionType)
+	g.Expect(condition.Status).To(Equal(status), conditionType)
+	g.Expect(condition.Reason).To(Equal(reason), conditionType)
+	g.Expect(condition.ObservedGeneration).To(Equal(webServer.Generation), conditionType)
+}
+
+// storedWebServer returns the WebServer written by the reconciler.
+func storedWebServer(g *WithT, reconciler *WebServerReconciler) *webserversorgv1alpha1.WebServer {
+	webServer := &webserversorgv1alpha1.WebServer{}
+	g.Expect(reconciler.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "demo"}, webServer)).To(Succeed())
+	return webServer
+}
+
+func TestSetCondition(t *testing.T) {
+	g := NewWithT(t)
+	webServer := newConditionsTest()
+	reconciler := &WebServerReconciler{}
+
+	g.Expect(reconciler.setCondition(webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady, "")).To(BeTrue())
+	expectCondition(g, webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady)
+	transitionTime := meta.FindStatusCondition(webServer.Status.Conditions, webserversorgv1alpha1.ConditionAvailable).LastTransitionTime
+
+	// Setting the same condition again changes nothing
+	g.Expect(reconciler.setCondition(webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady, "")).To(BeFalse())
+
+	// A new generation is recorded without a transition
+	webServer.Generation = 3
+	g.Expect(reconciler.setCondition(webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady, "")).To(BeTrue())
+	expectCondition(g, webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonReplicasNotReady)
+	g.Expect(meta.FindStatusCondition(webServer.Status.Conditions, webserversorgv1alpha1.ConditionAvailable).LastTransitionTime).To(Equal(transitionTime))
+
+	g.Expect(reconciler.setCondition(webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionTrue, reasonReplicasReady, "")).To(BeTrue())
+	expectCondition(g, webServer, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionTrue, reasonReplicasReady)
+	g.Expect(webServer.Status.Conditions).To(HaveLen(1))
+}
+
+func TestReconcileStepFailed(t *testing.T) {
+	g := NewWithT(t)
+	webServer := newConditionsTest()
+	reconciler := newTestReconciler(webServer)
+
+	stepErr := errors.New("connection refused")
+	result, err := reconciler.reconcileStep(context.Background(), webServer, "routing Service", ctrl.Result{}, stepErr)
+	g.Expect(err).To(MatchError(stepErr))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+
+	stored := storedWebServer(g, reconciler)
+	g.Expect(stored.Status.ObservedGeneration).To(Equal(int64(2)))
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionReconcileError, metav1.ConditionTrue, reasonReconcileFailed)
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonReconcileFailed)
+	g.Expect(meta.FindStatusCondition(stored.Status.Conditions, webserversorgv1alpha1.ConditionDegraded).Message).To(Equal("routing Service: connection refused"))
+}
+
+func TestReconcileStepProgressing(t *testing.T) {
+	g := NewWithT(t)
+	webServer := newConditionsTest()
+	reconciler := newTestReconciler(webServer)
+
+	result, err := reconciler.reconcileStep(context.Background(), webServer, "Certificate issuance", ctrl.Result{Requeue: true}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+
+	stored := storedWebServer(g, reconciler)
+	g.Expect(stored.Status.ObservedGeneration).To(Equal(int64(2)))
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionProgressing, metav1.ConditionTrue, reasonReconciling)
+	g.Expect(meta.FindStatusCondition(stored.Status.Conditions, webserversorgv1alpha1.ConditionProgressing).Message).To(Equal("Waiting for Certificate issuance"))
+	g.Expect(meta.FindStatusCondition(stored.Status.Conditions, webserversorgv1alpha1.ConditionDegraded)).To(BeNil())
+}
+
+func TestInvalidSpec(t *testing.T) {
+	g := NewWithT(t)
+	webServer := newConditionsTest()
+	reconciler := newTestReconciler(webServer)
+
+	result, err := reconciler.invalidSpec(context.Background(), webServer, "WebImageStream or WebImage required")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(reconciler.Recorder.(*record.FakeRecorder).Events).To(Receive(Equal("Warning InvalidSpec WebImageStream or WebImage required")))
+
+	stored := storedWebServer(g, reconciler)
+	g.Expect(stored.Status.ObservedGeneration).To(Equal(int64(2)))
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionDegraded, metav1.ConditionTrue, reasonInvalidSpec)
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionAvailable, metav1.ConditionFalse, reasonInvalidSpec)
+	expectCondition(g, stored, webserversorgv1alpha1.ConditionProgressing, metav1.ConditionFalse, reasonInvalidSpec)
+}
+
+func TestSetFinalConditions(t *testing.T) {
+	tests := []struct {
+		name            string
+		desiredReplicas int32
+		readyReplicas   int32
+		requeue         bool
+		podState        string
+		available       metav1.ConditionStatus
+		progressing     metav1.ConditionStatus
+		degraded        metav1.ConditionStatus
+	}{
+		{"with all the replicas ready", 2, 2, false, webserversorgv1alpha1.PodStateActive, metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionFalse},
+		{"while the replicas start", 2, 1, false, webserversorgv1alpha1.PodStatePending, metav1.ConditionFalse, metav1.ConditionTrue, metav1.ConditionFalse},
+		{"while the replicas scale down", 2, 3, false, webserversorgv1alpha1.PodStateActive, metav1.ConditionTrue, metav1.ConditionTrue, metav1.ConditionFalse},
+		{"while waiting for the pods", 2, 2, true, webserversorgv1alpha1.PodStateActive, metav1.ConditionTrue, metav1.ConditionTrue, metav1.ConditionFalse},
+		{"with a failed pod", 2, 1, false, webserversorgv1alpha1.PodStateFailed, metav1.ConditionFalse, metav1.ConditionTrue, metav1.ConditionTrue},
+		{"scaled up by an autoscaler", 5, 5, false, webserversorgv1alpha1.PodStateActive, metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionFalse},
+		{"scaled down by an autoscaler", 1, 1, false, webserversorgv1alpha1.PodStateActive, metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionFalse},
+		{"while an autoscaler scales up", 5, 3, false, webserversorgv1alpha1.PodStateActive, metav1.ConditionFalse, metav1.ConditionTrue, metav1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webServer := newConditionsTest()
+			webServer.Status.Pods = []webserversorgv1alpha1.PodStatus{{Name: "demo-0", State: tt.podState}}
+			reconciler := &WebServerReconciler{}
+
+			g.Expect(reconciler.setFinalConditions(webServer, tt.desiredReplicas, tt.readyReplicas, tt.requeue)).To(BeTrue())
+			g.Expect(webServer.Status.ObservedGeneration).To(Equal(int64(2)))
+			expectCondition(g, webServer, webserversorgv1alpha1.ConditionReconcileError, metav1.ConditionFalse, reasonReconcileSucceeded)
+			g.Expect(meta.FindStatusCondition(webServer.Status.Conditions, webserversorgv1alpha1.ConditionAvailable).Status).To(Equal(tt.available))
+			g.Expect(meta.FindStatusCondition(webServer.Status.Conditions, webserversorgv1alpha1.ConditionProgressing).Status).To(Equal(tt.progressing))
+			g.Expect(meta.FindStatusCondition(webServer.Status.Conditions, webserversorgv1alpha1.ConditionDegraded).Status).To(Equal(tt.degraded))
+
+			// Nothing changes until the replicas or the pods change
+			g.Expect(reconciler.setFinalConditions(webServer, tt.desiredReplicas, tt.readyReplicas, tt.requeue)).To(BeFalse())
+		})
+	}
+}
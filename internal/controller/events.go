@@ -0,0 +1,48 @@
+package controller
+
+import (
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Reasons used in the Events recorded for a WebServer
+const (
+	eventReasonCreated           = "Created"
+	eventReasonCreateFailed      = "CreateFailed"
+	eventReasonUpdated           = "Updated"
+	eventReasonUpdateFailed      = "UpdateFailed"
+	eventReasonRedeploying       = "Redeploying"
+	eventReasonRebuilding        = "Rebuilding"
+	eventReasonBuildStarted      = "BuildStarted"
+	eventReasonBuildFailed       = "BuildFailed"
+	eventReasonBuildSucceeded    = "BuildSucceeded"
+	eventReasonDNSPing           = "DNSPing"
+	eventReasonDeleted           = "Deleted"
+	eventReasonDeleteFailed      = "DeleteFailed"
+	eventReasonRetained          = "Retained"
+	eventReasonRolloutStarted    = "RolloutStarted"
+	eventReasonRolloutStep       = "RolloutStep"
+	eventReasonRolloutPromoting  = "RolloutPromoting"
+	eventReasonRolloutCompleted  = "RolloutCompleted"
+	eventReasonRolloutAborted    = "RolloutAborted"
+	eventReasonRolledBack        = "RolledBack"
+	eventReasonSecretNotFound    = "SecretNotFound"
+	eventReasonConfigMapNotFound = "ConfigMapNotFound"
+	eventReasonTLSRotated        = "TLSRotated"
+	eventReasonTLSReloaded       = "TLSReloaded"
+	eventReasonTLSReloadFailed   = "TLSReloadFailed"
+)
+
+// recordEvent records a Normal Event for the WebServer, the recorder is optional (unit tests don't set it).
+func (r *WebServerReconciler) recordEvent(webServer *webserversv1alpha1.WebServer, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(webServer, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// recordWarning records a Warning Event for the WebServer.
+func (r *WebServerReconciler) recordWarning(webServer *webserversv1alpha1.WebServer, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(webServer, corev1.EventTypeWarning, reason, message)
+	}
+}
@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// hasGatewayAPI checks if the HTTPRoute kind of the Gateway API is registered in the cluster.
+func hasGatewayAPI(c *rest.Config) bool {
+	return CustomResourceDefinitionExists(httpRouteGVK, c)
+}
+
+// hasTLSRoute checks if the TLSRoute kind of the experimental channel of the Gateway API is registered in the cluster.
+func hasTLSRoute(c *rest.Config) bool {
+	return CustomResourceDefinitionExists(tlsRouteGVK, c)
+}
+
+// exposureType returns how the application is exposed on Kubernetes, LoadBalancer when spec.exposure isn't set.
+func exposureType(webServer *webserversv1alpha1.WebServer) string {
+	if webServer.Spec.Exposure == nil || webServer.Spec.Exposure.Type == "" {
+		return webserversv1alpha1.ExposureLoadBalancer
+	}
+	return webServer.Spec.Exposure.Type
+}
+
+// routingServicePort returns the port of the routing Service: 8443 when the application serves TLS.
+func routingServicePort(webServer *webserversv1alpha1.WebServer) int {
+	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") {
+		return 8443
+	}
+	return 8080
+}
+
+// exposeApplication applies the resource exposing the application outside of a Kubernetes cluster, deletes the
+// resources of the other exposure types and returns the hosts of the application. The hosts stay empty until
+// the load balancer, the ingress controller or the Gateway publishes an address, their status update triggers
+// the next reconciliation.
+func (r *WebServerReconciler) exposeApplication(ctx context.Context, webServer *webserversv1alpha1.WebServer) ([]string, ctrl.Result, error) {
+	exposure := exposureType(webServer)
+	if err := r.deleteUnusedExposure(ctx, webServer, exposure); err != nil {
+		return nil, reconcile.Result{}, err
+	}
+
+	var hosts []string
+	switch exposure {
+	case webserversv1alpha1.ExposureLoadBalancer:
+		loadBalancer := r.generateLoadBalancer(webServer)
+		result, err := r.applyResource(ctx, webServer, loadBalancer)
+		if err != nil || result != (ctrl.Result{}) {
+			return nil, result, err
+		}
+		hosts = loadBalancerHosts(loadBalancer.Status.LoadBalancer.Ingress)
+	case webserversv1alpha1.ExposureNodePort:
+		nodePort := r.generateNodePortService(webServer)
+		result, err := r.applyResource(ctx, webServer, nodePort)
+		if err != nil || result != (ctrl.Result{}) {
+			return nil, result, err
+		}
+		hosts, err = r.nodePortHosts(ctx, nodePort)
+		if err != nil {
+			return nil, reconcile.Result{}, err
+		}
+	case webserversv1alpha1.ExposureIngress:
+		ingress := r.generateIngress(webServer)
+		result, err := r.applyResource(ctx, webServer, ingress)
+		if err != nil || result != (ctrl.Result{}) {
+			return nil, result, err
+		}
+		if webServer.Spec.Exposure.Host != "" {
+			hosts = []string{webServer.Spec.Exposure.Host}
+		} else {
+			for _, ingress := range ingress.Status.LoadBalancer.Ingress {
+				if ingress.Hostname != "" {
+					hosts = append(hosts, ingress.Hostname)
+				} else if ingress.IP != "" {
+					hosts = append(hosts, ingress.IP)
+				}
+			}
+		}
+	case webserversv1alpha1.ExposureGateway:
+		route := r.generateGatewayRoute(webServer)
+		result, err := r.applyResource(ctx, webServer, route)
+		if err != nil || result != (ctrl.Result{}) {
+			return nil, result, err
+		}
+		if webServer.Spec.Exposure.Host != "" {
+			hosts = []string{webServer.Spec.Exposure.Host}
+		} else {
+			hosts = r.gatewayAddresses(ctx, webServer)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, reconcile.Result{}, nil
+}
+
+// deleteUnusedExposure deletes the resources of the exposure types the WebServer doesn't use (anymore).
+func (r *WebServerReconciler) deleteUnusedExposure(ctx context.Context, webServer *webserversv1alpha1.WebServer, exposure string) error {
+	name := webServer.Spec.ApplicationName
+	if exposure != webserversv1alpha1.ExposureLoadBalancer {
+		if err := r.deleteForWebServer(ctx, webServer, &corev1.Service{ObjectMeta: r.generateObjectMeta(webServer, name+"-lb")}, "Service"); err != nil {
+			return err
+		}
+	}
+	if exposure != webserversv1alpha1.ExposureNodePort {
+		if err := r.deleteForWebServer(ctx, webServer, &corev1.Service{ObjectMeta: r.generateObjectMeta(webServer, name+"-nodeport")}, "Service"); err != nil {
+			return err
+		}
+	}
+	if exposure != webserversv1alpha1.ExposureIngress {
+		if err := r.deleteForWebServer(ctx, webServer, &networkingv1.Ingress{ObjectMeta: r.generateObjectMeta(webServer, name)}, "Ingress"); err != nil {
+			return err
+		}
+	}
+	if r.hasGatewayAPI {
+		// Switching TLS on or off replaces the HTTPRoute with a TLSRoute
+		used := schema.GroupVersionKind{}
+		if exposure == webserversv1alpha1.ExposureGateway {
+			used = gatewayRouteGVK(webServer)
+		}
+		gvks := []schema.GroupVersionKind{httpRouteGVK}
+		if r.hasTLSRoute {
+			gvks = append(gvks, tlsRouteGVK)
+		}
+		for _, gvk := range gvks {
+			if gvk == used {
+				continue
+			}
+			route := newUnstructured(gvk)
+			route.SetName(name)
+			route.SetNamespace(webServer.Namespace)
+			if err := r.deleteForWebServer(ctx, webServer, route, gvk.Kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadBalancerHosts returns the hostnames of the load balancer, or its IPs when it has no hostname.
+func loadBalancerHosts(ingresses []corev1.LoadBalancerIngress) []string {
+	hosts := make([]string, 0, len(ingresses))
+	for _, ingress := range ingresses {
+		if ingress.Hostname != "" {
+			hosts = append(hosts, ingress.Hostname)
+		} else if ingress.IP != "" {
+			hosts = append(hosts, ingress.IP)
+		}
+	}
+	log.Info("Status.Hosts number of Ingress: " + strconv.Itoa(len(ingresses)))
+	return hosts
+}
+
+// nodePortHosts returns the address:port of the application on each node, the external IP of the node
+// or its internal IP when it has none.
+func (r *WebServerReconciler) nodePortHosts(ctx context.Context, service *corev1.Service) ([]string, error) {
+	if len(service.Spec.Ports) == 0 || service.Spec.Ports[0].NodePort == 0 {
+		return nil, nil
+	}
+	port := strconv.Itoa(int(service.Spec.Ports[0].NodePort))
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		log.Error(err, "Failed to list the nodes")
+		return nil, err
+	}
+	hosts := []string{}
+	for _, node := range nodes.Items {
+		address := ""
+		for _, nodeAddress := range node.Status.Addresses {
+			if nodeAddress.Type == corev1.NodeExternalIP {
+				address = nodeAddress.Address
+				break
+			}
+			if nodeAddress.Type == corev1.NodeInternalIP && address == "" {
+				address = nodeAddress.Address
+			}
+		}
+		if address != "" {
+			hosts = append(hosts, address+":"+port)
+		}
+	}
+	return hosts, nil
+}
+
+// gatewayAddresses returns the addresses published in the status of the Gateway of the routes. The Gateway
+// may be outside of the namespaces watched by the operator, no address is returned when it can't be read.
+func (r *WebServerReconciler) gatewayAddresses(ctx context.Context, webServer *webserversv1alpha1.WebServer) []string {
+	reference := webServer.Spec.Exposure.Gateway
+	namespace := reference.Namespace
+	if namespace == "" {
+		namespace = webServer.Namespace
+	}
+	gateway := newUnstructured(gatewayGVK)
+	err := r.Get(ctx, types.NamespacedName{Name: reference.Name, Namespace: namespace}, gateway)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Info("Unable to read the addresses of Gateway " + namespace + "/" + reference.Name + ": " + err.Error())
+		}
+		return nil
+	}
+	addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+	hosts := []string{}
+	for _, address := range addresses {
+		if value, ok := address.(map[string]interface{})["value"].(string); ok && value != "" {
+			hosts = append(hosts, value)
+		}
+	}
+	return hosts
+}
+
+// generateNodePortService exposes the application on a port of each node.
+func (r *WebServerReconciler) generateNodePortService(webServer *webserversv1alpha1.WebServer) *corev1.Service {
+	service := r.generateLoadBalancer(webServer)
+	service.Name = webServer.Spec.ApplicationName + "-nodeport"
+	service.Annotations["description"] = "NodePort for application's http service."
+	service.Spec.Type = corev1.ServiceTypeNodePort
+	service.Spec.Ports[0].NodePort = webServer.Spec.Exposure.NodePort
+	return service
+}
+
+// generateIngress routes the traffic of the host to the routing Service, the ingress controller terminates
+// TLS with the TLS secret when it is set.
+func (r *WebServerReconciler) generateIngress(webServer *webserversv1alpha1.WebServer) *networkingv1.Ingress {
+	exposure := webServer.Spec.Exposure
+	pathType := networkingv1.PathTypePrefix
+	objectMeta := r.generateObjectMeta(webServer, webServer.Spec.ApplicationName)
+	objectMeta.Labels = r.generateLabelsForWeb(webServer)
+	objectMeta.Annotations = exposure.Annotations
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: objectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: exposure.IngressClassName,
+			Rules: []networkingv1.IngressRule{{
+				Host: exposure.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: webServer.Spec.ApplicationName,
+									Port: networkingv1.ServiceBackendPort{Number: int32(routingServicePort(webServer))},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if secretName := r.tlsSecretName(webServer); secretName != "" {
+		tls := networkingv1.IngressTLS{SecretName: secretName}
+		if exposure.Host != "" {
+			tls.Hosts = []string{exposure.Host}
+		}
+		ingress.Spec.TLS = []networkingv1.IngressTLS{tls}
+	}
+
+	err := controllerutil.SetControllerReference(webServer, ingress, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return ingress
+}
+
+// gatewayRouteGVK returns the kind of route of the Gateway API: the TLS traffic is passed through to the pods
+// by a TLSRoute, the HTTP traffic is routed by an HTTPRoute.
+func gatewayRouteGVK(webServer *webserversv1alpha1.WebServer) schema.GroupVersionKind {
+	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") {
+		return tlsRouteGVK
+	}
+	return httpRouteGVK
+}
+
+// generateGatewayRoute attaches the routing Service to the Gateway of the WebServer.
+func (r *WebServerReconciler) generateGatewayRoute(webServer *webserversv1alpha1.WebServer) *unstructured.Unstructured {
+	exposure := webServer.Spec.Exposure
+	parentRef := map[string]interface{}{
+		"name": exposure.Gateway.Name,
+	}
+	if exposure.Gateway.Namespace != "" {
+		parentRef["namespace"] = exposure.Gateway.Namespace
+	}
+	if exposure.Gateway.SectionName != "" {
+		parentRef["sectionName"] = exposure.Gateway.SectionName
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules": []interface{}{map[string]interface{}{
+			"backendRefs": []interface{}{map[string]interface{}{
+				"name": webServer.Spec.ApplicationName,
+				"port": int64(routingServicePort(webServer)),
+			}},
+		}},
+	}
+	if exposure.Host != "" {
+		spec["hostnames"] = []interface{}{exposure.Host}
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	route.SetGroupVersionKind(gatewayRouteGVK(webServer))
+	route.SetName(webServer.Spec.ApplicationName)
+	route.SetNamespace(webServer.Namespace)
+	route.SetLabels(r.generateLabelsForWeb(webServer))
+	route.SetAnnotations(exposure.Annotations)
+
+	err := controllerutil.SetControllerReference(webServer, route, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return route
+}
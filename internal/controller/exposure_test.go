@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadBalancerHosts(t *testing.T) {
+	tests := []struct {
+		name      string
+		ingresses []corev1.LoadBalancerIngress
+		hosts     []string
+	}{
+		{"while it is provisioned", nil, []string{}},
+		{"by hostname", []corev1.LoadBalancerIngress{{Hostname: "lb.example.com", IP: "192.0.2.10"}}, []string{"lb.example.com"}},
+		{"by IP", []corev1.LoadBalancerIngress{{IP: "192.0.2.10"}, {IP: "192.0.2.11"}}, []string{"192.0.2.10", "192.0.2.11"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NewWithT(t).Expect(loadBalancerHosts(tt.ingresses)).To(Equal(tt.hosts))
+		})
+	}
+}
+
+func TestNodePortHosts(t *testing.T) {
+	node := func(name string, addresses ...corev1.NodeAddress) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Addresses: addresses},
+		}
+	}
+	reconciler := newTestReconciler(
+		node("external",
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "192.0.2.1"}),
+		node("internal", corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.2"}),
+		node("hostname", corev1.NodeAddress{Type: corev1.NodeHostName, Address: "worker-3"}),
+	)
+
+	t.Run("with the node port", func(t *testing.T) {
+		g := NewWithT(t)
+		service := &corev1.Service{Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080, NodePort: 30080}}}}
+		hosts, err := reconciler.nodePortHosts(context.Background(), service)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hosts).To(ConsistOf("192.0.2.1:30080", "10.0.0.2:30080"))
+	})
+
+	t.Run("until the node port is allocated", func(t *testing.T) {
+		g := NewWithT(t)
+		service := &corev1.Service{Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}}}
+		hosts, err := reconciler.nodePortHosts(context.Background(), service)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(hosts).To(BeEmpty())
+	})
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+
+	imagestreamv1 "github.com/openshift/api/image/v1"
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// webServerFinalizer makes sure the resources not garbage collected are removed before the WebServer
+	webServerFinalizer = "web.servers.org/finalizer"
+)
+
+// addFinalizer adds the finalizer to a WebServer which is not being deleted. The patch only holds
+// metadata.finalizers, the stored spec and the generation are left alone.
+func (r *WebServerReconciler) addFinalizer(ctx context.Context, webServer *webserversv1alpha1.WebServer) error {
+	if controllerutil.ContainsFinalizer(webServer, webServerFinalizer) {
+		return nil
+	}
+	log.Info("Adding the finalizer to WebServer " + webServer.Name)
+	patch := client.MergeFromWithOptions(webServer.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.AddFinalizer(webServer, webServerFinalizer)
+	if err := r.Patch(ctx, webServer, patch); err != nil {
+		log.Error(err, "Failed to add the finalizer to WebServer "+webServer.Name)
+		return err
+	}
+	return nil
+}
+
+// finalizeWebServer removes what the garbage collector doesn't handle (or not deterministically)
+// before releasing the WebServer:
+// the log PersistentVolumeClaim and the StatefulSet PersistentVolumeClaims according to the delete flags,
+// the RoleBinding of the Kubernetes membership provider and the ImageStream built from the sources.
+// Everything the operator creates is in the namespace of the WebServer, the objects of other namespaces it
+// references (the ImageStream of imageStreamNamespace, the Gateway) are only read and left alone.
+func (r *WebServerReconciler) finalizeWebServer(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(webServer, webServerFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	log.Info("Finalizing WebServer " + webServer.Name)
+
+	// Log PersistentVolumeClaim
+	logClaim := &corev1.PersistentVolumeClaim{}
+	logClaim.Name = "volume-pvc-" + webServer.Name
+	logClaim.Namespace = webServer.Namespace
+	if webServer.Spec.PersistentLogsConfig.DeleteLogClaims {
+		if err := r.deleteForWebServer(ctx, webServer, logClaim, "PersistentVolumeClaim"); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if webServer.Spec.PersistentLogsConfig.CatalinaLogs || webServer.Spec.PersistentLogsConfig.AccessLogs {
+		r.recordEvent(webServer, eventReasonRetained, "Keeping the archived logs in PersistentVolumeClaim "+logClaim.Name)
+	}
+
+	// PersistentVolumeClaims created from the VolumeClaimTemplates of the StatefulSet
+	if webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0 {
+		claims := &corev1.PersistentVolumeClaimList{}
+		err := r.List(ctx, claims,
+			client.InNamespace(webServer.Namespace),
+			client.MatchingLabels(r.generateSelectorLabelsForWeb(webServer)),
+		)
+		if err != nil {
+			log.Error(err, "unable to list the PersistentVolumeClaims of the StatefulSet")
+			return ctrl.Result{}, err
+		}
+		for i := range claims.Items {
+			if webServer.Spec.Volume.DeleteCreatedClaims {
+				if err := r.deleteForWebServer(ctx, webServer, &claims.Items[i], "PersistentVolumeClaim"); err != nil {
+					return ctrl.Result{}, err
+				}
+			} else {
+				r.recordEvent(webServer, eventReasonRetained, "Keeping PersistentVolumeClaim "+claims.Items[i].Name)
+			}
+		}
+	}
+
+	// RoleBinding allowing the pods to list the pods for the Kubernetes membership provider
+	roleBinding := &rbac.RoleBinding{}
+	roleBinding.Name = membershipServiceAccountName(webServer)
+	roleBinding.Namespace = webServer.Namespace
+	if err := r.deleteForWebServer(ctx, webServer, roleBinding, "RoleBinding"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ImageStream built from the sources, the ImageStreams referenced by the WebServer belong to the user.
+	if webServer.Spec.WebImageStream != nil && webServer.Spec.WebImageStream.WebSources != nil {
+		imageStream := &imagestreamv1.ImageStream{}
+		imageStream.Name = webServer.Spec.ApplicationName
+		imageStream.Namespace = webServer.Namespace
+		if err := r.deleteForWebServer(ctx, webServer, imageStream, "ImageStream"); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	patch := client.MergeFromWithOptions(webServer.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	controllerutil.RemoveFinalizer(webServer, webServerFinalizer)
+	if err := r.Patch(ctx, webServer, patch); err != nil {
+		if errors.IsConflict(err) {
+			log.V(1).Info(err.Error())
+			return ctrl.Result{Requeue: true}, nil
+		}
+		log.Error(err, "Failed to remove the finalizer from WebServer "+webServer.Name)
+		return ctrl.Result{}, err
+	}
+	log.Info("WebServer " + webServer.Name + " finalized")
+	return ctrl.Result{}, nil
+}
+
+// deleteForWebServer deletes the resource if it is controlled by the WebServer, the resources created by
+// somebody else with the same name are left alone.
+func (r *WebServerReconciler) deleteForWebServer(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource client.Object, kind string) error {
+	return r.deleteForWebServerFrom(ctx, r.Client, webServer, resource, kind)
+}
+
+// deleteForWebServerFrom is deleteForWebServer reading the resource with reader, for the resources which aren't
+// in the cache.
+func (r *WebServerReconciler) deleteForWebServerFrom(ctx context.Context, reader client.Reader, webServer *webserversv1alpha1.WebServer, resource client.Object, kind string) error {
+	err := reader.Get(ctx, client.ObjectKeyFromObject(resource), resource)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Failed to get "+kind+": "+resource.GetName())
+		return err
+	}
+	if owner := metav1.GetControllerOf(resource); owner == nil || owner.UID != webServer.UID {
+		// The StatefulSet claims are owned by nobody but carry the selector labels of the WebServer
+		if kind != "PersistentVolumeClaim" || owner != nil {
+			log.Info(kind + " " + resource.GetName() + " is not controlled by WebServer " + webServer.Name + ", keeping it")
+			return nil
+		}
+	}
+	log.Info("Deleting " + kind + ": " + resource.GetName() + " Namespace: " + resource.GetNamespace())
+	err = r.Delete(ctx, resource)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to delete "+kind+": "+resource.GetName())
+		r.recordWarning(webServer, eventReasonDeleteFailed, "Failed to delete "+kind+" "+resource.GetName()+": "+err.Error())
+		return err
+	}
+	r.recordEvent(webServer, eventReasonDeleted, "Deleted "+kind+" "+resource.GetName())
+	return nil
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestAddFinalizerKeepsSpec(t *testing.T) {
+	g := NewWithT(t)
+	reconciler := newTestReconciler(newConditionsTest())
+	webServer := storedWebServer(g, reconciler)
+
+	// A spec changed in memory isn't written with the finalizer
+	webServer.Spec.Replicas = 5
+	g.Expect(reconciler.addFinalizer(context.Background(), webServer)).To(Succeed())
+
+	stored := storedWebServer(g, reconciler)
+	g.Expect(controllerutil.ContainsFinalizer(stored, webServerFinalizer)).To(BeTrue())
+	g.Expect(stored.Spec.Replicas).To(Equal(int32(2)))
+	g.Expect(stored.Generation).To(Equal(int64(2)))
+}
@@ -7,18 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
-	"time"
 
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
 
 	buildv1 "github.com/openshift/api/build/v1"
 	imagestreamv1 "github.com/openshift/api/image/v1"
-	routev1 "github.com/openshift/api/route/v1"
 	kbappsv1 "k8s.io/api/apps/v1"
 
 	corev1 "k8s.io/api/core/v1"
-	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -35,30 +32,6 @@ const (
 	ownerUIDIndex = ".metadata.ownerReference.uid"
 )
 
-func isOpenShift(c *rest.Config) bool {
-	var err error
-	var dcclient *discovery.DiscoveryClient
-	dcclient, err = discovery.NewDiscoveryClientForConfig(c)
-	if err != nil {
-		log.Info("isOpenShift discovery.NewDiscoveryClientForConfig has encountered a problem")
-		return false
-	}
-	apiList, err := dcclient.ServerGroups()
-	if err != nil {
-		log.Info("isOpenShift client.ServerGroups has encountered a problem")
-		return false
-	}
-	for _, v := range apiList.Groups {
-		log.Info(v.Name)
-		if v.Name == "route.openshift.io" {
-
-			log.Info("route.openshift.io was found in apis, platform is OpenShift")
-			return true
-		}
-	}
-	return false
-}
-
 func (r *WebServerReconciler) getWebServer(ctx context.Context, request reconcile.Request) (*webserversv1alpha1.WebServer, error) {
 	webServer := &webserversv1alpha1.WebServer{}
 	err := r.Get(ctx, request.NamespacedName, webServer)
@@ -83,7 +56,7 @@ func (r *WebServerReconciler) setDefaultValues(webServer *webserversv1alpha1.Web
 		webApp := webServer.Spec.WebImage.WebApp
 		if webApp.Name == "" {
 			log.Info("WebServer.Spec.Image.WebApp.Name is not set, setting value to 'ROOT.war'")
-			webApp.Name = "ROOT.war"
+			webApp.Name = webserversv1alpha1.DefaultWebAppName
 		}
 		if webApp.Builder.ApplicationBuildScript == "" {
 			log.Info("WebServer.Spec.Image.WebApp.Builder.ApplicationBuildScript is not set, will use the default build script")
@@ -98,57 +71,15 @@ func (r *WebServerReconciler) setDefaultValues(webServer *webserversv1alpha1.Web
 }
 
 func (r *WebServerReconciler) webImageConfiguration(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
-	var result ctrl.Result
-	var err error
-
 	// Check if a webapp needs to be built
-	if webServer.Spec.WebImage.WebApp != nil && webServer.Spec.WebImage.WebApp.SourceRepositoryURL != "" && webServer.Spec.WebImage.WebApp.Builder != nil && webServer.Spec.WebImage.WebApp.Builder.Image != "" {
-
-		// Create a ConfigMap for custom build script
-		if webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" {
-			configMap := r.generateConfigMapForCustomBuildScript(webServer)
-			result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
-			if err != nil || result != (ctrl.Result{}) {
-				return result, err
-			}
-			// Check the script has changed, if yes delete it and requeue
-			if configMap.Data["build.sh"] != webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript {
-				// Just Delete and requeue
-				err = r.Delete(ctx, configMap)
-				if err != nil && errors.IsNotFound(err) {
-					return ctrl.Result{}, nil
-				}
-				log.Info("Webserver hash changed: Delete Builder ConfigMap and requeue reconciliation")
-				return ctrl.Result{RequeueAfter: (500 * time.Millisecond)}, nil
-			}
+	if buildsWebApp(webServer) {
+		if buildStrategy(webServer) == webserversv1alpha1.BuildStrategyShipwright && !r.hasShipwright {
+			return r.invalidSpec(ctx, webServer, "Shipwright isn't installed in the cluster, the Shipwright build strategy can't be used")
 		}
-
-		// Check if a build Pod for the webapp already exists, and if not create a new one
-		buildPod := r.generateBuildPod(webServer)
-		log.Info("WebServe createBuildPod: " + buildPod.Name + " in " + buildPod.Namespace + " using: " + buildPod.Spec.Volumes[0].Secret.SecretName + " and: " + buildPod.Spec.Containers[0].Image)
-		result, err = r.createBuildPod(ctx, buildPod, buildPod.Name, buildPod.Namespace)
-		if err != nil || result != (ctrl.Result{}) {
+		built, result, err := r.buildWebApp(ctx, webServer)
+		if err != nil || result != (ctrl.Result{}) || !built {
 			return result, err
 		}
-
-		// Check if we need to delete it and recreate it.
-		currentHash := r.getWebServerHash(webServer)
-		if buildPod.Labels["webserver-hash"] != currentHash {
-			// Just Delete and requeue
-			err = r.Delete(ctx, buildPod)
-			if err != nil && errors.IsNotFound(err) {
-				return ctrl.Result{}, nil
-			}
-			log.Info("Webserver hash changed: Delete BuildPod and requeue reconciliation")
-			return ctrl.Result{RequeueAfter: (500 * time.Millisecond)}, nil
-		}
-
-		// Is the build pod ready.
-		result = r.checkBuildPodPhase(buildPod)
-		if result != (ctrl.Result{}) {
-			return result, nil
-		}
-
 	}
 
 	applicationImage := webServer.Spec.WebImage.ApplicationImage
@@ -159,156 +90,164 @@ func (r *WebServerReconciler) webImageConfiguration(ctx context.Context, webServ
 
 	if webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0 {
 		return r.continueWithStatefulSet(ctx, webServer, applicationImage)
+	} else if useRollout(webServer) {
+		return r.continueWithRollout(ctx, webServer, applicationImage)
 	} else {
+		if webServer.Status.Rollout != nil {
+			// The rollout was disabled, the Deployment of the application takes all the traffic again
+			webServer.Status.Rollout = nil
+			if err := r.updateStatus(ctx, webServer); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
 		return r.continueWithDeployment(ctx, webServer, applicationImage)
 	}
 }
 
 func (r *WebServerReconciler) continueWithDeployment(ctx context.Context, webServer *webserversv1alpha1.WebServer, image string) (ctrl.Result, error) {
-	updateDeployment := false
-
-	// Check if a Deployment already exists, and if not create a new one
 	deployment := r.generateDeployment(webServer, image)
-	log.Info("WebServe createDeployment: " + deployment.Name + " in " + deployment.Namespace + " using: " + deployment.Spec.Template.Spec.Containers[0].Image)
-	result, err := r.createDeployment(ctx, webServer, deployment, deployment.Name, deployment.Namespace)
-	if err != nil || result != (ctrl.Result{}) {
-		if err != nil {
-			log.Info("WebServer can't create deployment")
-		}
-		return result, err
-	}
+	deployment.Labels["webserver-hash"] = r.getWebServerHash(webServer)
+	return r.applyDeployment(ctx, webServer, deployment)
+}
 
-	// Check if we need to update it.
-	currentHash := r.getWebServerHash(webServer)
-	if deployment.Labels["webserver-hash"] == "" {
-		deployment.Labels["webserver-hash"] = currentHash
-		updateDeployment = true
-	} else {
-		if deployment.Labels["webserver-hash"] != currentHash {
-			// Just Update and requeue
-			r.generateUpdatedDeployment(webServer, deployment, image)
-			deployment.Labels["webserver-hash"] = currentHash
-			err = r.Update(ctx, deployment)
-			if err != nil {
-				log.Error(err, "Failed to update Deployment.", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
-				if errors.IsConflict(err) {
-					log.V(1).Info(err.Error())
-				} else {
-					return ctrl.Result{}, nil
-				}
-			}
-			log.Info("Webserver hash changed: Update Deployment and requeue reconciliation")
-			return ctrl.Result{RequeueAfter: (500 * time.Millisecond)}, nil
-		}
-	}
+// applyDeployment applies the generated Deployment, on success deployment contains the state returned by the API server.
+func (r *WebServerReconciler) applyDeployment(ctx context.Context, webServer *webserversv1alpha1.WebServer, deployment *kbappsv1.Deployment) (ctrl.Result, error) {
+	log.Info("WebServe applyDeployment: " + deployment.Name + " in " + deployment.Namespace + " using: " + deployment.Spec.Template.Spec.Containers[0].Image)
 
-	foundImage := deployment.Spec.Template.Spec.Containers[0].Image
-	if webServer.Spec.WebImage != nil && webServer.Spec.WebImage.ApplicationImage != "" && webServer.Spec.WebImage.ApplicationImage != foundImage {
-		// if we are using a builder that it normal otherwise we need to redeploy.
-		if webServer.Spec.WebImage.WebApp == nil {
-			log.Info("WebServer application image change detected. Deployment update scheduled")
-			deployment.Spec.Template.Spec.Containers[0].Image = webServer.Spec.WebImage.ApplicationImage
-			updateDeployment = true
-		}
+	found := &kbappsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Deployment: "+deployment.Name)
+		return reconcile.Result{}, err
 	}
+	created := errors.IsNotFound(err)
 
-	// Handle Scaling
-	foundReplicas := *deployment.Spec.Replicas
-	replicas := webServer.Spec.Replicas
-	if foundReplicas != replicas {
-		log.Info("Deployment replicas number does not match the WebServer specification. Deployment update scheduled")
-		deployment.Spec.Replicas = &replicas
-		updateDeployment = true
+	if err := r.setTLSSecretHash(ctx, webServer, &deployment.Spec.Template); err != nil {
+		return reconcile.Result{}, err
 	}
-
-	if updateDeployment {
-		err = r.Update(ctx, deployment)
+	if err := r.setTomcatConfigHash(ctx, webServer, &deployment.Spec.Template); err != nil {
+		return reconcile.Result{}, err
+	}
+	if !created {
+		autoscaled, err := r.replicasManagedByAutoscaler(ctx, "Deployment", found)
 		if err != nil {
-			log.Error(err, "Failed to update Deployment.", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
-			if errors.IsConflict(err) {
-				log.V(1).Info(err.Error())
-			} else {
-				return ctrl.Result{}, err
+			return reconcile.Result{}, err
+		}
+		if autoscaled {
+			log.Info("Deployment replicas are managed by a HorizontalPodAutoscaler, leaving them alone")
+			deployment.Spec.Replicas = nil
+		}
+		if webServer.Spec.WebImage == nil {
+			// The image is set by the image trigger of the ImageStream
+			keepContainerImage(&deployment.Spec.Template, &found.Spec.Template, webServer.Spec.ApplicationName)
+		}
+		setTLSRotationStrategy(deployment, found)
+		if deployment.Spec.Strategy.Type == kbappsv1.RecreateDeploymentStrategyType && found.Spec.Strategy.RollingUpdate != nil {
+			// The rollingUpdate defaulted by the API server isn't owned by the operator, the apply can't remove it
+			// and a Recreate Deployment with a rollingUpdate is invalid.
+			patch := client.MergeFrom(found.DeepCopy())
+			found.Spec.Strategy = deployment.Spec.Strategy
+			if err := r.Patch(ctx, found, patch); err != nil {
+				log.Error(err, "Failed to switch the Deployment "+deployment.Name+" to the Recreate strategy")
+				return reconcile.Result{}, err
 			}
 		}
-		// Spec updated - return and requeue
-		return ctrl.Result{Requeue: true}, nil
+		if deployment.Name == webServer.Spec.ApplicationName {
+			if err := r.keepRolledBackTemplate(ctx, webServer, &deployment.Spec.Template, found); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		if found.Labels["webserver-hash"] != deployment.Labels["webserver-hash"] {
+			log.Info("Webserver hash changed: Update Deployment")
+			r.recordEvent(webServer, eventReasonRedeploying, "WebServer changed, redeploying Deployment "+deployment.Name)
+		} else if tlsRotation(&found.Spec.Template, &deployment.Spec.Template) {
+			log.Info("TLS Secret changed: Update Deployment")
+			r.recordEvent(webServer, eventReasonTLSRotated, "TLS Secret changed, replacing the pods of Deployment "+deployment.Name)
+		}
+	} else {
+		log.Info("Creating a new Deployment: " + deployment.Name + " Namespace: " + deployment.Namespace)
 	}
 
-	return result, err
+	err = r.patchApply(ctx, deployment)
+	if err != nil {
+		log.Error(err, "Failed to apply Deployment.", "Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
+		if created {
+			r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create Deployment "+deployment.Name+": "+err.Error())
+		} else {
+			r.recordWarning(webServer, eventReasonUpdateFailed, "Failed to update Deployment "+deployment.Name+": "+err.Error())
+		}
+		return reconcile.Result{}, err
+	}
+	if created {
+		// Resource created successfully - return and requeue
+		r.recordEvent(webServer, eventReasonCreated, "Created Deployment "+deployment.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return reconcile.Result{}, nil
 }
 
 func (r *WebServerReconciler) continueWithStatefulSet(ctx context.Context, webServer *webserversv1alpha1.WebServer, image string) (ctrl.Result, error) {
-	updateStatefulSet := false
-
 	statefulset := r.generateStatefulSet(webServer, image)
-	log.Info("WebServe createStatefulSet: " + statefulset.Name + " in " + statefulset.Namespace + " using: " + statefulset.Spec.Template.Spec.Containers[0].Image)
-	result, err := r.createStatefulSet(ctx, webServer, statefulset)
-	if err != nil || result != (ctrl.Result{}) {
-		if err != nil {
-			log.Info("WebServer can't create deployment")
-		}
-		return result, err
-	}
+	statefulset.Labels["webserver-hash"] = r.getWebServerHash(webServer)
+	log.Info("WebServe applyStatefulSet: " + statefulset.Name + " in " + statefulset.Namespace + " using: " + statefulset.Spec.Template.Spec.Containers[0].Image)
 
-	// Check if we need to update it.
-	currentHash := r.getWebServerHash(webServer)
-	if statefulset.Labels["webserver-hash"] == "" {
-		statefulset.Labels["webserver-hash"] = currentHash
-		updateStatefulSet = true
-	} else {
-		if statefulset.Labels["webserver-hash"] != currentHash {
-			// Just Update and requeue
-			r.generateUpdatedStatefulSet(webServer, statefulset, image)
-			statefulset.Labels["webserver-hash"] = currentHash
-			err = r.Update(ctx, statefulset)
-			if err != nil {
-				log.Error(err, "Failed to update:", "Namespace", statefulset.Namespace, "Name", statefulset.Name)
-				if errors.IsConflict(err) {
-					log.V(1).Info(err.Error())
-				} else {
-					return ctrl.Result{}, nil
-				}
-			}
-			log.Info("Webserver hash changed: Update Deployment and requeue reconciliation")
-			return ctrl.Result{RequeueAfter: (500 * time.Millisecond)}, nil
-		}
+	found := &kbappsv1.StatefulSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: statefulset.Name, Namespace: statefulset.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get StatefulSet: "+statefulset.Name)
+		return reconcile.Result{}, err
 	}
+	created := errors.IsNotFound(err)
 
-	foundImage := statefulset.Spec.Template.Spec.Containers[0].Image
-	if webServer.Spec.WebImage.ApplicationImage != "" && webServer.Spec.WebImage.ApplicationImage != foundImage {
-		// if we are using a builder that it normal otherwise we need to redeploy.
-		if webServer.Spec.WebImage.WebApp == nil {
-			log.Info("WebServer application image change detected. Deployment update scheduled")
-			statefulset.Spec.Template.Spec.Containers[0].Image = webServer.Spec.WebImage.ApplicationImage
-			updateStatefulSet = true
-		}
+	if err := r.setTLSSecretHash(ctx, webServer, &statefulset.Spec.Template); err != nil {
+		return reconcile.Result{}, err
 	}
-
-	// Handle Scaling
-	foundReplicas := *statefulset.Spec.Replicas
-	replicas := webServer.Spec.Replicas
-	if foundReplicas != replicas {
-		log.Info("Deployment replicas number does not match the WebServer specification. Deployment update scheduled")
-		statefulset.Spec.Replicas = &replicas
-		updateStatefulSet = true
+	if err := r.setTomcatConfigHash(ctx, webServer, &statefulset.Spec.Template); err != nil {
+		return reconcile.Result{}, err
 	}
-
-	if updateStatefulSet {
-		err = r.Update(ctx, statefulset)
+	if !created {
+		autoscaled, err := r.replicasManagedByAutoscaler(ctx, "StatefulSet", found)
 		if err != nil {
-			log.Error(err, "Failed to update:", "Namespace", statefulset.Namespace, "Name", statefulset.Name)
-			if errors.IsConflict(err) {
-				log.V(1).Info(err.Error())
-			} else {
-				return ctrl.Result{}, err
-			}
+			return reconcile.Result{}, err
 		}
-		// Spec updated - return and requeue
-		return ctrl.Result{Requeue: true}, nil
+		if autoscaled {
+			log.Info("StatefulSet replicas are managed by a HorizontalPodAutoscaler, leaving them alone")
+			statefulset.Spec.Replicas = nil
+		}
+		if webServer.Spec.WebImage == nil {
+			// The image is set by the image trigger of the ImageStream
+			keepContainerImage(&statefulset.Spec.Template, &found.Spec.Template, webServer.Spec.ApplicationName)
+		}
+		if err := r.keepRolledBackTemplate(ctx, webServer, &statefulset.Spec.Template, found); err != nil {
+			return reconcile.Result{}, err
+		}
+		if found.Labels["webserver-hash"] != statefulset.Labels["webserver-hash"] {
+			log.Info("Webserver hash changed: Update StatefulSet")
+			r.recordEvent(webServer, eventReasonRedeploying, "WebServer changed, redeploying StatefulSet "+statefulset.Name)
+		} else if tlsRotation(&found.Spec.Template, &statefulset.Spec.Template) {
+			log.Info("TLS Secret changed: Update StatefulSet")
+			r.recordEvent(webServer, eventReasonTLSRotated, "TLS Secret changed, replacing the pods of StatefulSet "+statefulset.Name)
+		}
+	} else {
+		log.Info("Creating a new StatefulSet: " + statefulset.Name + " Namespace: " + statefulset.Namespace)
 	}
 
-	return result, err
+	err = r.patchApply(ctx, statefulset)
+	if err != nil {
+		log.Error(err, "Failed to apply StatefulSet.", "Namespace", statefulset.Namespace, "Name", statefulset.Name)
+		if created {
+			r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create StatefulSet "+statefulset.Name+": "+err.Error())
+		} else {
+			r.recordWarning(webServer, eventReasonUpdateFailed, "Failed to update StatefulSet "+statefulset.Name+": "+err.Error())
+		}
+		return reconcile.Result{}, err
+	}
+	if created {
+		// Resource created successfully - return and requeue
+		r.recordEvent(webServer, eventReasonCreated, "Created StatefulSet "+statefulset.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return reconcile.Result{}, nil
 }
 
 //nolint:gocyclo
@@ -324,7 +263,7 @@ func (r *WebServerReconciler) webImageSourceConfiguration(ctx context.Context, w
 
 		// Check if an Image Stream already exists, and if not create a new one
 		imageStream := r.generateImageStream(webServer)
-		result, err = r.createImageStream(ctx, imageStream, imageStream.Name, imageStream.Namespace)
+		result, err = r.applyResource(ctx, webServer, imageStream)
 		if err != nil || result != (ctrl.Result{}) {
 			return result, err
 		}
@@ -349,7 +288,7 @@ func (r *WebServerReconciler) webImageSourceConfiguration(ctx context.Context, w
 
 		// Check if a BuildConfig already exists, and if not create a new one
 		buildConfig := r.generateBuildConfig(webServer)
-		result, err = r.createUpdateBuildConfig(ctx, buildConfig, buildConfig.Name, buildConfig.Namespace)
+		result, err = r.applyBuildConfig(ctx, webServer, buildConfig)
 		if err != nil || result != (ctrl.Result{}) {
 			return result, err
 		}
@@ -388,179 +327,7 @@ func (r *WebServerReconciler) webImageSourceConfiguration(ctx context.Context, w
 	}
 }
 
-func (r *WebServerReconciler) useSessionClusteringConfig(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
-	result := ctrl.Result{}
-	var err error = nil
-
-	if r.needgetUseKUBEPing(webServer) {
-		// Check if a RoleBinding for the KUBEPing exists, and if not create one.
-		rolename := "view-kubeping-" + webServer.Name
-		rolebinding := r.generateRoleBinding(webServer, rolename)
-		//
-		// The example in docs seems to use view (name: view and roleRef ClusterRole/view for our ServiceAccount)
-		// like:
-		// oc policy add-role-to-user view system:serviceaccount:tomcat-in-the-cloud:default -n tomcat-in-the-cloud
-		useKUBEPing, update, err := r.createRoleBinding(ctx, webServer, rolebinding, rolename, rolebinding.Namespace)
-		if err != nil {
-			return reconcile.Result{}, err
-		}
-		if !useKUBEPing {
-			// Update the webServer annotation to prevent retrying
-			log.Info("Won't use KUBEPing missing view permissions")
-		} else {
-			log.Info("Will use KUBEPing")
-			if update {
-				// We have created the Role Binding
-				log.Info("We have created the Role Binding")
-				return ctrl.Result{Requeue: update}, nil
-			}
-		}
-		update, err = r.setUseKUBEPing(ctx, webServer, useKUBEPing)
-		if err != nil {
-			log.Error(err, "Failed to add a new Annotations")
-			return reconcile.Result{}, err
-		} else {
-			if update {
-				log.Info("Add a new Annotations or need UPDATE")
-				return ctrl.Result{Requeue: update}, nil
-			}
-		}
-	}
-	if !r.getUseKUBEPing(webServer) {
-
-		// Check if a Service for DNSPing already exists, and if not create a new one
-		dnsService := r.generateServiceForDNS(webServer)
-		result, err = r.createService(ctx, dnsService, dnsService.Name, dnsService.Namespace)
-		if err != nil || result != (ctrl.Result{}) {
-			return result, err
-		}
-	}
-
-	return result, err
-}
-
-func (r *WebServerReconciler) createService(ctx context.Context, resource *corev1.Service, resourceName, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: resourceNamespace,
-		Name:      resourceName,
-	}, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new Service: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new Service: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Service: "+resourceName)
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, err
-}
-
-// Look for a rolebinding that allows KUBEPing
-
-func (r *WebServerReconciler) checkRoleBinding(ctx context.Context, webServer *webserversv1alpha1.WebServer) bool {
-	roleBindingList := &rbac.RoleBindingList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(webServer.Namespace),
-	}
-	err := r.List(ctx, roleBindingList, listOpts...)
-	if err != nil {
-		log.Error(err, "checkRoleBinding")
-		return false
-	}
-	var roleBindings = roleBindingList.Items
-	for _, rolebinding := range roleBindings {
-		// Look for ServiceAccount / default in subjects:
-		for _, subject := range rolebinding.Subjects {
-			if subject.Kind == "ServiceAccount" && subject.Name == "default" && subject.Namespace == webServer.Namespace {
-				// now check the roleRef for ClusterRole/view
-				if rolebinding.RoleRef.Kind == "ClusterRole" && rolebinding.RoleRef.Name == "view" {
-					log.Info("checkRoleBinding bingo: " + rolebinding.Name + " should allow KUBEPing")
-					if rolebinding.Name == "view-kubeping-"+webServer.Name {
-						// We already created it for this webserver
-						return true
-					}
-					if !strings.HasPrefix(rolebinding.Name, "view-kubeping-") {
-						// it was create by the admin via oc command something like view-nn or by hands.
-						return true
-					}
-					// Here was created for another webserver, the operator will create one for this webserver
-					// remember the RoleBinding is removed if the webserver is deleted.
-					// Note that removing it when the cluster is using KUBEPing will cause 403 in the tomcat pods.
-				}
-			}
-		}
-	}
-	return false
-}
-
-// Test for the "view" RoleBinding and if not existing try to create it, if that fails we can't use useKUBEPing
-// first bool = Role Binding exist
-// second bool = We need to requeue or not...
-//
-//nolint:unparam
-func (r *WebServerReconciler) createRoleBinding(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource *rbac.RoleBinding, resourceName string, resourceNamespace string) (bool, bool, error) {
-	// First try to check if there is a roleBinding that allows KUBEPing
-	checked := r.checkRoleBinding(ctx, webServer)
-	if checked {
-		return true, false, nil
-	}
-
-	// Then try to create it.
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: resourceNamespace,
-		Name:      resourceName,
-	}, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new RoleBinding: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			if errors.IsForbidden(err) {
-				log.Info("No permission to create a new RoleBinding: " + resourceName + " Namespace: " + resourceNamespace)
-				return false, false, nil
-			} else {
-				log.Error(err, "Failed to create a new RoleBinding: "+resourceName+" Namespace: "+resourceNamespace)
-				return false, false, err
-			}
-		}
-		// Resource created successfully - return and requeue
-		// return true, true, nil
-		return true, false, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get RoleBinding "+resourceName)
-		return false, false, err
-	}
-	return true, false, nil
-}
-
-func (r *WebServerReconciler) createConfigMap(ctx context.Context, resource *corev1.ConfigMap, resourceName, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Update(ctx, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new ConfigMap: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new ConfigMap: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get ConfigMap "+resourceName)
-		return reconcile.Result{}, err
-	}
-
-	log.Info("ConfigMap updated")
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createPersistentVolumeClaim(ctx context.Context, resource *corev1.PersistentVolumeClaim, resourceName, resourceNamespace string) (ctrl.Result, error) {
+func (r *WebServerReconciler) createPersistentVolumeClaim(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource *corev1.PersistentVolumeClaim, resourceName, resourceNamespace string) (ctrl.Result, error) {
 	err := r.Get(ctx, client.ObjectKey{
 		Namespace: resourceNamespace,
 		Name:      resourceName,
@@ -571,9 +338,11 @@ func (r *WebServerReconciler) createPersistentVolumeClaim(ctx context.Context, r
 		err = r.Create(ctx, resource)
 		if err != nil && !errors.IsAlreadyExists(err) {
 			log.Error(err, "Failed to create a new PersistentVolumeClaim: "+resourceName+" Namespace: "+resourceNamespace)
+			r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create PersistentVolumeClaim "+resourceName+": "+err.Error())
 			return reconcile.Result{}, err
 		}
 		// Resource created successfully - return and requeue
+		r.recordEvent(webServer, eventReasonCreated, "Created PersistentVolumeClaim "+resourceName)
 		return ctrl.Result{Requeue: true}, nil
 	} else if err != nil {
 		log.Error(err, "Failed to get PersistentVolumeClaim "+resourceName)
@@ -582,179 +351,48 @@ func (r *WebServerReconciler) createPersistentVolumeClaim(ctx context.Context, r
 	return reconcile.Result{}, err
 }
 
-func (r *WebServerReconciler) createBuildPod(ctx context.Context, resource *corev1.Pod, resourceName, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: resourceNamespace,
-		Name:      resourceName,
-	}, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new Build Pod: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new Pod: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		log.Info("Created new Build Pod: " + resourceName + " Namespace: " + resourceNamespace)
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Build Pod: "+resourceName)
-		return reconcile.Result{}, err
-	}
-	log.Info("Have Build Pod: " + resourceName + " Namespace: " + resourceNamespace)
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createDeployment(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource *kbappsv1.Deployment, resourceName string, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: resourceNamespace}, resource)
-
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new Deployment: " + resourceName + " Namespace: " + resourceNamespace)
-		resource.Labels["webserver-hash"] = r.getWebServerHash(webServer)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new Deployment: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Deployment: "+resourceName)
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createStatefulSet(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource *kbappsv1.StatefulSet) (ctrl.Result, error) {
-	name := resource.Name
-	namespace := resource.Namespace
-
-	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, resource)
-
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new StatefulSet: " + name + " Namespace: " + namespace)
-		resource.Labels["webserver-hash"] = r.getWebServerHash(webServer)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new StatefulSet: "+name+" Namespace: "+namespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get StatefulSet: "+name)
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createImageStream(ctx context.Context, resource *imagestreamv1.ImageStream, resourceName, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: resourceNamespace,
-		Name:      resourceName,
-	}, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new ImageStream: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new ImageStream: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get ImageStream: "+resourceName)
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createUpdateBuildConfig(ctx context.Context, resource *buildv1.BuildConfig, resourceName, resourceNamespace string) (ctrl.Result, error) {
+// applyBuildConfig applies the BuildConfig and starts a new build when the sources have changed.
+func (r *WebServerReconciler) applyBuildConfig(ctx context.Context, webServer *webserversv1alpha1.WebServer, resource *buildv1.BuildConfig) (ctrl.Result, error) {
+	resourceName := resource.Name
+	resourceNamespace := resource.Namespace
 	originalBuildConfig := &buildv1.BuildConfig{}
 
 	err := r.Get(ctx, client.ObjectKey{
 		Namespace: resourceNamespace,
 		Name:      resourceName,
 	}, originalBuildConfig)
-
 	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new BuildConfig: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new BuildConfig: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
+		// The new BuildConfig is created by applyResource
+		return r.applyResource(ctx, webServer, resource)
 	} else if err != nil {
 		log.Error(err, "Failed to get BuildConfig: "+resourceName)
 		return reconcile.Result{}, err
 	}
 
-	if originalBuildConfig.Labels["web-image-source-hash"] != resource.Labels["web-image-source-hash"] {
-		resource.ResourceVersion = originalBuildConfig.ResourceVersion
-		err = r.Update(ctx, resource)
+	err = r.patchApply(ctx, resource)
+	if err != nil {
+		log.Error(err, "Failed to apply BuildConfig: "+resourceName+" Namespace: "+resourceNamespace)
+		r.recordWarning(webServer, eventReasonUpdateFailed, "Failed to update BuildConfig "+resourceName+": "+err.Error())
+		return reconcile.Result{}, err
+	}
 
-		if err != nil {
-			return reconcile.Result{}, err
-		}
+	if originalBuildConfig.Labels["web-image-source-hash"] != resource.Labels["web-image-source-hash"] {
+		r.recordEvent(webServer, eventReasonUpdated, "Updated BuildConfig "+resourceName)
 
 		newBuild, err := r.BuildClient.BuildV1().BuildConfigs(resourceNamespace).
 			Instantiate(ctx, resourceName, &buildv1.BuildRequest{ObjectMeta: metav1.ObjectMeta{Name: resourceName}}, metav1.CreateOptions{})
 
 		if err != nil {
 			log.Error(err, "Failed to trigger build")
+			r.recordWarning(webServer, eventReasonBuildFailed, "Failed to start a build of "+resourceName+": "+err.Error())
 			return ctrl.Result{}, err
 		}
 
 		log.Info("Build started", "BuildName", newBuild.Name)
+		r.recordEvent(webServer, eventReasonBuildStarted, "Started build "+newBuild.Name)
 	}
 
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) createRoute(ctx context.Context, resource *routev1.Route, resourceName, resourceNamespace string) (ctrl.Result, error) {
-	err := r.Get(ctx, client.ObjectKey{
-		Namespace: resourceNamespace,
-		Name:      resourceName,
-	}, resource)
-	if err != nil && errors.IsNotFound(err) {
-		// Create a new resource
-		log.Info("Creating a new Route: " + resourceName + " Namespace: " + resourceNamespace)
-		err = r.Create(ctx, resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create a new Route: "+resourceName+" Namespace: "+resourceNamespace)
-			return reconcile.Result{}, err
-		}
-		// Resource created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Route: "+resourceName)
-		return reconcile.Result{}, err
-	}
-	return reconcile.Result{}, err
-}
-
-func (r *WebServerReconciler) checkBuildPodPhase(buildPod *corev1.Pod) reconcile.Result {
-	if buildPod.Status.Phase != corev1.PodSucceeded {
-		switch buildPod.Status.Phase {
-		case corev1.PodFailed:
-			log.Info("Application build failed: " + buildPod.Status.Message)
-		case corev1.PodPending:
-			log.Info("Application build pending")
-		case corev1.PodRunning:
-			log.Info("Application is still being built")
-		default:
-			log.Info("Unknown build pod status")
-		}
-		return reconcile.Result{RequeueAfter: (5 * time.Second)}
-	}
-	return reconcile.Result{}
+	return reconcile.Result{}, nil
 }
 
 // getPodList lists pods which belongs to the Web server
@@ -859,7 +497,7 @@ func (r *WebServerReconciler) getPodStatus(pods []corev1.Pod) ([]webserversv1alp
 		}
 	}
 	if requeue {
-		log.Info("Some pods don't have an IP address yet, reconciliation requeue scheduled")
+		log.Info("Some pods don't have an IP address yet")
 	}
 	return podStatuses, requeue
 }
@@ -874,6 +512,87 @@ func (r *WebServerReconciler) getWebImageStreamHash(webServer *webserversv1alpha
 	}
 	h.Write(data)
 
+	if webServer.Spec.SessionClustering != nil {
+		data, err = json.Marshal(webServer.Spec.SessionClustering)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionClustering")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionStore != nil {
+		data, err = json.Marshal(webServer.Spec.SessionStore)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionStore")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.Connectors != nil {
+		data, err = json.Marshal(webServer.Spec.Connectors)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - Connectors")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionClustering != nil {
+		data, err = json.Marshal(webServer.Spec.SessionClustering)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionClustering")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionStore != nil {
+		data, err = json.Marshal(webServer.Spec.SessionStore)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionStore")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionStore != nil {
+		data, err = json.Marshal(webServer.Spec.SessionStore)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionStore")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.Connectors != nil {
+		data, err = json.Marshal(webServer.Spec.Connectors)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - Connectors")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionClustering != nil {
+		data, err = json.Marshal(webServer.Spec.SessionClustering)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionClustering")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionStore != nil {
+		data, err = json.Marshal(webServer.Spec.SessionStore)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionStore")
+			return ""
+		}
+		h.Write(data)
+	}
+
 	/* rules for labels: '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"} */
 	enc := base64.NewEncoding("qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM_.0123456789")
 	enc = enc.WithPadding(base64.NoPadding)
@@ -948,7 +667,13 @@ func (r *WebServerReconciler) getWebServerHash(webServer *webserversv1alpha1.Web
 	}
 	h.Write(data)
 
-	data, err = json.Marshal(webServer.Spec.SecurityContext)
+	// The default SecurityContext is hashed like no SecurityContext, the pods deployed before the defaulting
+	// webhook persisted it aren't replaced when the operator is upgraded
+	securityContext := webServer.Spec.SecurityContext
+	if equality.Semantic.DeepEqual(securityContext, webserversv1alpha1.DefaultSecurityContext()) {
+		securityContext = nil
+	}
+	data, err = json.Marshal(securityContext)
 	if err != nil {
 		log.Error(err, "WebServer hash sum calculation failed - SecurityContext")
 		return ""
@@ -962,96 +687,86 @@ func (r *WebServerReconciler) getWebServerHash(webServer *webserversv1alpha1.Web
 	}
 	h.Write(data)
 
+	// The fields added since are only hashed when set, the existing pods aren't replaced when the operator is upgraded
+	if webServer.Spec.TomcatConfig != nil {
+		data, err = json.Marshal(webServer.Spec.TomcatConfig)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - TomcatConfig")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.Connectors != nil {
+		data, err = json.Marshal(webServer.Spec.Connectors)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - Connectors")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionClustering != nil {
+		data, err = json.Marshal(webServer.Spec.SessionClustering)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionClustering")
+			return ""
+		}
+		h.Write(data)
+	}
+
+	if webServer.Spec.SessionStore != nil {
+		data, err = json.Marshal(webServer.Spec.SessionStore)
+		if err != nil {
+			log.Error(err, "WebServer hash sum calculation failed - SessionStore")
+			return ""
+		}
+		h.Write(data)
+	}
+
 	/* rules for labels: '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?')"} */
 	enc := base64.NewEncoding("qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM_.0123456789")
 	enc = enc.WithPadding(base64.NoPadding)
 	return "A" + enc.EncodeToString(h.Sum(nil)) + "A"
 }
 
-// Calculate a hash of the Spec (configuration) to redeploy/rebuild if needed.
-func (r *WebServerReconciler) getReplicaStatus(ctx context.Context, webServer *webserversv1alpha1.WebServer) int32 {
+// getReplicaStatus returns the number of replicas, of ready replicas and of desired replicas of the Deployment or
+// StatefulSet. The desired replicas are the replicas of the WebServer unless a HorizontalPodAutoscaler scales the
+// workload, it then sets them in the Deployment or StatefulSet.
+func (r *WebServerReconciler) getReplicaStatus(ctx context.Context, webServer *webserversv1alpha1.WebServer) (int32, int32, int32) {
+	var workload client.Object
+	var kind string
+	var replicas, readyReplicas int32
+	var workloadReplicas *int32
 	if webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0 {
 		statefulset := &kbappsv1.StatefulSet{}
 		err := r.Get(ctx, types.NamespacedName{Name: webServer.Spec.ApplicationName, Namespace: webServer.Namespace}, statefulset)
 
 		if err != nil {
 			log.Error(err, "Failed to get StatefulSet: "+webServer.Spec.ApplicationName)
-			return 0
+			return 0, 0, webServer.Spec.Replicas
 		}
 
-		return statefulset.Status.Replicas
+		workload, kind = statefulset, "StatefulSet"
+		replicas, readyReplicas, workloadReplicas = statefulset.Status.Replicas, statefulset.Status.ReadyReplicas, statefulset.Spec.Replicas
 	} else {
 		deployment := &kbappsv1.Deployment{}
 		err := r.Get(ctx, types.NamespacedName{Name: webServer.Spec.ApplicationName, Namespace: webServer.Namespace}, deployment)
 		if err != nil {
 			log.Error(err, "Failed to get Deployment: "+webServer.Spec.ApplicationName)
-			return 0
+			return 0, 0, webServer.Spec.Replicas
 		}
 
-		return deployment.Status.Replicas
+		workload, kind = deployment, "Deployment"
+		replicas, readyReplicas, workloadReplicas = deployment.Status.Replicas, deployment.Status.ReadyReplicas, deployment.Spec.Replicas
 	}
-}
-
-// Add an annotation to the webServer for the KUBEPing
 
-func (r *WebServerReconciler) setUseKUBEPing(ctx context.Context, webServer *webserversv1alpha1.WebServer, kubeping bool) (bool, error) {
-	skubeping := "false"
-	if kubeping {
-		skubeping = "true"
-	}
-	needUpdate := false
-	annotations := webServer.Annotations
-	if annotations == nil {
-		annotations = make(map[string]string)
-		webServer.Annotations = annotations
-		needUpdate = true
-		annotations["UseKUBEPing"] = skubeping
-	} else {
-		if strings.Compare(skubeping, annotations["UseKUBEPing"]) != 0 {
-			annotations["UseKUBEPing"] = skubeping
-			needUpdate = true
-		}
-	}
-	if needUpdate {
-		log.Info("The UseKUBEPing annotation is being updated")
-		err := r.Update(ctx, webServer)
-		if err != nil {
-			if errors.IsConflict(err) {
-				log.Info("setUseKUBEPing needs webServer UPDATE!!!")
-				return true, nil
-			} else {
-				log.Error(err, "Failed to update WebServer UseKUBEPing annotation")
-			}
-		}
-		return true, err
-	}
-	return false, nil
-}
-func (r *WebServerReconciler) getUseKUBEPing(webServer *webserversv1alpha1.WebServer) bool {
-	annotations := webServer.Annotations
-	if annotations != nil {
-		skubeping := annotations["UseKUBEPing"]
-		if skubeping != "" {
-			if strings.Compare(skubeping, "false") == 0 {
-				return false
-			}
-		}
+	desiredReplicas := webServer.Spec.Replicas
+	autoscaled, err := r.replicasManagedByAutoscaler(ctx, kind, workload)
+	if err == nil && autoscaled && workloadReplicas != nil {
+		desiredReplicas = *workloadReplicas
 	}
-	return true
-}
-func (r *WebServerReconciler) needgetUseKUBEPing(webServer *webserversv1alpha1.WebServer) bool {
-	annotations := webServer.Annotations
-	if annotations != nil {
-		log.Info("needgetUseKUBEPing: annotations")
-		skubeping := annotations["UseKUBEPing"]
-		if skubeping != "" {
-			log.Info("needgetUseKUBEPing: annotations skubeping")
-			return false
-		} else {
-			log.Info("needgetUseKUBEPing: annotations NO skubeping")
-		}
-	}
-	return true
+	return replicas, readyReplicas, desiredReplicas
 }
 
 // CustomResourceDefinitionExists returns true if the CRD exists in the cluster
@@ -1096,6 +811,14 @@ func (r *WebServerReconciler) checkOwnedObjects(ctx context.Context, webServer *
 		return err
 	}
 
+	if !rolloutInProgress(webServer) {
+		// The canary Service only exists during a rollout
+		canaryService := &corev1.Service{ObjectMeta: r.generateObjectMeta(webServer, canaryName(webServer))}
+		if err = r.deleteForWebServer(ctx, webServer, canaryService, "Service"); err != nil {
+			return err
+		}
+	}
+
 	if webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0 {
 		log.Info("CheckOwnedObjects: statefulset case")
 		for _, deployment := range ownedDeployments.Items {
@@ -1124,6 +847,9 @@ func (r *WebServerReconciler) checkOwnedObjects(ctx context.Context, webServer *
 			}
 		}
 		for _, deployment := range ownedDeployments.Items {
+			if deployment.Name == canaryName(webServer) && rolloutInProgress(webServer) {
+				continue
+			}
 			if deployment.Name != webServer.Spec.ApplicationName {
 				err = r.Delete(ctx, &deployment)
 				if err != nil {
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	kbappsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+// The plain tests of the package don't need the API server, make test-unit runs them without the envtest suite.
+
+// newTestScheme returns the scheme of the objects of the operator, without the APIs of OpenShift.
+func newTestScheme() *runtime.Scheme {
+	testScheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(testScheme))
+	utilruntime.Must(webserversorgv1alpha1.AddToScheme(testScheme))
+	return testScheme
+}
+
+// newTestReconciler returns a reconciler reading and writing the objects with a fake client. The fake client
+// doesn't implement server-side apply, an apply creates the object or replaces it but keeps its status.
+func newTestReconciler(objects ...client.Object) *WebServerReconciler {
+	testScheme := newTestScheme()
+	fakeClient := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).
+		WithStatusSubresource(&webserversorgv1alpha1.WebServer{}, &kbappsv1.Deployment{}, &kbappsv1.StatefulSet{}).
+		WithIndex(&kbappsv1.Deployment{}, ownerUIDIndex, ownerReferenceUIDs).
+		WithIndex(&kbappsv1.StatefulSet{}, ownerUIDIndex, ownerReferenceUIDs).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: applyPatch}).
+		Build()
+	return &WebServerReconciler{
+		Client:    fakeClient,
+		Scheme:    testScheme,
+		APIReader: fakeClient,
+		Recorder:  record.NewFakeRecorder(100),
+	}
+}
+
+// applyPatch stands for the server-side apply of the fake client.
+func applyPatch(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch != client.Apply {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	found := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), found)
+	if errors.IsNotFound(err) {
+		err = c.Create(ctx, obj)
+	} else if err == nil {
+		obj.SetResourceVersion(found.GetResourceVersion())
+		err = c.Update(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	return c.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+}
+
+// ownerReferenceUIDs returns the UIDs of the owners of the object, like the index of cmd/main.go.
+func ownerReferenceUIDs(obj client.Object) []string {
+	uids := make([]string, len(obj.GetOwnerReferences()))
+	for i, ownerReference := range obj.GetOwnerReferences() {
+		uids[i] = string(ownerReference.UID)
+	}
+	return uids
+}
+
+func TestGetReplicaStatus(t *testing.T) {
+	newObjects := func() (*webserversorgv1alpha1.WebServer, *kbappsv1.Deployment) {
+		replicas := int32(5)
+		webServer := &webserversorgv1alpha1.WebServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec: webserversorgv1alpha1.WebServerSpec{
+				ApplicationName: "demo",
+				Replicas:        2,
+				WebImage:        &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/demo/app:1"},
+			},
+		}
+		deployment := &kbappsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec:       kbappsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     kbappsv1.DeploymentStatus{Replicas: 5, ReadyReplicas: 4},
+		}
+		return webServer, deployment
+	}
+
+	t.Run("with the replicas of the WebServer", func(t *testing.T) {
+		g := NewWithT(t)
+		webServer, deployment := newObjects()
+		replicas, readyReplicas, desiredReplicas := newTestReconciler(deployment).getReplicaStatus(context.Background(), webServer)
+		g.Expect(replicas).To(Equal(int32(5)))
+		g.Expect(readyReplicas).To(Equal(int32(4)))
+		g.Expect(desiredReplicas).To(Equal(int32(2)))
+	})
+
+	t.Run("with the replicas of a HorizontalPodAutoscaler", func(t *testing.T) {
+		g := NewWithT(t)
+		webServer, deployment := newObjects()
+		autoscaler := &autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "demo"},
+				MaxReplicas:    10,
+			},
+		}
+		_, _, desiredReplicas := newTestReconciler(deployment, autoscaler).getReplicaStatus(context.Background(), webServer)
+		g.Expect(desiredReplicas).To(Equal(int32(5)))
+	})
+}
+
+func TestGetWebServerHash(t *testing.T) {
+	maxThreads := int32(400)
+	tests := []struct {
+		name   string
+		update func(*webserversorgv1alpha1.WebServer)
+		moves  bool
+	}{
+		{"without changes", func(*webserversorgv1alpha1.WebServer) {}, false},
+		{"with the replicas", func(webServer *webserversorgv1alpha1.WebServer) { webServer.Spec.Replicas = 5 }, false},
+		{"with the image", func(webServer *webserversorgv1alpha1.WebServer) {
+			webServer.Spec.WebImage.ApplicationImage = "quay.io/demo/app:2"
+		}, true},
+		{"with the tomcat configuration", func(webServer *webserversorgv1alpha1.WebServer) {
+			webServer.Spec.TomcatConfig = &webserversorgv1alpha1.TomcatConfigSpec{ServerXml: &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config"}}
+		}, true},
+		{"with the tuning of a connector", func(webServer *webserversorgv1alpha1.WebServer) {
+			webServer.Spec.Connectors = &webserversorgv1alpha1.ConnectorsSpec{HTTP: &webserversorgv1alpha1.ConnectorSpec{MaxThreads: &maxThreads}}
+		}, true},
+		{"with the session manager", func(webServer *webserversorgv1alpha1.WebServer) {
+			webServer.Spec.SessionClustering = &webserversorgv1alpha1.SessionClusteringSpec{Manager: webserversorgv1alpha1.SessionManagerBackup}
+		}, true},
+		{"with the session store", func(webServer *webserversorgv1alpha1.WebServer) {
+			webServer.Spec.SessionStore = &webserversorgv1alpha1.SessionStoreSpec{Type: webserversorgv1alpha1.SessionStoreRedis, SecretName: "redis"}
+		}, true},
+	}
+	reconciler := &WebServerReconciler{}
+	hash := reconciler.getWebServerHash(newConditionsTest())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webServer := newConditionsTest()
+			tt.update(webServer)
+			if tt.moves {
+				NewWithT(t).Expect(reconciler.getWebServerHash(webServer)).NotTo(Equal(hash))
+			} else {
+				NewWithT(t).Expect(reconciler.getWebServerHash(webServer)).To(Equal(hash))
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	kbappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkRevision records the last revision of the pods which reached full readiness and rolls back a new
+// revision which isn't ready after updateStrategy.rollbackDeadlineSeconds. It returns true when the status
+// changed, the result requeues the reconciliation to check the deadline or to apply the rollback.
+// The rollouts of the application image have their own abort and are left alone.
+func (r *WebServerReconciler) checkRevision(ctx context.Context, webServer *webserversv1alpha1.WebServer) (bool, ctrl.Result, error) {
+	if useRollout(webServer) {
+		return false, ctrl.Result{}, nil
+	}
+	name := webServer.Spec.ApplicationName
+	var template *corev1.PodTemplateSpec
+	var ready bool
+	statefulSet := webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0
+	if statefulSet {
+		found := &kbappsv1.StatefulSet{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, found); err != nil {
+			if errors.IsNotFound(err) {
+				return false, ctrl.Result{}, nil
+			}
+			log.Error(err, "Failed to get StatefulSet: "+name)
+			return false, ctrl.Result{}, err
+		}
+		template = &found.Spec.Template
+		ready = statefulSetReady(found)
+	} else {
+		found := &kbappsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, found); err != nil {
+			if errors.IsNotFound(err) {
+				return false, ctrl.Result{}, nil
+			}
+			log.Error(err, "Failed to get Deployment: "+name)
+			return false, ctrl.Result{}, err
+		}
+		template = &found.Spec.Template
+		ready = deploymentReady(found)
+	}
+
+	status := &webServer.Status
+	revision := webserversv1alpha1.Revision{Image: containerImage(template, name), Hash: template.Labels["webserver-hash"]}
+	now := metav1.Now()
+	changed := false
+
+	if rolledBack := status.RolledBackRevision; rolledBack != nil && rolledBack.Hash != r.getWebServerHash(webServer) {
+		// The WebServer changed since the rollback, its new revision has been applied
+		status.RolledBackRevision = nil
+		r.setCondition(webServer, webserversv1alpha1.ConditionRolledBack, metav1.ConditionFalse, reasonWebServerChanged, "The WebServer changed since the rollback")
+		changed = true
+	}
+
+	if ready {
+		if status.LastReadyRevision == nil || !sameRevision(status.LastReadyRevision, &revision) {
+			log.Info("Revision " + revision.Hash + " is ready")
+			revision.Time = &now
+			status.LastReadyRevision = &revision
+			changed = true
+		}
+		if status.UpdatingRevision != nil {
+			status.UpdatingRevision = nil
+			changed = true
+		}
+		return changed, ctrl.Result{}, nil
+	}
+
+	if status.RolledBackRevision != nil {
+		// Waiting for the pods of the last ready revision
+		if statefulSet {
+			return changed, ctrl.Result{}, r.deleteRolledBackPods(ctx, webServer)
+		}
+		return changed, ctrl.Result{}, nil
+	}
+
+	deadline := time.Duration(0)
+	if webServer.Spec.UpdateStrategy != nil && webServer.Spec.UpdateStrategy.RollbackDeadlineSeconds != nil {
+		deadline = time.Duration(*webServer.Spec.UpdateStrategy.RollbackDeadlineSeconds) * time.Second
+	}
+	lastReady := status.LastReadyRevision
+	if deadline == 0 || lastReady == nil || sameRevision(lastReady, &revision) {
+		// Nothing to roll back to, or the pods of the ready revision are being replaced
+		if status.UpdatingRevision != nil {
+			status.UpdatingRevision = nil
+			changed = true
+		}
+		return changed, ctrl.Result{}, nil
+	}
+	if status.UpdatingRevision == nil || !sameRevision(status.UpdatingRevision, &revision) || status.UpdatingRevision.Time == nil {
+		revision.Time = &now
+		status.UpdatingRevision = &revision
+		return true, ctrl.Result{RequeueAfter: deadline}, nil
+	}
+	elapsed := time.Since(status.UpdatingRevision.Time.Time)
+	if elapsed < deadline {
+		return changed, ctrl.Result{RequeueAfter: deadline - elapsed}, nil
+	}
+
+	message := fmt.Sprintf("Revision %s (%s) was not ready after %s, rolled back to revision %s (%s)", revision.Hash, revision.Image, deadline, lastReady.Hash, lastReady.Image)
+	log.Info(message)
+	r.recordWarning(webServer, eventReasonRolledBack, message)
+	revision.Time = &now
+	status.RolledBackRevision = &revision
+	status.UpdatingRevision = nil
+	r.setCondition(webServer, webserversv1alpha1.ConditionRolledBack, metav1.ConditionTrue, reasonRevisionNotReady, message)
+	// The next reconciliation applies the pod template of the last ready revision
+	return true, ctrl.Result{Requeue: true}, nil
+}
+
+// keepRolledBackTemplate replaces the generated pod template with the template of the last ready revision
+// while the WebServer is unchanged since the rollback. The template is taken from the ReplicaSet of the Deployment
+// or from the ControllerRevision of the StatefulSet, only the image is rolled back when they are gone.
+func (r *WebServerReconciler) keepRolledBackTemplate(ctx context.Context, webServer *webserversv1alpha1.WebServer, template *corev1.PodTemplateSpec, workload client.Object) error {
+	rolledBack := webServer.Status.RolledBackRevision
+	lastReady := webServer.Status.LastReadyRevision
+	if rolledBack == nil || lastReady == nil || template.Labels["webserver-hash"] != rolledBack.Hash || useRollout(webServer) {
+		return nil
+	}
+	revisionTemplate, err := r.getRevisionTemplate(ctx, webServer, workload, lastReady)
+	if err != nil {
+		return err
+	}
+	if revisionTemplate == nil {
+		log.Info("The pod template of revision " + lastReady.Hash + " is gone, rolling back the image only")
+		for i := range template.Spec.Containers {
+			if template.Spec.Containers[i].Name == webServer.Spec.ApplicationName {
+				template.Spec.Containers[i].Image = lastReady.Image
+			}
+		}
+		template.Labels["webserver-hash"] = lastReady.Hash
+		return nil
+	}
+	log.Info("Keeping the pod template of revision " + lastReady.Hash + " until the WebServer changes")
+	*template = *revisionTemplate
+	return nil
+}
+
+// getRevisionTemplate looks for the pod template of the revision in the ReplicaSets of the Deployment or in the
+// ControllerRevisions of the StatefulSet, it returns nil when the revision history doesn't have it anymore.
+func (r *WebServerReconciler) getRevisionTemplate(ctx context.Context, webServer *webserversv1alpha1.WebServer, workload client.Object, revision *webserversv1alpha1.Revision) (*corev1.PodTemplateSpec, error) {
+	matches := func(template *corev1.PodTemplateSpec) bool {
+		return template.Labels["webserver-hash"] == revision.Hash && containerImage(template, webServer.Spec.ApplicationName) == revision.Image
+	}
+	owned := func(object metav1.Object) bool {
+		owner := metav1.GetControllerOf(object)
+		return owner != nil && owner.UID == workload.GetUID()
+	}
+	listOpts := []client.ListOption{
+		client.InNamespace(webServer.Namespace),
+		client.MatchingLabels(r.generateSelectorLabelsForWeb(webServer)),
+	}
+
+	switch workload.(type) {
+	case *kbappsv1.Deployment:
+		replicaSets := &kbappsv1.ReplicaSetList{}
+		if err := r.List(ctx, replicaSets, listOpts...); err != nil {
+			log.Error(err, "Failed to list the ReplicaSets of Deployment: "+workload.GetName())
+			return nil, err
+		}
+		for _, replicaSet := range replicaSets.Items {
+			if owned(&replicaSet) && matches(&replicaSet.Spec.Template) {
+				template := replicaSet.Spec.Template.DeepCopy()
+				// Set by the Deployment controller for each ReplicaSet
+				delete(template.Labels, kbappsv1.DefaultDeploymentUniqueLabelKey)
+				return template, nil
+			}
+		}
+	case *kbappsv1.StatefulSet:
+		revisions := &kbappsv1.ControllerRevisionList{}
+		if err := r.List(ctx, revisions, listOpts...); err != nil {
+			log.Error(err, "Failed to list the ControllerRevisions of StatefulSet: "+workload.GetName())
+			return nil, err
+		}
+		for _, controllerRevision := range revisions.Items {
+			if !owned(&controllerRevision) {
+				continue
+			}
+			// The revision holds the patch of the StatefulSet replacing its template
+			data := struct {
+				Spec struct {
+					Template corev1.PodTemplateSpec `json:"template"`
+				} `json:"spec"`
+			}{}
+			if err := json.Unmarshal(controllerRevision.Data.Raw, &data); err != nil {
+				log.Info("Unable to read ControllerRevision " + controllerRevision.Name + ": " + err.Error())
+				continue
+			}
+			if matches(&data.Spec.Template) {
+				return &data.Spec.Template, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// deleteRolledBackPods deletes the pods of the rolled back revision which aren't ready: the StatefulSet controller
+// doesn't replace a pod which never became ready with the pod of the restored template.
+func (r *WebServerReconciler) deleteRolledBackPods(ctx context.Context, webServer *webserversv1alpha1.WebServer) error {
+	podList := &corev1.PodList{}
+	labels := r.generateSelectorLabelsForWeb(webServer)
+	labels["webserver-hash"] = webServer.Status.RolledBackRevision.Hash
+	err := r.List(ctx, podList, client.InNamespace(webServer.Namespace), client.MatchingLabels(labels))
+	if err != nil {
+		log.Error(err, "Failed to list the pods of the rolled back revision")
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if isPodReady(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		log.Info("Deleting pod " + pod.Name + " of the rolled back revision")
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete pod: "+pod.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// sameRevision compares the image and the hash of the revisions.
+func sameRevision(revision, other *webserversv1alpha1.Revision) bool {
+	return revision.Image == other.Image && revision.Hash == other.Hash
+}
+
+// deploymentReady returns true when all the pods of the Deployment run its current template and are ready.
+func deploymentReady(deployment *kbappsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation && status.Replicas == replicas &&
+		status.UpdatedReplicas == replicas && status.ReadyReplicas == replicas
+}
+
+// statefulSetReady returns true when all the pods of the StatefulSet are ready and the pods above the
+// partition run its current template.
+func statefulSetReady(statefulSet *kbappsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	updated := replicas
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		updated = max(replicas-*rollingUpdate.Partition, 0)
+	}
+	status := statefulSet.Status
+	return status.ObservedGeneration >= statefulSet.Generation && status.ReadyReplicas == replicas &&
+		status.UpdatedReplicas >= updated
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	kbappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+func TestStatefulSetReady(t *testing.T) {
+	tests := []struct {
+		name            string
+		partition       int32
+		readyReplicas   int32
+		updatedReplicas int32
+		ready           bool
+	}{
+		{"all updated and ready", -1, 3, 3, true},
+		{"while a pod isn't ready", -1, 2, 3, false},
+		{"while a pod runs the previous template", -1, 3, 2, false},
+		{"above the partition", 2, 3, 1, true},
+		{"below the partition", 2, 3, 0, false},
+		{"with a partition above the replicas", 5, 3, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas := int32(3)
+			statefulSet := &kbappsv1.StatefulSet{
+				Spec: kbappsv1.StatefulSetSpec{Replicas: &replicas},
+				Status: kbappsv1.StatefulSetStatus{
+					ReadyReplicas:   tt.readyReplicas,
+					UpdatedReplicas: tt.updatedReplicas,
+				},
+			}
+			if tt.partition >= 0 {
+				partition := tt.partition
+				statefulSet.Spec.UpdateStrategy.RollingUpdate = &kbappsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+			}
+			NewWithT(t).Expect(statefulSetReady(statefulSet)).To(Equal(tt.ready))
+		})
+	}
+}
+
+// newRevisionTest returns a WebServer rolled back after 300 seconds and its Deployment, whose pods run revision "new"
+// while revision "old" was the last one ready.
+func newRevisionTest() (*webserversorgv1alpha1.WebServer, *kbappsv1.Deployment) {
+	rollbackDeadlineSeconds := int32(300)
+	replicas := int32(2)
+	webServer := &webserversorgv1alpha1.WebServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec: webserversorgv1alpha1.WebServerSpec{
+			ApplicationName: "demo",
+			Replicas:        2,
+			WebImage:        &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/demo/app:2"},
+			UpdateStrategy:  &webserversorgv1alpha1.UpdateStrategy{RollbackDeadlineSeconds: &rollbackDeadlineSeconds},
+		},
+		Status: webserversorgv1alpha1.WebServerStatus{
+			LastReadyRevision: &webserversorgv1alpha1.Revision{Image: "quay.io/demo/app:1", Hash: "old"},
+		},
+	}
+	deployment := &kbappsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec: kbappsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"webserver-hash": "new"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "demo", Image: "quay.io/demo/app:2"}}},
+			},
+		},
+		Status: kbappsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2},
+	}
+	return webServer, deployment
+}
+
+func TestCheckRevisionReady(t *testing.T) {
+	g := NewWithT(t)
+	webServer, deployment := newRevisionTest()
+	deployment.Status.ReadyReplicas = 2
+	changed, result, err := newTestReconciler(deployment).checkRevision(context.Background(), webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(webServer.Status.LastReadyRevision.Image).To(Equal("quay.io/demo/app:2"))
+	g.Expect(webServer.Status.LastReadyRevision.Hash).To(Equal("new"))
+	g.Expect(webServer.Status.UpdatingRevision).To(BeNil())
+}
+
+func TestCheckRevisionRollback(t *testing.T) {
+	g := NewWithT(t)
+	webServer, deployment := newRevisionTest()
+	reconciler := newTestReconciler(deployment)
+	changed, result, err := reconciler.checkRevision(context.Background(), webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(result.RequeueAfter).To(Equal(300 * time.Second))
+	g.Expect(webServer.Status.UpdatingRevision.Hash).To(Equal("new"))
+	g.Expect(webServer.Status.RolledBackRevision).To(BeNil())
+
+	// After the deadline
+	webServer.Status.UpdatingRevision.Time = &metav1.Time{Time: time.Now().Add(-301 * time.Second)}
+	changed, result, err = reconciler.checkRevision(context.Background(), webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(result.Requeue).To(BeTrue())
+	g.Expect(webServer.Status.RolledBackRevision.Hash).To(Equal("new"))
+	g.Expect(webServer.Status.UpdatingRevision).To(BeNil())
+	g.Expect(meta.IsStatusConditionTrue(webServer.Status.Conditions, webserversorgv1alpha1.ConditionRolledBack)).To(BeTrue())
+}
+
+func TestCheckRevisionWithoutDeadline(t *testing.T) {
+	g := NewWithT(t)
+	webServer, deployment := newRevisionTest()
+	webServer.Spec.UpdateStrategy = nil
+	changed, result, err := newTestReconciler(deployment).checkRevision(context.Background(), webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(webServer.Status.UpdatingRevision).To(BeNil())
+	g.Expect(webServer.Status.RolledBackRevision).To(BeNil())
+}
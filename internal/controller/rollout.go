@@ -0,0 +1,362 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	routev1 "github.com/openshift/api/route/v1"
+	kbappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// canaryMaxRestarts is the number of restarts of a container running the new image which aborts the rollout,
+// the container is restarted when its liveness probe fails.
+const canaryMaxRestarts = 3
+
+// canaryName returns the name of the Deployment and of the Service of the image being rolled out.
+func canaryName(webServer *webserversv1alpha1.WebServer) string {
+	return webServer.Spec.ApplicationName + "-canary"
+}
+
+// useRollout returns true when the changes of the application image are rolled out progressively,
+// only an image deployed as is by a Deployment can be rolled out.
+func useRollout(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.Rollout != nil && webServer.Spec.WebImage != nil && webServer.Spec.WebImage.WebApp == nil &&
+		(webServer.Spec.Volume == nil || len(webServer.Spec.Volume.VolumeClaimTemplates) == 0)
+}
+
+// rolloutSteps returns the traffic percentages of a canary rollout, the WebServers created before the defaulting
+// webhook may not have them.
+func rolloutSteps(rolloutSpec *webserversv1alpha1.RolloutSpec) []int32 {
+	if len(rolloutSpec.Steps) == 0 {
+		return webserversv1alpha1.DefaultRolloutSteps()
+	}
+	return rolloutSpec.Steps
+}
+
+// rolloutInProgress returns true while the canary Deployment exists and may receive traffic.
+func rolloutInProgress(webServer *webserversv1alpha1.WebServer) bool {
+	if !useRollout(webServer) || webServer.Status.Rollout == nil {
+		return false
+	}
+	phase := webServer.Status.Rollout.Phase
+	return phase == webserversv1alpha1.RolloutPhaseProgressing || phase == webserversv1alpha1.RolloutPhasePromoting
+}
+
+// rolloutTrafficDeployment returns, during a rollout, the Deployment whose pods are selected by the Services
+// of the application, "" selects the pods of both Deployments. It returns false when there is no rollout.
+func (r *WebServerReconciler) rolloutTrafficDeployment(webServer *webserversv1alpha1.WebServer) (string, bool) {
+	if !rolloutInProgress(webServer) {
+		return "", false
+	}
+	switch {
+	case r.isOpenShift:
+		// The Route splits the traffic between the Service of the application and the canary Service
+		return webServer.Spec.ApplicationName, true
+	case webServer.Spec.Rollout.Strategy == webserversv1alpha1.RolloutStrategyCanary:
+		return "", true
+	case webServer.Status.Rollout.Weight == 100:
+		return canaryName(webServer), true
+	default:
+		return webServer.Spec.ApplicationName, true
+	}
+}
+
+// setRolloutBackends sends the weight of the rollout to the canary Service, the rest of the traffic goes to
+// the Service of the application.
+func (r *WebServerReconciler) setRolloutBackends(webServer *webserversv1alpha1.WebServer, route *routev1.Route) {
+	if !rolloutInProgress(webServer) {
+		return
+	}
+	weight := webServer.Status.Rollout.Weight
+	stableWeight := 100 - weight
+	route.Spec.To.Kind = "Service"
+	route.Spec.To.Weight = &stableWeight
+	route.Spec.AlternateBackends = []routev1.RouteTargetReference{{
+		Kind:   "Service",
+		Name:   canaryName(webServer),
+		Weight: &weight,
+	}}
+}
+
+// continueWithRollout deploys the application image through a rollout: a new image runs first in the canary
+// Deployment, the Deployment of the application is only updated once the canary pods went through all the steps.
+// The Services and the Route follow the status of the rollout, the reconciliation is requeued when it changes.
+func (r *WebServerReconciler) continueWithRollout(ctx context.Context, webServer *webserversv1alpha1.WebServer, image string) (ctrl.Result, error) {
+	found := &kbappsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: webServer.Spec.ApplicationName, Namespace: webServer.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Deployment: "+webServer.Spec.ApplicationName)
+		return reconcile.Result{}, err
+	}
+	if errors.IsNotFound(err) {
+		// Nothing to roll out, the first image is deployed directly
+		return r.continueWithDeployment(ctx, webServer, image)
+	}
+	stableImage := containerImage(&found.Spec.Template, webServer.Spec.ApplicationName)
+	rollout := webServer.Status.Rollout
+
+	if !rolloutInProgress(webServer) {
+		switch {
+		case rollout != nil && rollout.Phase == webserversv1alpha1.RolloutPhaseAborted && rollout.CanaryImage == image &&
+			(rollout.AbortedGeneration == webServer.Generation || webServer.Spec.Rollout.Abort):
+			// The image is rolled out again once the WebServer changes
+			log.Info("Rollout of " + image + " aborted, keeping " + rollout.StableImage)
+			return r.continueWithDeployment(ctx, withApplicationImage(webServer, rollout.StableImage), rollout.StableImage)
+		case stableImage == image:
+			return r.continueWithDeployment(ctx, webServer, image)
+		case webServer.Spec.Rollout.Abort:
+			log.Info("Rollout of " + image + " aborted, keeping " + stableImage)
+			return r.continueWithDeployment(ctx, withApplicationImage(webServer, stableImage), stableImage)
+		}
+		return r.startRollout(ctx, webServer, stableImage, image)
+	}
+
+	switch {
+	case image == rollout.StableImage:
+		return r.abortRollout(ctx, webServer, "The application image was reverted to "+image)
+	case image != rollout.CanaryImage:
+		// The image changed again, the rollout starts over with the new one
+		return r.startRollout(ctx, webServer, rollout.StableImage, image)
+	case webServer.Spec.Rollout.Abort:
+		return r.abortRollout(ctx, webServer, "Rollout of "+image+" aborted by spec.rollout.abort")
+	case rollout.Phase == webserversv1alpha1.RolloutPhasePromoting:
+		return r.promoteRollout(ctx, webServer)
+	default:
+		return r.progressRollout(ctx, webServer)
+	}
+}
+
+// startRollout records the start of the rollout of image, the canary Deployment is created at the next reconciliation
+// once the Services and the Route are ready to send it traffic.
+func (r *WebServerReconciler) startRollout(ctx context.Context, webServer *webserversv1alpha1.WebServer, stableImage, image string) (ctrl.Result, error) {
+	rolloutSpec := webServer.Spec.Rollout
+	weight := int32(0)
+	if rolloutSpec.Strategy == webserversv1alpha1.RolloutStrategyCanary {
+		weight = rolloutSteps(rolloutSpec)[0]
+	}
+	now := metav1.Now()
+	webServer.Status.Rollout = &webserversv1alpha1.RolloutStatus{
+		Phase:         webserversv1alpha1.RolloutPhaseProgressing,
+		StableImage:   stableImage,
+		CanaryImage:   image,
+		Weight:        weight,
+		StepStartTime: &now,
+		Message:       fmt.Sprintf("Sending %d%% of the traffic to %s", weight, image),
+	}
+	log.Info("Starting the " + rolloutSpec.Strategy + " rollout of " + image)
+	r.recordEvent(webServer, eventReasonRolloutStarted, "Rolling out "+image+" with the "+rolloutSpec.Strategy+" strategy, "+stableImage+" still serves the traffic")
+	return r.updateRolloutStatus(ctx, webServer)
+}
+
+// splitCanaryReplicas returns the replicas of the canary and of the stable Deployments at a step of a canary
+// rollout. The canary gets its weight of the replicas, rounded up. When splitsReplicas the stable Deployment keeps
+// the rest but at least one replica until the canary gets all the traffic, a single replica WebServer runs one
+// pod of each.
+func splitCanaryReplicas(replicas, weight int32, splitsReplicas bool) (canary, stable int32) {
+	canary = (replicas*weight + 99) / 100
+	if !splitsReplicas {
+		return canary, replicas
+	}
+	stable = replicas - canary
+	if stable < 1 && weight < 100 && replicas > 0 {
+		stable = 1
+		canary = max(replicas-1, 1)
+	}
+	return canary, stable
+}
+
+// progressRollout deploys the canary image next to the previous one and moves to the next step once all the
+// canary pods are available, i.e. they stayed ready for spec.rollout.stepSeconds.
+func (r *WebServerReconciler) progressRollout(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	rollout := webServer.Status.Rollout
+	canaryReplicas := webServer.Spec.Replicas
+	stableReplicas := webServer.Spec.Replicas
+	if webServer.Spec.Rollout.Strategy == webserversv1alpha1.RolloutStrategyCanary {
+		// Without Route the Services split the traffic in proportion of the replicas of both Deployments
+		canaryReplicas, stableReplicas = splitCanaryReplicas(webServer.Spec.Replicas, rollout.Weight, !r.isOpenShift)
+	}
+
+	stable := withApplicationImage(webServer, rollout.StableImage)
+	deployment := r.generateDeployment(stable, rollout.StableImage)
+	deployment.Labels["webserver-hash"] = r.getWebServerHash(stable)
+	deployment.Spec.Replicas = &stableReplicas
+	result, err := r.applyDeployment(ctx, webServer, deployment)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+
+	canary, result, err := r.applyCanary(ctx, webServer, canaryReplicas)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+	result, err = r.checkRolloutPods(ctx, webServer, canary)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+
+	steps := rolloutSteps(webServer.Spec.Rollout)
+	if webServer.Spec.Rollout.Strategy == webserversv1alpha1.RolloutStrategyCanary && int(rollout.Step)+1 < len(steps) {
+		rollout.Step++
+		rollout.Weight = steps[rollout.Step]
+		rollout.Message = fmt.Sprintf("Sending %d%% of the traffic to %s", rollout.Weight, rollout.CanaryImage)
+		r.recordEvent(webServer, eventReasonRolloutStep, rollout.Message)
+	} else {
+		rollout.Phase = webserversv1alpha1.RolloutPhasePromoting
+		rollout.Weight = 100
+		rollout.Message = "Sending all the traffic to " + rollout.CanaryImage + " while the Deployment " + webServer.Spec.ApplicationName + " is updated"
+		r.recordEvent(webServer, eventReasonRolloutPromoting, "Promoting "+rollout.CanaryImage)
+	}
+	now := metav1.Now()
+	rollout.StepStartTime = &now
+	log.Info(rollout.Message)
+	return r.updateRolloutStatus(ctx, webServer)
+}
+
+// promoteRollout updates the Deployment of the application to the canary image while the canary pods serve
+// all the traffic, the rollout is completed once the updated pods are available.
+func (r *WebServerReconciler) promoteRollout(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	rollout := webServer.Status.Rollout
+
+	_, result, err := r.applyCanary(ctx, webServer, webServer.Spec.Replicas)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+
+	deployment := r.generateDeployment(webServer, rollout.CanaryImage)
+	deployment.Labels["webserver-hash"] = r.getWebServerHash(webServer)
+	result, err = r.applyDeployment(ctx, webServer, deployment)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+	result, err = r.checkRolloutPods(ctx, webServer, deployment)
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+
+	rollout.Phase = webserversv1alpha1.RolloutPhaseCompleted
+	rollout.StableImage = rollout.CanaryImage
+	rollout.Step = 0
+	rollout.Weight = 0
+	rollout.Message = "Rolled out " + rollout.CanaryImage
+	log.Info(rollout.Message)
+	r.recordEvent(webServer, eventReasonRolloutCompleted, rollout.Message)
+	return r.updateRolloutStatus(ctx, webServer)
+}
+
+// checkRolloutPods waits for the pods of the Deployment running the canary image to be available. The rollout is
+// aborted when they aren't available within spec.rollout.progressDeadlineSeconds or when they keep restarting.
+func (r *WebServerReconciler) checkRolloutPods(ctx context.Context, webServer *webserversv1alpha1.WebServer, deployment *kbappsv1.Deployment) (ctrl.Result, error) {
+	rollout := webServer.Status.Rollout
+
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList,
+		client.InNamespace(webServer.Namespace),
+		client.MatchingLabels{"WebServer": webServer.Name, "deployment": deployment.Name},
+	)
+	if err != nil {
+		log.Error(err, "Failed to list the pods of Deployment: "+deployment.Name)
+		return reconcile.Result{}, err
+	}
+	for _, pod := range podList.Items {
+		if containerImage(&corev1.PodTemplateSpec{Spec: pod.Spec}, webServer.Spec.ApplicationName) != rollout.CanaryImage {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.RestartCount >= canaryMaxRestarts {
+				return r.abortRollout(ctx, webServer, fmt.Sprintf("Pod %s running %s restarted %d times", pod.Name, rollout.CanaryImage, containerStatus.RestartCount))
+			}
+		}
+	}
+
+	if deploymentAvailable(deployment) {
+		return reconcile.Result{}, nil
+	}
+	deadline := time.Duration(webserversv1alpha1.DefaultRolloutProgressDeadlineSeconds) * time.Second
+	if webServer.Spec.Rollout.ProgressDeadlineSeconds != nil {
+		deadline = time.Duration(*webServer.Spec.Rollout.ProgressDeadlineSeconds) * time.Second
+	}
+	elapsed := time.Duration(0)
+	if rollout.StepStartTime != nil {
+		elapsed = time.Since(rollout.StepStartTime.Time)
+	}
+	if elapsed >= deadline {
+		return r.abortRollout(ctx, webServer, fmt.Sprintf("The pods of Deployment %s running %s were not available after %s", deployment.Name, rollout.CanaryImage, deadline))
+	}
+	// The status changes of the Deployment trigger the next reconciliation, the requeue checks the deadline
+	log.Info("Waiting for the pods of Deployment " + deployment.Name + " to be available")
+	return ctrl.Result{RequeueAfter: deadline - elapsed}, nil
+}
+
+// abortRollout abandons the canary image, the Deployment of the application gets the previous image back at the
+// next reconciliation and the canary Deployment and Service are deleted with the other leftovers.
+func (r *WebServerReconciler) abortRollout(ctx context.Context, webServer *webserversv1alpha1.WebServer, message string) (ctrl.Result, error) {
+	rollout := webServer.Status.Rollout
+	rollout.Phase = webserversv1alpha1.RolloutPhaseAborted
+	rollout.Weight = 0
+	rollout.AbortedGeneration = webServer.Generation
+	rollout.Message = message
+	log.Info("Rollout aborted: " + message)
+	r.recordWarning(webServer, eventReasonRolloutAborted, message+", keeping "+rollout.StableImage)
+	return r.updateRolloutStatus(ctx, webServer)
+}
+
+// updateRolloutStatus writes the new state of the rollout and requeues the reconciliation: the status updates
+// don't trigger it and the Services and the Route must follow the rollout.
+func (r *WebServerReconciler) updateRolloutStatus(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	if err := r.updateStatus(ctx, webServer); err != nil {
+		return reconcile.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// applyCanary applies the Deployment of the canary image and, on OpenShift, the canary Service used by the Route.
+func (r *WebServerReconciler) applyCanary(ctx context.Context, webServer *webserversv1alpha1.WebServer, replicas int32) (*kbappsv1.Deployment, ctrl.Result, error) {
+	if r.isOpenShift {
+		result, err := r.applyResource(ctx, webServer, r.generateCanaryService(webServer, routingServicePort(webServer)))
+		if err != nil || result != (ctrl.Result{}) {
+			return nil, result, err
+		}
+	}
+	deployment := r.generateCanaryDeployment(webServer, webServer.Status.Rollout.CanaryImage, replicas)
+	result, err := r.applyDeployment(ctx, webServer, deployment)
+	return deployment, result, err
+}
+
+// withApplicationImage returns a copy of the WebServer deploying image, the Deployment generated from it
+// keeps the hash of the previous image so its pods aren't restarted during the rollout.
+func withApplicationImage(webServer *webserversv1alpha1.WebServer, image string) *webserversv1alpha1.WebServer {
+	webServerCopy := webServer.DeepCopy()
+	webServerCopy.Spec.WebImage.ApplicationImage = image
+	return webServerCopy
+}
+
+// containerImage returns the image of the named container of the pod template.
+func containerImage(template *corev1.PodTemplateSpec, name string) string {
+	for _, container := range template.Spec.Containers {
+		if container.Name == name {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// deploymentAvailable returns true when all the pods of the Deployment run its current template and are available.
+func deploymentAvailable(deployment *kbappsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation && status.Replicas == replicas &&
+		status.UpdatedReplicas == replicas && status.AvailableReplicas == replicas
+}
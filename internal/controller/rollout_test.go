@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	routev1 "github.com/openshift/api/route/v1"
+	kbappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+func TestSplitCanaryReplicas(t *testing.T) {
+	tests := []struct {
+		name           string
+		replicas       int32
+		weight         int32
+		splitsReplicas bool
+		canary         int32
+		stable         int32
+	}{
+		{"behind a Route", 4, 10, false, 1, 4},
+		{"behind a Route at the last step", 4, 100, false, 4, 4},
+		{"in proportion of the weight", 10, 30, true, 3, 7},
+		{"rounding the canary up", 4, 10, true, 1, 3},
+		{"keeping a stable replica", 2, 90, true, 1, 1},
+		{"with a single replica", 1, 10, true, 1, 1},
+		{"at the last step", 3, 100, true, 3, 0},
+		{"without replicas", 0, 50, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			canary, stable := splitCanaryReplicas(tt.replicas, tt.weight, tt.splitsReplicas)
+			g.Expect(canary).To(Equal(tt.canary))
+			g.Expect(stable).To(Equal(tt.stable))
+		})
+	}
+}
+
+// newRolloutTest returns a WebServer rolling out quay.io/demo/app:2 at the first of the steps 20% and 50%,
+// the Deployment of the application running quay.io/demo/app:1 and the canary Deployment.
+func newRolloutTest() (*webserversorgv1alpha1.WebServer, *kbappsv1.Deployment, *kbappsv1.Deployment) {
+	progressDeadlineSeconds := int32(300)
+	webServer := &webserversorgv1alpha1.WebServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", UID: "demo-uid", Generation: 2},
+		Spec: webserversorgv1alpha1.WebServerSpec{
+			ApplicationName: "demo",
+			Replicas:        4,
+			WebImage:        &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/demo/app:2"},
+			Rollout: &webserversorgv1alpha1.RolloutSpec{
+				Strategy:                webserversorgv1alpha1.RolloutStrategyCanary,
+				Steps:                   []int32{20, 50},
+				ProgressDeadlineSeconds: &progressDeadlineSeconds,
+			},
+		},
+	}
+	now := metav1.Now()
+	webServer.Status.Rollout = &webserversorgv1alpha1.RolloutStatus{
+		Phase:         webserversorgv1alpha1.RolloutPhaseProgressing,
+		StableImage:   "quay.io/demo/app:1",
+		CanaryImage:   "quay.io/demo/app:2",
+		Weight:        20,
+		StepStartTime: &now,
+	}
+	reconciler := &WebServerReconciler{Scheme: newTestScheme()}
+	stable := reconciler.generateDeployment(withApplicationImage(webServer, "quay.io/demo/app:1"), "quay.io/demo/app:1")
+	canary := reconciler.generateCanaryDeployment(webServer, "quay.io/demo/app:2", 1)
+	return webServer, stable, canary
+}
+
+// setDeploymentAvailable makes all the replicas of the Deployment available.
+func setDeploymentAvailable(t *testing.T, c client.Client, name string) {
+	deployment := &kbappsv1.Deployment{}
+	NewWithT(t).Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, deployment)).To(Succeed())
+	replicas := *deployment.Spec.Replicas
+	deployment.Status = kbappsv1.DeploymentStatus{Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas}
+	NewWithT(t).Expect(c.Status().Update(context.Background(), deployment)).To(Succeed())
+}
+
+// deploymentReplicas returns the replicas of the Deployment.
+func deploymentReplicas(t *testing.T, c client.Client, name string) int32 {
+	deployment := &kbappsv1.Deployment{}
+	NewWithT(t).Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, deployment)).To(Succeed())
+	return *deployment.Spec.Replicas
+}
+
+func TestSetRolloutBackends(t *testing.T) {
+	g := NewWithT(t)
+	webServer, _, _ := newRolloutTest()
+	reconciler := &WebServerReconciler{isOpenShift: true}
+
+	route := &routev1.Route{Spec: routev1.RouteSpec{To: routev1.RouteTargetReference{Name: "demo"}}}
+	reconciler.setRolloutBackends(webServer, route)
+	g.Expect(*route.Spec.To.Weight).To(Equal(int32(80)))
+	g.Expect(route.Spec.AlternateBackends).To(HaveLen(1))
+	g.Expect(route.Spec.AlternateBackends[0].Name).To(Equal("demo-canary"))
+	g.Expect(*route.Spec.AlternateBackends[0].Weight).To(Equal(int32(20)))
+
+	// Once the rollout is completed the Route only sends traffic to the application
+	webServer.Status.Rollout.Phase = webserversorgv1alpha1.RolloutPhaseCompleted
+	route = &routev1.Route{Spec: routev1.RouteSpec{To: routev1.RouteTargetReference{Name: "demo"}}}
+	reconciler.setRolloutBackends(webServer, route)
+	g.Expect(route.Spec.To.Weight).To(BeNil())
+	g.Expect(route.Spec.AlternateBackends).To(BeEmpty())
+}
+
+func TestProgressRollout(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webServer, stable, canary := newRolloutTest()
+	reconciler := newTestReconciler(webServer, stable, canary)
+
+	// The rollout doesn't move to the next step before the canary pods are available
+	result, err := reconciler.progressRollout(ctx, webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 300*time.Second, time.Second))
+	g.Expect(webServer.Status.Rollout.Step).To(Equal(int32(0)))
+	g.Expect(deploymentReplicas(t, reconciler.Client, "demo")).To(Equal(int32(3)))
+	g.Expect(deploymentReplicas(t, reconciler.Client, "demo-canary")).To(Equal(int32(1)))
+
+	setDeploymentAvailable(t, reconciler.Client, "demo-canary")
+	result, err = reconciler.progressRollout(ctx, webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(webServer.Status.Rollout.Phase).To(Equal(webserversorgv1alpha1.RolloutPhaseProgressing))
+	g.Expect(webServer.Status.Rollout.Step).To(Equal(int32(1)))
+	g.Expect(webServer.Status.Rollout.Weight).To(Equal(int32(50)))
+
+	// The replicas follow the weight of the new step
+	result, err = reconciler.progressRollout(ctx, webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(deploymentReplicas(t, reconciler.Client, "demo")).To(Equal(int32(2)))
+	g.Expect(deploymentReplicas(t, reconciler.Client, "demo-canary")).To(Equal(int32(2)))
+
+	// After the last step the canary gets all the traffic while the application is updated
+	setDeploymentAvailable(t, reconciler.Client, "demo-canary")
+	result, err = reconciler.progressRollout(ctx, webServer)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(webServer.Status.Rollout.Phase).To(Equal(webserversorgv1alpha1.RolloutPhasePromoting))
+	g.Expect(webServer.Status.Rollout.Weight).To(Equal(int32(100)))
+}
+
+func TestAbortRolloutAfterRestarts(t *testing.T) {
+	g := NewWithT(t)
+	webServer, stable, canary := newRolloutTest()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "demo-canary-0",
+			Namespace: "default",
+			Labels:    map[string]string{"WebServer": "demo", "deployment": "demo-canary"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "demo", Image: "quay.io/demo/app:2"}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:         "demo",
+			RestartCount: canaryMaxRestarts,
+		}}},
+	}
+	reconciler := newTestReconciler(webServer, stable, canary, pod)
+
+	result, err := reconciler.checkRolloutPods(context.Background(), webServer, canary)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(webServer.Status.Rollout.Phase).To(Equal(webserversorgv1alpha1.RolloutPhaseAborted))
+	g.Expect(webServer.Status.Rollout.Weight).To(BeZero())
+	g.Expect(webServer.Status.Rollout.AbortedGeneration).To(Equal(webServer.Generation))
+}
+
+func TestAbortRolloutAfterDeadline(t *testing.T) {
+	g := NewWithT(t)
+	webServer, stable, canary := newRolloutTest()
+	webServer.Status.Rollout.StepStartTime = &metav1.Time{Time: time.Now().Add(-301 * time.Second)}
+	reconciler := newTestReconciler(webServer, stable, canary)
+
+	result, err := reconciler.checkRolloutPods(context.Background(), webServer, canary)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(webServer.Status.Rollout.Phase).To(Equal(webserversorgv1alpha1.RolloutPhaseAborted))
+}
+
+func TestAbortedRolloutKeepsStableImage(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	webServer, stable, canary := newRolloutTest()
+	webServer.Status.Rollout.Phase = webserversorgv1alpha1.RolloutPhaseAborted
+	webServer.Status.Rollout.AbortedGeneration = webServer.Generation
+	reconciler := newTestReconciler(webServer, stable, canary)
+
+	_, err := reconciler.continueWithRollout(ctx, webServer, "quay.io/demo/app:2")
+	g.Expect(err).NotTo(HaveOccurred())
+	deployment := &kbappsv1.Deployment{}
+	g.Expect(reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo"}, deployment)).To(Succeed())
+	g.Expect(containerImage(&deployment.Spec.Template, "demo")).To(Equal("quay.io/demo/app:1"))
+	g.Expect(deployment.Spec.Replicas).To(Equal(&webServer.Spec.Replicas))
+
+	// The image is rolled out again once the WebServer changes
+	webServer.Generation++
+	result, err := reconciler.continueWithRollout(ctx, webServer, "quay.io/demo/app:2")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(webServer.Status.Rollout.Phase).To(Equal(webserversorgv1alpha1.RolloutPhaseProgressing))
+	g.Expect(webServer.Status.Rollout.Weight).To(Equal(int32(20)))
+}
+
+func TestCheckOwnedObjectsCanaryCleanup(t *testing.T) {
+	ctx := context.Background()
+	controlledBy := func(webServer *webserversorgv1alpha1.WebServer) []metav1.OwnerReference {
+		controller := true
+		return []metav1.OwnerReference{{
+			APIVersion: webserversorgv1alpha1.GroupVersion.String(),
+			Kind:       "WebServer",
+			Name:       webServer.Name,
+			UID:        webServer.UID,
+			Controller: &controller,
+		}}
+	}
+	newObjects := func(webServer *webserversorgv1alpha1.WebServer, stable, canary *kbappsv1.Deployment) []client.Object {
+		stable.OwnerReferences = controlledBy(webServer)
+		canary.OwnerReferences = controlledBy(webServer)
+		canaryService := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Name:            "demo-canary",
+			Namespace:       "default",
+			OwnerReferences: controlledBy(webServer),
+		}}
+		return []client.Object{webServer, stable, canary, canaryService}
+	}
+
+	t.Run("during the rollout", func(t *testing.T) {
+		g := NewWithT(t)
+		webServer, stable, canary := newRolloutTest()
+		reconciler := newTestReconciler(newObjects(webServer, stable, canary)...)
+		g.Expect(reconciler.checkOwnedObjects(ctx, webServer)).To(Succeed())
+		g.Expect(reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo-canary"}, &corev1.Service{})).To(Succeed())
+		g.Expect(reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo-canary"}, &kbappsv1.Deployment{})).To(Succeed())
+	})
+
+	t.Run("after the rollout", func(t *testing.T) {
+		g := NewWithT(t)
+		webServer, stable, canary := newRolloutTest()
+		webServer.Status.Rollout.Phase = webserversorgv1alpha1.RolloutPhaseCompleted
+		reconciler := newTestReconciler(newObjects(webServer, stable, canary)...)
+		g.Expect(reconciler.checkOwnedObjects(ctx, webServer)).To(Succeed())
+		err := reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo-canary"}, &corev1.Service{})
+		g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo-canary"}, &kbappsv1.Deployment{})
+		g.Expect(errors.IsNotFound(err)).To(BeTrue())
+		g.Expect(reconciler.Get(ctx, client.ObjectKey{Namespace: "default", Name: "demo"}, &kbappsv1.Deployment{})).To(Succeed())
+	})
+}
+
+func TestRolloutDefaults(t *testing.T) {
+	g := NewWithT(t)
+	webServer, _, _ := newRolloutTest()
+	// A WebServer created before the defaulting webhook
+	webServer.Spec.Rollout.Steps = nil
+	webServer.Spec.Rollout.StepSeconds = nil
+
+	g.Expect(rolloutSteps(webServer.Spec.Rollout)).To(Equal(webserversorgv1alpha1.DefaultRolloutSteps()))
+	canary := newTestReconciler().generateCanaryDeployment(webServer, "quay.io/demo/app:2", 1)
+	g.Expect(canary.Spec.MinReadySeconds).To(Equal(webserversorgv1alpha1.DefaultRolloutStepSeconds))
+	g.Expect(webServer.Spec.Rollout.StepSeconds).To(BeNil())
+}
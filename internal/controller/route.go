@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// configureRoute applies spec.route to the generated Route: the annotations, the TLS termination by the router
+// and the certificates read from the Secrets. Without spec.route the Route has no TLS, or passes the TLS
+// connections through to tomcat when routeHostname starts with tls.
+func (r *WebServerReconciler) configureRoute(ctx context.Context, webServer *webserversv1alpha1.WebServer, route *routev1.Route) error {
+	spec := webServer.Spec.Route
+	if spec == nil {
+		return nil
+	}
+	for key, value := range spec.Annotations {
+		route.Annotations[key] = value
+	}
+
+	switch spec.Termination {
+	case webserversv1alpha1.RouteTerminationEdge:
+		route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge}
+	case webserversv1alpha1.RouteTerminationReencrypt:
+		route.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationReencrypt}
+	}
+	if route.Spec.TLS == nil {
+		return nil
+	}
+	route.Spec.TLS.InsecureEdgeTerminationPolicy = routev1.InsecureEdgeTerminationPolicyType(spec.InsecureEdgeTerminationPolicy)
+	if route.Spec.TLS.Termination == routev1.TLSTerminationPassthrough {
+		// The router can't read the traffic, the certificates are the ones of tomcat
+		return nil
+	}
+
+	if spec.CertificateSecret != "" {
+		secret, err := r.getRouteSecret(ctx, webServer, spec.CertificateSecret)
+		if err != nil {
+			return err
+		}
+		route.Spec.TLS.Certificate = string(secret.Data[corev1.TLSCertKey])
+		route.Spec.TLS.Key = string(secret.Data[corev1.TLSPrivateKeyKey])
+		route.Spec.TLS.CACertificate = string(secret.Data["ca.crt"])
+	}
+	if spec.DestinationCASecret != "" && route.Spec.TLS.Termination == routev1.TLSTerminationReencrypt {
+		secret, err := r.getRouteSecret(ctx, webServer, spec.DestinationCASecret)
+		if err != nil {
+			return err
+		}
+		route.Spec.TLS.DestinationCACertificate = string(secret.Data["ca.crt"])
+	}
+	return nil
+}
+
+// getRouteSecret reads a Secret holding certificates of the Route.
+func (r *WebServerReconciler) getRouteSecret(ctx context.Context, webServer *webserversv1alpha1.WebServer, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.recordWarning(webServer, eventReasonSecretNotFound, "Secret "+name+" of the Route not found")
+		}
+		log.Error(err, "Failed to get the Secret of the Route: "+name)
+		return nil, err
+	}
+	return secret, nil
+}
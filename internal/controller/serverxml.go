@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ajpSecretEnv is the environment variable of the pods holding the secret of the AJP connector, tomcat replaces
+// ${AJP_SECRET} in server.xml with the EnvironmentPropertySource set in catalina.properties.
+const ajpSecretEnv = "AJP_SECRET"
+
+const (
+	// tomcatConfigToolDir is the directory of the pods where the init container of the operator image installs
+	// the tomcat-config tool merging the fragments into the configuration files of the image
+	tomcatConfigToolDir = "/jws-operator"
+	// fragmentSuffix is the suffix of the keys of the fragments in the ConfigMap webserver-<name>
+	fragmentSuffix = ".fragment"
+)
+
+// operatorImage returns the image of the operator, empty when the operator runs outside of the cluster.
+func operatorImage() string {
+	return os.Getenv("OPERATOR_IMAGE")
+}
+
+// mergesTomcatConfigFile returns true when the pods merge the elements of the operator and the fragments of
+// tomcatConfig into a configuration file of their image. Otherwise a file of tomcatConfig replaces it, or the
+// default file of tomcat with the elements of the operator when the image of the operator isn't known.
+func mergesTomcatConfigFile(webServer *webserversv1alpha1.WebServer, file string) bool {
+	if operatorImage() == "" {
+		return false
+	}
+	if spec := tomcatConfigFile(webServer, file); spec != nil {
+		return spec.Mode == webserversv1alpha1.TomcatConfigFragment
+	}
+	switch file {
+	case serverxml.ServerXml:
+		return customizesServerXml(webServer)
+	case serverxml.ContextXml:
+		return len(generateContextXmlElements(webServer)) > 0
+	}
+	return false
+}
+
+// mergesTomcatConfig returns true when the pods install the tomcat-config tool to merge configuration files.
+func mergesTomcatConfig(webServer *webserversv1alpha1.WebServer) bool {
+	for _, file := range serverxml.Files {
+		if mergesTomcatConfigFile(webServer, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// customizesServerXml returns true when the server.xml of the image is replaced by the one of the operator.
+func customizesServerXml(webServer *webserversv1alpha1.WebServer) bool {
+	return strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") || replicatesSessions(webServer) || webServer.Spec.PersistentLogsConfig.AccessLogs ||
+		tomcatConfigFile(webServer, serverxml.ServerXml) != nil || webServer.Spec.Connectors != nil
+}
+
+// usesAJP returns true when the WebServer has an AJP connector.
+func usesAJP(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.Connectors != nil && webServer.Spec.Connectors.AJP != nil
+}
+
+// usesEnvFiles returns true when the pods run the test.sh script of the operator before starting tomcat.
+func usesEnvFiles(webServer *webserversv1alpha1.WebServer) bool {
+	return customizesServerXml(webServer) || webServer.Spec.TomcatConfig != nil || webServer.Spec.PersistentLogsConfig.CatalinaLogs ||
+		persistsSessions(webServer) || usesSessionStore(webServer)
+}
+
+// renderTomcatConfig renders the configuration files of tomcat installed in the pods: the files and the fragments
+// of tomcatConfig, server.xml with the connectors, the session clustering and the persistent access logs,
+// context.xml with the persistent session manager or the one of the session store, and the configuration of the
+// Redisson client. The files merged into the ones of the image are rendered as fragments, under the key of the
+// file with fragmentSuffix, after checking that they can be added to the default file of tomcat. tlsSecret is
+// the Secret mounted in /tls, nil when it doesn't exist yet. The error explains why the configuration of the
+// WebServer is invalid.
+func (r *WebServerReconciler) renderTomcatConfig(webServer *webserversv1alpha1.WebServer, tlsSecret *corev1.Secret, configMaps map[string]*corev1.ConfigMap) (map[string]string, error) {
+	config := make(map[string]string)
+	for _, file := range serverxml.Files {
+		if file == serverxml.ServerXml && !customizesServerXml(webServer) {
+			continue
+		}
+		var elements []interface{}
+		switch file {
+		case serverxml.ServerXml:
+			elements = r.generateServerXmlElements(webServer, tlsSecret)
+			if mergesTomcatConfigFile(webServer, file) {
+				// The default server.xml of the operator has it, the clients are behind the router
+				elements = append([]interface{}{serverxml.RemoteIPValve()}, elements...)
+			}
+		case serverxml.ContextXml:
+			elements = generateContextXmlElements(webServer)
+		}
+		spec := tomcatConfigFile(webServer, file)
+		if spec == nil && file != serverxml.ServerXml && len(elements) == 0 {
+			continue
+		}
+
+		var root *serverxml.Element
+		var fragment []*serverxml.Element
+		if spec == nil {
+			var err error
+			if root, err = serverxml.DefaultFile(file); err != nil {
+				return nil, err
+			}
+		} else {
+			data, err := tomcatConfigData(spec, file, configMaps)
+			if err != nil {
+				return nil, err
+			}
+			if spec.Mode == webserversv1alpha1.TomcatConfigFragment {
+				root, err = serverxml.DefaultFile(file)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", tomcatConfigPath(file), err)
+				}
+				fragment, err = serverxml.ParseFragment(data)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s/%s is not well-formed: %w", tomcatConfigPath(file), spec.ConfigMap, tomcatConfigKey(spec, file), err)
+				}
+				for _, element := range fragment {
+					if err := serverxml.Add(file, root, element); err != nil {
+						return nil, fmt.Errorf("%s: %w", tomcatConfigPath(file), err)
+					}
+				}
+			} else {
+				root, err = serverxml.Parse(data)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s/%s is not well-formed: %w", tomcatConfigPath(file), spec.ConfigMap, tomcatConfigKey(spec, file), err)
+				}
+				if file != serverxml.ServerXml && len(elements) == 0 {
+					// The operator doesn't change the other files, they are installed as they are written
+					config[file] = string(data)
+					continue
+				}
+			}
+		}
+
+		parsed, err := addElements(file, root, elements)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tomcatConfigPath(file), err)
+		}
+		if mergesTomcatConfigFile(webServer, file) {
+			config[file+fragmentSuffix] = string(serverxml.RenderElements(append(fragment, parsed...)))
+		} else {
+			config[file] = string(root.Render())
+		}
+	}
+	if usesSessionStoreType(webServer, webserversv1alpha1.SessionStoreRedis) {
+		config[redissonYaml] = generateRedissonConfig()
+	}
+	return config, nil
+}
+
+// addElements adds the elements of the operator to a configuration file of tomcat and returns them parsed.
+func addElements(file string, root *serverxml.Element, elements []interface{}) ([]*serverxml.Element, error) {
+	var added []*serverxml.Element
+	for _, element := range elements {
+		parsed, err := serverxml.ParseElement(element)
+		if err != nil {
+			log.Error(err, "Failed to render "+file)
+			return nil, err
+		}
+		if err := serverxml.Add(file, root, parsed); err != nil {
+			return nil, err
+		}
+		added = append(added, parsed)
+	}
+	return added, nil
+}
+
+// generateServerXmlElements returns the connectors, the session clustering and the persistent access logs of the
+// WebServer added to server.xml.
+func (r *WebServerReconciler) generateServerXmlElements(webServer *webserversv1alpha1.WebServer, tlsSecret *corev1.Secret) []interface{} {
+	var elements []interface{}
+	connectors := webServer.Spec.Connectors
+	if connectors != nil && connectors.HTTP != nil {
+		connector := serverxml.HTTPConnector()
+		tuneConnector(&connector, connectors.HTTP)
+		elements = append(elements, connector)
+	}
+	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") {
+		if connector := r.generateHTTPSConnector(webServer, tlsSecret); connector != nil {
+			elements = append(elements, connector)
+		}
+	}
+	if usesAJP(webServer) {
+		elements = append(elements, generateAJPConnector(connectors.AJP))
+	}
+	if replicatesSessions(webServer) {
+		elements = append(elements, r.generateCluster(webServer))
+	}
+	if webServer.Spec.PersistentLogsConfig.AccessLogs {
+		// pod_name is set by catalina.sh or start.sh
+		elements = append(elements, serverxml.AccessLogValve("/opt/tomcat_logs", "access-${pod_name}", ".log"))
+	}
+	return elements
+}
+
+// generateContextXmlElements returns the session manager of the WebServer added to context.xml.
+func generateContextXmlElements(webServer *webserversv1alpha1.WebServer) []interface{} {
+	var elements []interface{}
+	if usesSessionStore(webServer) {
+		elements = append(elements, generateSessionStoreManager(webServer))
+	}
+	if persistsSessions(webServer) {
+		elements = append(elements, serverxml.PersistentManager(webServer.Spec.SessionClustering.PersistentDirectory))
+	}
+	return elements
+}
+
+// generateHTTPSConnector returns the HTTPS connector for the certificates of the TLS Secret, nil when the Secret
+// misses the certificate or the key. The client certificates are verified with the ca.crt of the Secret.
+func (r *WebServerReconciler) generateHTTPSConnector(webServer *webserversv1alpha1.WebServer, tlsSecret *corev1.Secret) *serverxml.Connector {
+	// "/tls" is the dir in which the secret's contents are mounted to the pod
+	caCertificateFile := ""
+	if tlsSecret != nil {
+		certificateKey, privateKey := "server.crt", "server.key"
+		if r.useCertManager(webServer) {
+			// The keys are renamed by the projected volume
+			certificateKey, privateKey = corev1.TLSCertKey, corev1.TLSPrivateKeyKey
+		}
+		if len(tlsSecret.Data[certificateKey]) == 0 || len(tlsSecret.Data[privateKey]) == 0 {
+			log.Info("Partial HTTPS configuration in Secret " + tlsSecret.Name + ", the https connector WILL NOT be configured.")
+			return nil
+		}
+		if len(tlsSecret.Data["ca.crt"]) != 0 {
+			caCertificateFile = "/tls/ca.crt"
+		}
+	}
+	certificateVerification := ""
+	if webServer.Spec.TLSConfig.CertificateVerification == "required" || webServer.Spec.TLSConfig.CertificateVerification == "optional" {
+		certificateVerification = webServer.Spec.TLSConfig.CertificateVerification
+	}
+	connector := serverxml.HTTPSConnector("/tls/server.crt", "/tls/server.key", caCertificateFile, certificateVerification)
+	if connectors := webServer.Spec.Connectors; connectors != nil && connectors.HTTPS != nil {
+		tuneConnector(&connector, &connectors.HTTPS.ConnectorSpec)
+		connector.SSLHostConfigs[0].Protocols = strings.Join(connectors.HTTPS.Protocols, ",")
+		connector.SSLHostConfigs[0].Ciphers = connectors.HTTPS.Ciphers
+	}
+	return &connector
+}
+
+// tuneConnector applies the tuning of the WebServer to an HTTP or HTTPS connector, tomcat keeps its defaults for
+// the fields which aren't set.
+func tuneConnector(connector *serverxml.Connector, spec *webserversv1alpha1.ConnectorSpec) {
+	if spec.Protocol == webserversv1alpha1.ConnectorProtocolNIO2 {
+		connector.Protocol = serverxml.HTTP11Nio2Protocol
+	}
+	if spec.MaxThreads != nil {
+		connector.MaxThreads = int(*spec.MaxThreads)
+	}
+	if spec.AcceptCount != nil {
+		connector.AcceptCount = int(*spec.AcceptCount)
+	}
+	if spec.ConnectionTimeout != nil {
+		connector.ConnectionTimeout = int(*spec.ConnectionTimeout)
+	}
+	connector.KeepAliveTimeout = toInt(spec.KeepAliveTimeout)
+	connector.MaxKeepAliveRequests = toInt(spec.MaxKeepAliveRequests)
+	connector.Compression = spec.Compression
+	connector.CompressibleMimeType = strings.Join(spec.CompressibleMimeTypes, ",")
+	connector.CompressionMinSize = toInt(spec.CompressionMinSize)
+	if spec.HTTP2 {
+		connector.UpgradeProtocols = []serverxml.UpgradeProtocol{{ClassName: serverxml.HTTP2Protocol}}
+	}
+}
+
+// generateAJPConnector returns the AJP connector, its secret is read from the environment of the pods.
+func generateAJPConnector(spec *webserversv1alpha1.AJPConnectorSpec) serverxml.Connector {
+	connector := serverxml.AJPConnector("${" + ajpSecretEnv + "}")
+	if spec.Protocol == webserversv1alpha1.ConnectorProtocolNIO2 {
+		connector.Protocol = serverxml.AJPNio2Protocol
+	}
+	if spec.MaxThreads != nil {
+		connector.MaxThreads = int(*spec.MaxThreads)
+	}
+	if spec.ConnectionTimeout != nil {
+		connector.ConnectionTimeout = int(*spec.ConnectionTimeout)
+	}
+	return connector
+}
+
+func toInt(value *int32) *int {
+	if value == nil {
+		return nil
+	}
+	converted := int(*value)
+	return &converted
+}
@@ -5,28 +5,17 @@ import (
 
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// GetOrCreateNewPrometheusService either returns the headless service or create
+// GetOrCreateNewPrometheusService applies the headless service, it returns nil when the service has just been created
 func (r *WebServerReconciler) GetOrCreateNewPrometheusService(w *webserversv1alpha1.WebServer, ctx context.Context, labels map[string]string) (*corev1.Service, error) {
-	service := &corev1.Service{}
-	if err := r.Get(ctx, client.ObjectKey{
-		Namespace: w.Namespace,
-		Name:      PrometeusServiceName(w),
-	}, service); err != nil {
-		if errors.IsNotFound(err) {
-			if err := r.Create(ctx, r.generatePrometeusService(w, labels)); err != nil {
-				if errors.IsAlreadyExists(err) {
-					return nil, nil
-				}
-				return nil, err
-			}
-			return nil, nil
-		}
+	service := r.generatePrometeusService(w, labels)
+	result, err := r.applyResource(ctx, w, service)
+	if err != nil || result != (ctrl.Result{}) {
+		return nil, err
 	}
 	return service, nil
 }
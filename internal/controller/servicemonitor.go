@@ -5,30 +5,19 @@ import (
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// GetOrCreateNewServiceMonitor either returns the headless service or create it
+// GetOrCreateNewServiceMonitor applies the ServiceMonitor, it returns nil when the ServiceMonitor has just been created
 func (r *WebServerReconciler) GetOrCreateNewServiceMonitor(w *webserversv1alpha1.WebServer, ctx context.Context, labels map[string]string) (*monitoringv1.ServiceMonitor, error) {
-	serviceMonitor := &monitoringv1.ServiceMonitor{}
-	if err := r.Get(ctx, client.ObjectKey{
-		Namespace: w.Namespace,
-		Name:      w.Name,
-	}, serviceMonitor); err != nil {
-		if errors.IsNotFound(err) {
-			if err := r.Create(ctx, r.generateServiceMonitor(w, labels)); err != nil {
-				if errors.IsAlreadyExists(err) {
-					return nil, nil
-				}
-				return nil, err
-			}
-			return nil, nil
-		}
+	serviceMonitor := r.generateServiceMonitor(w, labels)
+	result, err := r.applyResource(ctx, w, serviceMonitor)
+	if err != nil || result != (ctrl.Result{}) {
+		return nil, err
 	}
 	return serviceMonitor, nil
 }
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sessionClusteringSpec returns the sessionClustering of the WebServer, the defaults of tomcat when it isn't set.
+func sessionClusteringSpec(webServer *webserversv1alpha1.WebServer) webserversv1alpha1.SessionClusteringSpec {
+	if webServer.Spec.SessionClustering == nil {
+		return webserversv1alpha1.SessionClusteringSpec{}
+	}
+	return *webServer.Spec.SessionClustering
+}
+
+// replicatesSessions returns true when the pods replicate the sessions with a Cluster, an external session store
+// disables the session clustering.
+func replicatesSessions(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.UseSessionClustering && !usesSessionStore(webServer) && sessionClusteringSpec(webServer).Manager != webserversv1alpha1.SessionManagerPersistent
+}
+
+// persistsSessions returns true when the pods save the sessions in the persistent directory of sessionClustering.
+func persistsSessions(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.UseSessionClustering && !usesSessionStore(webServer) && sessionClusteringSpec(webServer).Manager == webserversv1alpha1.SessionManagerPersistent
+}
+
+// membershipServiceAccountName returns the name of the ServiceAccount of the pods using the Kubernetes membership
+// provider, its Role and its RoleBinding.
+func membershipServiceAccountName(webServer *webserversv1alpha1.WebServer) string {
+	return "webserver-" + webServer.Name
+}
+
+// usesKubernetesMembership returns true when the members of the Cluster are found with the API server: the
+// membershipProvider of sessionClustering, otherwise unless the operator couldn't grant the pods the permission
+// to list the pods (status.membershipProvider).
+func (r *WebServerReconciler) usesKubernetesMembership(webServer *webserversv1alpha1.WebServer) bool {
+	switch sessionClusteringSpec(webServer).MembershipProvider {
+	case webserversv1alpha1.MembershipProviderKubernetes:
+		return true
+	case webserversv1alpha1.MembershipProviderDNS:
+		return false
+	}
+	return webServer.Status.MembershipProvider != webserversv1alpha1.MembershipProviderDNS
+}
+
+// useSessionClusteringConfig creates what the membership provider needs: the ServiceAccount, the Role and the
+// RoleBinding allowing the pods to list the pods for Kubernetes, the headless Service for DNS. Without an explicit
+// membershipProvider it falls back to DNS when the operator isn't allowed to create the Role or the RoleBinding.
+// The provider in use is recorded in status.membershipProvider.
+func (r *WebServerReconciler) useSessionClusteringConfig(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	// The previous versions bound the view ClusterRole to the default ServiceAccount of the namespace
+	legacy := &rbac.RoleBinding{}
+	legacy.Name = "view-kubeping-" + webServer.Name
+	legacy.Namespace = webServer.Namespace
+	if err := r.deleteForWebServer(ctx, webServer, legacy, "RoleBinding"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	provider := sessionClusteringSpec(webServer).MembershipProvider
+	if provider != webserversv1alpha1.MembershipProviderDNS {
+		result, err := r.applyMembershipRBAC(ctx, webServer)
+		switch {
+		case errors.IsForbidden(err) && provider == "":
+			if webServer.Status.MembershipProvider != webserversv1alpha1.MembershipProviderDNS {
+				log.Info("Won't use the Kubernetes membership provider, missing permissions")
+				r.recordWarning(webServer, eventReasonDNSPing, "Missing permission to allow the pods to list the pods, falling back to the DNS membership provider")
+			}
+			provider = webserversv1alpha1.MembershipProviderDNS
+		case err != nil || result != (ctrl.Result{}):
+			return result, err
+		default:
+			provider = webserversv1alpha1.MembershipProviderKubernetes
+		}
+	}
+	if webServer.Status.MembershipProvider != provider {
+		webServer.Status.MembershipProvider = provider
+		if err := r.updateStatus(ctx, webServer); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if provider == webserversv1alpha1.MembershipProviderDNS {
+		// Check if a Service for DNSPing already exists, and if not create a new one
+		return r.applyResource(ctx, webServer, r.generateServiceForDNS(webServer))
+	}
+	return ctrl.Result{}, nil
+}
+
+// applyMembershipRBAC applies the ServiceAccount of the pods and the Role and RoleBinding allowing them to list
+// the pods of the namespace. The ServiceAccount keeps the imagePullSecrets of the default ServiceAccount, like the
+// pull secret of the internal registry on OpenShift, the pods would no longer pull their image without them.
+func (r *WebServerReconciler) applyMembershipRBAC(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	defaultServiceAccount := &corev1.ServiceAccount{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: webServer.Namespace, Name: "default"}, defaultServiceAccount)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get the default ServiceAccount")
+		return ctrl.Result{}, err
+	}
+	result, err := r.applyResource(ctx, webServer, r.generateServiceAccount(webServer, defaultServiceAccount.ImagePullSecrets))
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+	result, err = r.applyResource(ctx, webServer, r.generateRole(webServer))
+	if err != nil || result != (ctrl.Result{}) {
+		return result, err
+	}
+	return r.applyResource(ctx, webServer, r.generateRoleBinding(webServer))
+}
+
+// generateCluster returns the Cluster replicating the sessions with the manager, the Receiver and the static
+// members of sessionClustering.
+func (r *WebServerReconciler) generateCluster(webServer *webserversv1alpha1.WebServer) *serverxml.Cluster {
+	spec := sessionClusteringSpec(webServer)
+	var cluster *serverxml.Cluster
+	if r.usesKubernetesMembership(webServer) {
+		cluster = serverxml.CloudCluster(serverxml.KubernetesMembershipProvider)
+	} else {
+		cluster = serverxml.CloudCluster(serverxml.DNSMembershipProvider)
+	}
+
+	if spec.SendOptions == webserversv1alpha1.SendOptionsAsynchronous {
+		cluster.ChannelSendOptions = serverxml.AsynchronousSendOptions
+	}
+	switch spec.Manager {
+	case webserversv1alpha1.SessionManagerDelta:
+		cluster.Manager = serverxml.DeltaManager()
+	case webserversv1alpha1.SessionManagerBackup:
+		cluster.Manager = serverxml.BackupManager(cluster.ChannelSendOptions)
+	}
+
+	port := serverxml.ReplicationPort
+	if spec.ReplicationPort != nil {
+		port = int(*spec.ReplicationPort)
+		cluster.Channel.Receiver = serverxml.NioReceiver(port)
+	}
+	if len(spec.StaticMembers) > 0 {
+		cluster.Channel.Interceptors = serverxml.StaticMembershipInterceptors(port, spec.StaticMembers...)
+	}
+	return cluster
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The environment variables of the pods holding the connection details of the session store, tomcat replaces
+// them in context.xml with the EnvironmentPropertySource set in catalina.properties and Redisson in its YAML file.
+const (
+	sessionStoreAddressEnv  = "SESSION_STORE_ADDRESS"
+	sessionStoreURIEnv      = "SESSION_STORE_URI"
+	sessionStoreURLEnv      = "SESSION_STORE_URL"
+	sessionStoreUsernameEnv = "SESSION_STORE_USERNAME"
+	sessionStorePasswordEnv = "SESSION_STORE_PASSWORD"
+)
+
+// redissonYaml is the configuration file of the Redisson client, installed next to context.xml.
+const redissonYaml = "redisson.yaml"
+
+// usesSessionStore returns true when the sessions are kept in an external store, the session clustering is
+// disabled then.
+func usesSessionStore(webServer *webserversv1alpha1.WebServer) bool {
+	return webServer.Spec.SessionStore != nil
+}
+
+// usesSessionStoreType returns true when the sessions are kept in an external store of that type.
+func usesSessionStoreType(webServer *webserversv1alpha1.WebServer, storeType string) bool {
+	return usesSessionStore(webServer) && webServer.Spec.SessionStore.Type == storeType
+}
+
+// generateSessionStoreManager returns the Manager of context.xml keeping the sessions in the external store, the
+// connection details are read from the environment of the pods.
+func generateSessionStoreManager(webServer *webserversv1alpha1.WebServer) *serverxml.Manager {
+	store := webServer.Spec.SessionStore
+	switch store.Type {
+	case webserversv1alpha1.SessionStoreRedis:
+		return serverxml.RedissonManager("${catalina.base}/conf/" + redissonYaml)
+	case webserversv1alpha1.SessionStoreInfinispan:
+		return serverxml.HotRodManager("${" + sessionStoreURIEnv + "}")
+	}
+	return serverxml.JDBCManager(store.DriverName, "${"+sessionStoreURLEnv+"}", "${"+sessionStoreUsernameEnv+"}", "${"+sessionStorePasswordEnv+"}")
+}
+
+// generateRedissonConfig returns the configuration of the Redisson client connecting to a single Redis server, the
+// password is appended by test.sh when the Secret has one.
+func generateRedissonConfig() string {
+	return "singleServerConfig:\n" +
+		"  address: \"${" + sessionStoreAddressEnv + "}\"\n"
+}
+
+// generateSessionStoreEnvVars returns the environment variables of the pods taken from the Secret of the session
+// store.
+func generateSessionStoreEnvVars(webServer *webserversv1alpha1.WebServer) []corev1.EnvVar {
+	store := webServer.Spec.SessionStore
+	fromSecret := func(name, key string, optional bool) corev1.EnvVar {
+		return corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: store.SecretName},
+					Key:                  key,
+					Optional:             &optional,
+				},
+			},
+		}
+	}
+	switch store.Type {
+	case webserversv1alpha1.SessionStoreRedis:
+		return []corev1.EnvVar{
+			fromSecret(sessionStoreAddressEnv, webserversv1alpha1.SessionStoreAddressKey, false),
+			fromSecret(sessionStorePasswordEnv, webserversv1alpha1.SessionStorePasswordKey, true),
+		}
+	case webserversv1alpha1.SessionStoreInfinispan:
+		return []corev1.EnvVar{
+			fromSecret(sessionStoreURIEnv, webserversv1alpha1.SessionStoreURIKey, false),
+		}
+	}
+	return []corev1.EnvVar{
+		fromSecret(sessionStoreURLEnv, webserversv1alpha1.SessionStoreURLKey, false),
+		fromSecret(sessionStoreUsernameEnv, webserversv1alpha1.SessionStoreUsernameKey, false),
+		fromSecret(sessionStorePasswordEnv, webserversv1alpha1.SessionStorePasswordKey, false),
+	}
+}
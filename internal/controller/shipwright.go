@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// hasShipwright checks if the BuildRun kind of Shipwright is registered in the cluster.
+func hasShipwright(c *rest.Config) bool {
+	return CustomResourceDefinitionExists(shipwrightBuildRunGVK, c)
+}
+
+// shipwrightBuildName returns the name of the Shipwright Build of the application.
+func shipwrightBuildName(webServer *webserversv1alpha1.WebServer) string {
+	return webServer.Spec.ApplicationName + "-build"
+}
+
+// buildRunName returns the name of the BuildRun of an attempt at building hash, the hash isn't a valid name.
+func buildRunName(webServer *webserversv1alpha1.WebServer, hash string, attempt int32) string {
+	return fmt.Sprintf("%s-%08x-%d", shipwrightBuildName(webServer), crc32.ChecksumIEEE([]byte(hash)), attempt)
+}
+
+// buildWithShipwright applies the Shipwright Build of the application and runs a BuildRun for each attempt, a failed
+// BuildRun is retried after its backoff delay up to the backoff limit. The BuildRuns of the previous versions of the
+// WebServer are deleted.
+func (r *WebServerReconciler) buildWithShipwright(ctx context.Context, webServer *webserversv1alpha1.WebServer) (bool, ctrl.Result, error) {
+	result, err := r.applyResource(ctx, webServer, r.generateShipwrightBuild(webServer))
+	if err != nil || result != (ctrl.Result{}) {
+		return false, result, err
+	}
+
+	hash := r.getWebServerHash(webServer)
+	buildRuns := &unstructured.UnstructuredList{}
+	buildRuns.SetGroupVersionKind(shipwrightBuildRunGVK.GroupVersion().WithKind(shipwrightBuildRunGVK.Kind + "List"))
+	err = r.List(ctx, buildRuns,
+		client.InNamespace(webServer.Namespace),
+		client.MatchingLabels{webServerLabel: webServer.Name},
+	)
+	if err != nil {
+		log.Error(err, "unable to list the BuildRuns of WebServer "+webServer.Name)
+		return false, ctrl.Result{}, err
+	}
+	var current *unstructured.Unstructured
+	rebuilding := false
+	for i := range buildRuns.Items {
+		buildRun := &buildRuns.Items[i]
+		if buildRun.GetLabels()["webserver-hash"] == hash {
+			if current == nil || buildAttempt(buildRun) > buildAttempt(current) {
+				current = buildRun
+			}
+			continue
+		}
+		if buildRun.GetDeletionTimestamp() != nil {
+			continue
+		}
+		// The Pods of the BuildRun are deleted with it
+		err = r.Delete(ctx, buildRun, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete BuildRun: "+buildRun.GetName())
+			return false, ctrl.Result{}, err
+		}
+		rebuilding = true
+	}
+	if rebuilding {
+		log.Info("Webserver hash changed: Delete BuildRuns")
+		r.recordEvent(webServer, eventReasonRebuilding, "WebServer changed, rebuilding the application with a new BuildRun")
+	}
+
+	if current != nil {
+		built, result, err := r.checkBuildRun(ctx, webServer, current)
+		if err != nil || result != (ctrl.Result{}) || built {
+			return built, result, err
+		}
+		if last := lastBuild(webServer, hash); last == nil || last.Phase != string(corev1.PodFailed) {
+			// The BuildRun is still running, or the BuildRun of the next attempt isn't in the cache yet
+			return false, ctrl.Result{}, nil
+		}
+	}
+	if buildGaveUp(webServer, hash) {
+		// The failed BuildRuns are kept, the WebServer isn't deployed until it changes
+		return false, ctrl.Result{}, nil
+	}
+	result, err = r.startBuildRun(ctx, webServer, hash)
+	return false, result, err
+}
+
+// startBuildRun records and creates the BuildRun of the next attempt, a failed build is retried once its backoff
+// delay has elapsed.
+func (r *WebServerReconciler) startBuildRun(ctx context.Context, webServer *webserversv1alpha1.WebServer, hash string) (ctrl.Result, error) {
+	if last := lastBuild(webServer, hash); last != nil && last.Phase == string(corev1.PodFailed) {
+		wait := buildBackoff(last.Attempt)
+		if last.CompletionTime != nil {
+			wait -= time.Since(last.CompletionTime.Time)
+		}
+		if wait > 0 {
+			log.Info("Retrying the build in " + wait.Round(time.Second).String())
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+	attempt := nextBuildAttempt(webServer, hash)
+	if err := r.recordBuildStart(ctx, webServer, hash, attempt); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	buildRun := r.generateShipwrightBuildRun(webServer, hash, attempt)
+	log.Info("Creating a new BuildRun: " + buildRun.GetName() + " Namespace: " + buildRun.GetNamespace())
+	if err := r.Create(ctx, buildRun); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "Failed to create a new BuildRun: "+buildRun.GetName()+" Namespace: "+buildRun.GetNamespace())
+		r.recordWarning(webServer, eventReasonCreateFailed, "Failed to create BuildRun "+buildRun.GetName()+": "+err.Error())
+		return ctrl.Result{}, err
+	}
+	r.recordEvent(webServer, eventReasonBuildStarted, "Started BuildRun "+buildRun.GetName())
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// checkBuildRun records the state of the BuildRun in the status and reports it in the BuildSucceeded condition, it
+// returns true once the application is built.
+func (r *WebServerReconciler) checkBuildRun(ctx context.Context, webServer *webserversv1alpha1.WebServer, buildRun *unstructured.Unstructured) (bool, ctrl.Result, error) {
+	previous := webServer.Status.DeepCopy().Builds
+	record := buildRecord(webServer, buildRun.GetLabels()["webserver-hash"], buildAttempt(buildRun))
+	updateBuildRunRecord(record, buildRun)
+
+	changed := false
+	built := false
+	limit := buildBackoffLimit(webServer)
+	switch record.Phase {
+	case string(corev1.PodSucceeded):
+		message := "Application built by BuildRun " + buildRun.GetName()
+		if r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionTrue, reasonBuildCompleted, message) {
+			r.recordEvent(webServer, eventReasonBuildSucceeded, message)
+			changed = true
+		}
+		built = true
+	case string(corev1.PodFailed):
+		reason := reasonBuildFailed
+		message := fmt.Sprintf("Application build failed (attempt %d of %d), retrying in %s: %s", record.Attempt, limit+1, buildBackoff(record.Attempt), record.FailureReason)
+		if record.Attempt > limit {
+			reason = reasonBuildBackoffLimitExceeded
+			message = fmt.Sprintf("Application build failed %d times, giving up until the WebServer changes: %s", record.Attempt, record.FailureReason)
+		}
+		log.Info(message)
+		if r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reason, message) {
+			r.recordWarning(webServer, eventReasonBuildFailed, "BuildRun "+buildRun.GetName()+" failed: "+message)
+			changed = true
+		}
+	case string(corev1.PodRunning):
+		log.Info("Application is still being built")
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reasonBuildRunning, "Application is still being built")
+	default:
+		log.Info("Application build pending")
+		changed = r.setCondition(webServer, webserversv1alpha1.ConditionBuildSucceeded, metav1.ConditionFalse, reasonBuildPending, "Application build pending")
+	}
+	if changed || !equality.Semantic.DeepEqual(previous, webServer.Status.Builds) {
+		// The failure must be recorded before the next attempt, its backoff delay is counted from it
+		if err := r.Status().Update(ctx, webServer); err != nil {
+			if errors.IsConflict(err) {
+				return false, ctrl.Result{Requeue: true}, nil
+			}
+			log.Error(err, "Failed to update the status of WebServer")
+			return false, ctrl.Result{}, err
+		}
+	}
+	return built, ctrl.Result{}, nil
+}
+
+// updateBuildRunRecord copies the state of the BuildRun to its record: the Succeeded condition, the times, the
+// commit of the sources, the digest of the image and the details of a failure.
+func updateBuildRunRecord(record *webserversv1alpha1.BuildRecord, buildRun *unstructured.Unstructured) {
+	conditions, _, _ := unstructured.NestedSlice(buildRun.Object, "status", "conditions")
+	status, reason, message := "", "", ""
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok || condition["type"] != "Succeeded" {
+			continue
+		}
+		status, _ = condition["status"].(string)
+		reason, _ = condition["reason"].(string)
+		message, _ = condition["message"].(string)
+	}
+	switch {
+	case status == string(metav1.ConditionTrue):
+		record.Phase = string(corev1.PodSucceeded)
+	case status == string(metav1.ConditionFalse):
+		record.Phase = string(corev1.PodFailed)
+		record.FailureReason = strings.TrimSpace(reason + " " + message)
+		record.LogTail, _, _ = unstructured.NestedString(buildRun.Object, "status", "failureDetails", "message")
+	case reason == "" || reason == "Pending":
+		record.Phase = string(corev1.PodPending)
+	default:
+		record.Phase = string(corev1.PodRunning)
+	}
+	if startTime := nestedTime(buildRun, "status", "startTime"); startTime != nil {
+		record.StartTime = startTime
+	}
+	if completionTime := nestedTime(buildRun, "status", "completionTime"); completionTime != nil {
+		record.CompletionTime = completionTime
+	}
+	if commit, _, _ := unstructured.NestedString(buildRun.Object, "status", "source", "git", "commitSha"); commit != "" {
+		record.Commit = commit
+	}
+	if digest, _, _ := unstructured.NestedString(buildRun.Object, "status", "output", "digest"); digest != "" {
+		record.ImageDigest = digest
+	}
+	if record.Phase == string(corev1.PodFailed) && record.CompletionTime == nil {
+		now := metav1.Now()
+		record.CompletionTime = &now
+	}
+}
+
+// nestedTime returns the time of the field of the resource, nil when it isn't set.
+func nestedTime(resource *unstructured.Unstructured, fields ...string) *metav1.Time {
+	value, _, _ := unstructured.NestedString(resource.Object, fields...)
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: parsed}
+}
+
+// generateShipwrightBuild returns the Shipwright Build of the application: the strategy of the builder builds the
+// image from the sources and pushes it with the push secret.
+func (r *WebServerReconciler) generateShipwrightBuild(webServer *webserversv1alpha1.WebServer) *unstructured.Unstructured {
+	webApp := webServer.Spec.WebImage.WebApp
+	shipwright := webApp.Builder.Shipwright
+	git := map[string]interface{}{
+		"url": webApp.SourceRepositoryURL,
+	}
+	if webApp.SourceRepositoryRef != "" {
+		git["revision"] = webApp.SourceRepositoryRef
+	}
+	source := map[string]interface{}{
+		"type": "Git",
+		"git":  git,
+	}
+	if webApp.SourceRepositoryContextDir != "" {
+		source["contextDir"] = webApp.SourceRepositoryContextDir
+	}
+	strategyKind := shipwright.StrategyKind
+	if strategyKind == "" {
+		strategyKind = "ClusterBuildStrategy"
+	}
+	spec := map[string]interface{}{
+		"source": source,
+		"strategy": map[string]interface{}{
+			"name": shipwright.StrategyName,
+			"kind": strategyKind,
+		},
+		"output": map[string]interface{}{
+			"image":      webApp.WebAppWarImage,
+			"pushSecret": webApp.WebAppWarImagePushSecret,
+		},
+		"timeout": strconv.FormatInt(buildActiveDeadlineSeconds(webServer), 10) + "s",
+		// Shipwright deletes the BuildRuns that don't fit in the records
+		"retention": map[string]interface{}{
+			"succeededLimit": int64(1),
+			"failedLimit":    int64(maxBuildRecords),
+		},
+	}
+
+	build := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	build.SetGroupVersionKind(shipwrightBuildGVK)
+	build.SetName(shipwrightBuildName(webServer))
+	build.SetNamespace(webServer.Namespace)
+	build.SetLabels(map[string]string{webServerLabel: webServer.Name})
+
+	err := controllerutil.SetControllerReference(webServer, build, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return build
+}
+
+// generateShipwrightBuildRun returns the BuildRun of an attempt at building hash.
+func (r *WebServerReconciler) generateShipwrightBuildRun(webServer *webserversv1alpha1.WebServer, hash string, attempt int32) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"build": map[string]interface{}{
+			"name": shipwrightBuildName(webServer),
+		},
+	}
+
+	buildRun := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	buildRun.SetGroupVersionKind(shipwrightBuildRunGVK)
+	buildRun.SetName(buildRunName(webServer, hash, attempt))
+	buildRun.SetNamespace(webServer.Namespace)
+	buildRun.SetLabels(map[string]string{
+		"webserver-hash": hash,
+		webServerLabel:   webServer.Name,
+	})
+	buildRun.SetAnnotations(map[string]string{buildAttemptAnnotation: strconv.Itoa(int(attempt))})
+
+	err := controllerutil.SetControllerReference(webServer, buildRun, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return buildRun
+}
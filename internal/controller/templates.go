@@ -3,18 +3,19 @@ package controller
 import (
 	"os"
 	"strconv"
-	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
 
 	buildv1 "github.com/openshift/api/build/v1"
 	imagev1 "github.com/openshift/api/image/v1"
 	routev1 "github.com/openshift/api/route/v1"
 
 	kbappsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -48,9 +49,20 @@ func (r *WebServerReconciler) generateRoutingService(webServer *webserversv1alph
 		},
 	}
 
+	if usesAJP(webServer) {
+		// For the proxies sending the requests with AJP
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:       "ajp",
+			Port:       serverxml.AJPPort,
+			TargetPort: intstr.FromInt(serverxml.AJPPort),
+		})
+	}
 	if webServer.Spec.Volume != nil && len(webServer.Spec.Volume.VolumeClaimTemplates) > 0 {
 		service.Spec.ClusterIP = "None"
 	}
+	if deployment, ok := r.rolloutTrafficDeployment(webServer); ok && deployment != "" {
+		service.Spec.Selector["deployment"] = deployment
+	}
 
 	err := controllerutil.SetControllerReference(webServer, service, r.Scheme)
 	if err != nil {
@@ -63,34 +75,65 @@ func (r *WebServerReconciler) generateRoutingService(webServer *webserversv1alph
 
 }
 
-// Create something like:
-// oc policy add-role-to-user view system:serviceaccount:tomcat-in-the-cloud:default -n tomcat-in-the-cloud
-// does:
-// apiVersion: rbac.authorization.k8s.io/v1
-// kind: RoleBinding
-// metadata:
-//   name: view
-//   namespace: tomcat-in-the-cloud
-// roleRef:
-//   apiGroup: rbac.authorization.k8s.io
-//   kind: ClusterRole
-//   name: view
-// subjects:
-// - kind: ServiceAccount
-//   name: default
-//   namespace: tomcat-in-the-cloud
-
-func (r *WebServerReconciler) generateRoleBinding(webServer *webserversv1alpha1.WebServer, rolename string) *rbac.RoleBinding {
+// generateCanaryService returns the Service of the canary pods, the Route sends them a part of the traffic during a rollout.
+func (r *WebServerReconciler) generateCanaryService(webServer *webserversv1alpha1.WebServer, port int) *corev1.Service {
+	service := r.generateRoutingService(webServer, port)
+	service.Name = canaryName(webServer)
+	service.Spec.Selector = map[string]string{
+		"deployment": canaryName(webServer),
+		"WebServer":  webServer.Name,
+	}
+	return service
+}
+
+// generateServiceAccount returns the ServiceAccount of the pods using the Kubernetes membership provider, with the
+// imagePullSecrets the pods got from the default ServiceAccount of the namespace.
+func (r *WebServerReconciler) generateServiceAccount(webServer *webserversv1alpha1.WebServer, imagePullSecrets []corev1.LocalObjectReference) *corev1.ServiceAccount {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta:       r.generateObjectMeta(webServer, membershipServiceAccountName(webServer)),
+		ImagePullSecrets: imagePullSecrets,
+	}
+
+	err := controllerutil.SetControllerReference(webServer, serviceAccount, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return serviceAccount
+}
+
+// generateRole returns the Role allowing the Kubernetes membership provider to find the pods of the namespace,
+// nothing else.
+func (r *WebServerReconciler) generateRole(webServer *webserversv1alpha1.WebServer) *rbac.Role {
+	role := &rbac.Role{
+		ObjectMeta: r.generateObjectMeta(webServer, membershipServiceAccountName(webServer)),
+		Rules: []rbac.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"get", "list", "watch"},
+		}},
+	}
+
+	err := controllerutil.SetControllerReference(webServer, role, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return role
+}
+
+// generateRoleBinding grants the Role of generateRole to the ServiceAccount of the pods.
+func (r *WebServerReconciler) generateRoleBinding(webServer *webserversv1alpha1.WebServer) *rbac.RoleBinding {
 	rolebinding := &rbac.RoleBinding{
-		ObjectMeta: r.generateObjectMeta(webServer, rolename),
+		ObjectMeta: r.generateObjectMeta(webServer, membershipServiceAccountName(webServer)),
 		RoleRef: rbac.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "ClusterRole",
-			Name:     "view",
+			Kind:     "Role",
+			Name:     membershipServiceAccountName(webServer),
 		},
 		Subjects: []rbac.Subject{{
 			Kind:      "ServiceAccount",
-			Name:      "default",
+			Name:      membershipServiceAccountName(webServer),
 			Namespace: webServer.Namespace,
 		}},
 	}
@@ -128,12 +171,12 @@ func (r *WebServerReconciler) generateServiceForDNS(webServer *webserversv1alpha
 	return service
 }
 
-// Script for the cluster and tls in server.xml
-func (r *WebServerReconciler) generateConfigMapForDNSTLS(webServer *webserversv1alpha1.WebServer) *corev1.ConfigMap {
+// configuration files of tomcat with the cluster and tls, and the script installing them
+func (r *WebServerReconciler) generateConfigMapForDNSTLS(webServer *webserversv1alpha1.WebServer, config map[string]string) *corev1.ConfigMap {
 
 	cmap := &corev1.ConfigMap{
 		ObjectMeta: r.generateObjectMeta(webServer, "webserver-"+webServer.Name),
-		Data:       r.generateCommandForServerXml(webServer),
+		Data:       r.generateCommandForServerXml(webServer, config),
 	}
 
 	err := controllerutil.SetControllerReference(webServer, cmap, r.Scheme)
@@ -209,8 +252,12 @@ func (r *WebServerReconciler) generateConfigMapForLoggingProperties(webServer *w
 // pvc for saving logs
 func (r *WebServerReconciler) generatePersistentVolumeClaimForLogging(webServer *webserversv1alpha1.WebServer) *corev1.PersistentVolumeClaim {
 
+	objectMeta := r.generateObjectMeta(webServer, "volume-pvc-"+webServer.Name)
+	objectMeta.Labels = map[string]string{
+		webServerLabel: webServer.Name,
+	}
 	pvc := &corev1.PersistentVolumeClaim{
-		ObjectMeta: r.generateObjectMeta(webServer, "volume-pvc-"+webServer.Name),
+		ObjectMeta: objectMeta,
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}, // works only if you remove "default" from StorageClass
 			Resources: corev1.VolumeResourceRequirements{
@@ -239,12 +286,12 @@ func (r *WebServerReconciler) generatePersistentVolumeClaimForLogging(webServer
 	return pvc
 }
 
-// Custom build script for the pod builder
+// Build script and Dockerfile for the pod builder
 func (r *WebServerReconciler) generateConfigMapForCustomBuildScript(webServer *webserversv1alpha1.WebServer) *corev1.ConfigMap {
 
 	cmap := &corev1.ConfigMap{
 		ObjectMeta: r.generateObjectMeta(webServer, "webserver-bd-"+webServer.Name),
-		Data:       r.generateCommandForBuider(webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript),
+		Data:       r.generateCommandForBuider(webServer),
 	}
 
 	err := controllerutil.SetControllerReference(webServer, cmap, r.Scheme)
@@ -255,20 +302,159 @@ func (r *WebServerReconciler) generateConfigMapForCustomBuildScript(webServer *w
 	return cmap
 }
 
-func (r *WebServerReconciler) generateBuildPod(webServer *webserversv1alpha1.WebServer) *corev1.Pod {
-	command := []string{}
-	args := []string{}
-	if webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" {
-		command = []string{"/bin/sh"}
-		args = []string{"/build/my-files/build.sh"}
-	}
+// generateBuildJob returns the Job building the application, it retries the failed Pods up to the backoff limit
+// within the active deadline.
+func (r *WebServerReconciler) generateBuildJob(webServer *webserversv1alpha1.WebServer) *batchv1.Job {
 	name := webServer.Spec.ApplicationName + "-build"
 	objectMeta := r.generateObjectMeta(webServer, name)
 	// Don't use r.generateLabelsForWeb(webServer) here, that is ONLY for applicaion pods.
 	objectMeta.Labels = map[string]string{
 		"webserver-hash": r.getWebServerHash(webServer),
+		webServerLabel:   webServer.Name,
+	}
+	backoffLimit := buildBackoffLimit(webServer)
+	activeDeadlineSeconds := buildActiveDeadlineSeconds(webServer)
+	job := &batchv1.Job{
+		ObjectMeta: objectMeta,
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			// The deadline covers all the attempts of the build
+			ActiveDeadlineSeconds: &activeDeadlineSeconds,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"webserver-hash": objectMeta.Labels["webserver-hash"],
+						webServerLabel:   webServer.Name,
+					},
+				},
+				Spec: r.generateBuildPodSpec(webServer),
+			},
+		},
 	}
+
+	err := controllerutil.SetControllerReference(webServer, job, r.Scheme)
+	if err != nil {
+		log.Error(err, "SetControllerReference was not successful")
+	}
+
+	return job
+}
+
+// generateBuildPodSpec returns the Pod of the build Job. With Buildah the builder image builds the war in /mnt and
+// buildah builds and pushes the image as a non-root user, with Script the builder image does it all.
+func (r *WebServerReconciler) generateBuildPodSpec(webServer *webserversv1alpha1.WebServer) corev1.PodSpec {
 	terminationGracePeriodSeconds := int64(60)
+	if buildStrategy(webServer) == webserversv1alpha1.BuildStrategyBuildah {
+		workspace := corev1.VolumeMount{
+			Name:      "workspace",
+			MountPath: "/mnt",
+		}
+		return corev1.PodSpec{
+			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+			// The Job retries a failed build with a new Pod
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: append(r.generateVolumePodBuilder(webServer),
+				corev1.Volume{
+					Name: "workspace",
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
+				corev1.Volume{
+					Name: "containers-storage",
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
+				corev1.Volume{
+					Name: "docker-config",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{
+							SecretName: webServer.Spec.WebImage.WebApp.WebAppWarImagePushSecret,
+							Items: []corev1.KeyToPath{{
+								Key:  corev1.DockerConfigJsonKey,
+								Path: "config.json",
+							}},
+						},
+					},
+				},
+			),
+			ImagePullSecrets: r.generateimagePullSecrets(webServer),
+			InitContainers: []corev1.Container{
+				{
+					Name:    "war",
+					Image:   webServer.Spec.WebImage.WebApp.Builder.Image,
+					Command: []string{"/bin/sh"},
+					Args:    []string{"/build/my-files/build.sh"},
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: &[]bool{false}[0],
+					},
+					Env:                      r.generateEnvBuild(webServer),
+					VolumeMounts:             append(r.generateVolumeMountPodBuilder(webServer), workspace),
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    "image",
+					Image:   buildahImage(),
+					Command: []string{"/bin/sh", "-c"},
+					Args: []string{
+						"set -e\n" +
+							"buildah --storage-driver=vfs bud --isolation=chroot --build-arg webAppSourceImage=${webAppSourceImage} " +
+							"-f /build/my-files/Dockerfile -t ${webAppWarImage} /mnt\n" +
+							// The digest of the pushed image is recorded in the status of the WebServer
+							"buildah --storage-driver=vfs push --digestfile /dev/termination-log ${webAppWarImage}\n",
+					},
+					Env: []corev1.EnvVar{
+						{
+							Name:  "webAppSourceImage",
+							Value: webServer.Spec.WebImage.ApplicationImage,
+						},
+						{
+							Name:  "webAppWarImage",
+							Value: webServer.Spec.WebImage.WebApp.WebAppWarImage,
+						},
+						{
+							Name:  "REGISTRY_AUTH_FILE",
+							Value: "/build/auth/config.json",
+						},
+					},
+					// The build user of the buildah images, the vfs storage driver and the chroot isolation
+					// need neither root nor privileges
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser:    &[]int64{1000}[0],
+						RunAsNonRoot: &[]bool{true}[0],
+						Privileged:   &[]bool{false}[0],
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						workspace,
+						{
+							Name:      "webserver-bd-" + webServer.Name,
+							MountPath: "/build/my-files",
+						},
+						{
+							Name:      "containers-storage",
+							MountPath: "/home/build/.local/share/containers",
+						},
+						{
+							Name:      "docker-config",
+							MountPath: "/build/auth",
+							ReadOnly:  true,
+						},
+					},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+		}
+	}
+
+	command := []string{}
+	args := []string{}
+	if webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" {
+		command = []string{"/bin/sh"}
+		args = []string{"/build/my-files/build.sh"}
+	}
 	serviceAccountName := ""
 	var securityContext *corev1.SecurityContext
 	if r.isOpenShift {
@@ -287,71 +473,65 @@ func (r *WebServerReconciler) generateBuildPod(webServer *webserversv1alpha1.Web
 			*/
 		}
 	} else {
+		// The build scripts of the builder images run buildah, it needs the privileges outside of OpenShift
 		securityContext = &corev1.SecurityContext{
 			Privileged: &[]bool{true}[0],
 		}
 	}
-	pod := &corev1.Pod{
-		ObjectMeta: objectMeta,
-		Spec: corev1.PodSpec{
-			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
-			RestartPolicy:                 "OnFailure",
-			Volumes:                       r.generateVolumePodBuilder(webServer),
-			/* from openshift BuildConfig: Use ServiceAccountName: "builder", */
-			ServiceAccountName: serviceAccountName,
-			/* secret to pull the image */
-			ImagePullSecrets: r.generateimagePullSecrets(webServer),
-			/* Problems: SeccompProfileTypeUnconfined, SeccompProfileTypeLocalhost */
-			/*
-				SecurityContext: &corev1.PodSecurityContext{
-					SeccompProfile: &corev1.SeccompProfile{
-						Type: corev1.SeccompProfileTypeRuntimeDefault,
-					},
+	return corev1.PodSpec{
+		TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+		// The Job retries a failed build with a new Pod
+		RestartPolicy: corev1.RestartPolicyNever,
+		Volumes:       r.generateVolumePodBuilder(webServer),
+		/* from openshift BuildConfig: Use ServiceAccountName: "builder", */
+		ServiceAccountName: serviceAccountName,
+		/* secret to pull the image */
+		ImagePullSecrets: r.generateimagePullSecrets(webServer),
+		/* Problems: SeccompProfileTypeUnconfined, SeccompProfileTypeLocalhost */
+		/*
+			SecurityContext: &corev1.PodSecurityContext{
+				SeccompProfile: &corev1.SeccompProfile{
+					Type: corev1.SeccompProfileTypeRuntimeDefault,
 				},
-			*/
-			Containers: []corev1.Container{
-				{
-					Name:  "war",
-					Image: webServer.Spec.WebImage.WebApp.Builder.Image,
-					// Default uses the default build.sh file in image
-					Command: command,
-					Args:    args,
-					// Actually the SA doesn't have that permission :( so that won't work with giving permissions.
-					// Doing the following allows it:
-					// oc adm policy add-scc-to-group privileged system:serviceaccounts:tomcat-in-the-cloud
-					/*
-						SecurityContext: &corev1.SecurityContext{
-							Privileged: &[]bool{true}[0],
-						},
-					*/
-					// here the permissions have to be added in a SecurityContextConstraint
-					// for example https://github.com/jfclere/tomcat-kubernetes/blob/main/scc.yaml
-					// kubectl create -f scc.yaml
-					// oc adm policy add-scc-to-group scc-jws system:serviceaccounts:tomcat-in-the-cloud
-					/*
-						SecurityContext: &corev1.SecurityContext{
-							Capabilities: &corev1.Capabilities{
-								Add: []corev1.Capability{
-									// "CAP_SETGID", "CAP_SETUID",
-									"SETGID", "SETUID",
-								},
+			},
+		*/
+		Containers: []corev1.Container{
+			{
+				Name:  "war",
+				Image: webServer.Spec.WebImage.WebApp.Builder.Image,
+				// Default uses the default build.sh file in image
+				Command: command,
+				Args:    args,
+				// Actually the SA doesn't have that permission :( so that won't work with giving permissions.
+				// Doing the following allows it:
+				// oc adm policy add-scc-to-group privileged system:serviceaccounts:tomcat-in-the-cloud
+				/*
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: &[]bool{true}[0],
+					},
+				*/
+				// here the permissions have to be added in a SecurityContextConstraint
+				// for example https://github.com/jfclere/tomcat-kubernetes/blob/main/scc.yaml
+				// kubectl create -f scc.yaml
+				// oc adm policy add-scc-to-group scc-jws system:serviceaccounts:tomcat-in-the-cloud
+				/*
+					SecurityContext: &corev1.SecurityContext{
+						Capabilities: &corev1.Capabilities{
+							Add: []corev1.Capability{
+								// "CAP_SETGID", "CAP_SETUID",
+								"SETGID", "SETUID",
 							},
 						},
-					*/
-					SecurityContext: securityContext,
-					Env:             r.generateEnvBuild(webServer),
-					VolumeMounts:    r.generateVolumeMountPodBuilder(webServer),
-				},
+					},
+				*/
+				SecurityContext: securityContext,
+				Env:             r.generateEnvBuild(webServer),
+				VolumeMounts:    r.generateVolumeMountPodBuilder(webServer),
+				// The end of the log of a failed build is recorded in the status of the WebServer
+				TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 			},
 		},
 	}
-
-	err := controllerutil.SetControllerReference(webServer, pod, r.Scheme)
-	if err != nil {
-		log.Error(err, "SetControllerReference was not successful")
-	}
-
-	return pod
 }
 
 func (r *WebServerReconciler) generateAnnotationsDeployment(webServer *webserversv1alpha1.WebServer) map[string]string {
@@ -372,11 +552,9 @@ func (r *WebServerReconciler) generateStatefulSet(webServer *webserversv1alpha1.
 	statefulset := &kbappsv1.StatefulSet{
 		ObjectMeta: objectMeta,
 		Spec: kbappsv1.StatefulSetSpec{
-			ServiceName: webServer.Spec.ApplicationName,
-			UpdateStrategy: kbappsv1.StatefulSetUpdateStrategy{
-				Type: kbappsv1.RollingUpdateStatefulSetStrategyType,
-			},
-			Replicas: &replicas,
+			ServiceName:    webServer.Spec.ApplicationName,
+			UpdateStrategy: r.generateStatefulSetUpdateStrategy(webServer),
+			Replicas:       &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: r.generateSelectorLabelsForWeb(webServer),
 			},
@@ -385,6 +563,10 @@ func (r *WebServerReconciler) generateStatefulSet(webServer *webserversv1alpha1.
 		},
 	}
 
+	if webServer.Spec.UpdateStrategy != nil {
+		statefulset.Spec.MinReadySeconds = webServer.Spec.UpdateStrategy.MinReadySeconds
+	}
+
 	if webServer.Spec.Volume != nil && webServer.Spec.Volume.DeleteCreatedClaims {
 		statefulset.Spec.PersistentVolumeClaimRetentionPolicy = &kbappsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
 			WhenDeleted: "Delete",
@@ -413,9 +595,7 @@ func (r *WebServerReconciler) generateDeployment(webServer *webserversv1alpha1.W
 	deployment := &kbappsv1.Deployment{
 		ObjectMeta: objectMeta,
 		Spec: kbappsv1.DeploymentSpec{
-			Strategy: kbappsv1.DeploymentStrategy{
-				Type: kbappsv1.RecreateDeploymentStrategyType,
-			},
+			Strategy: r.generateDeploymentStrategy(webServer),
 			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: r.generateSelectorLabelsForWeb(webServer),
@@ -424,6 +604,11 @@ func (r *WebServerReconciler) generateDeployment(webServer *webserversv1alpha1.W
 		},
 	}
 
+	if updateStrategy := webServer.Spec.UpdateStrategy; updateStrategy != nil {
+		deployment.Spec.MinReadySeconds = updateStrategy.MinReadySeconds
+		deployment.Spec.ProgressDeadlineSeconds = updateStrategy.ProgressDeadlineSeconds
+	}
+
 	err := controllerutil.SetControllerReference(webServer, deployment, r.Scheme)
 	if err != nil {
 		log.Error(err, "SetControllerReference was not successful")
@@ -432,66 +617,59 @@ func (r *WebServerReconciler) generateDeployment(webServer *webserversv1alpha1.W
 	return deployment
 }
 
-func (r *WebServerReconciler) generateUpdatedDeployment(webServer *webserversv1alpha1.WebServer, deployment *kbappsv1.Deployment, applicationImage string) {
-
-	replicas := webServer.Spec.Replicas
-	objectMeta := r.generateObjectMeta(webServer, webServer.Spec.ApplicationName)
-	objectMeta.Labels = r.generateLabelsForWeb(webServer)
-
-	if webServer.Spec.WebImage == nil {
-		objectMeta.Annotations = r.generateAnnotationsDeployment(webServer)
+// generateCanaryDeployment returns the Deployment of the image being rolled out, its pods carry the labels of the
+// application pods except the deployment label so the Deployment of the application doesn't select them.
+func (r *WebServerReconciler) generateCanaryDeployment(webServer *webserversv1alpha1.WebServer, image string, replicas int32) *kbappsv1.Deployment {
+	name := canaryName(webServer)
+	deployment := r.generateDeployment(webServer, image)
+	deployment.Name = name
+	deployment.Labels["deployment"] = name
+	deployment.Labels["webserver-hash"] = r.getWebServerHash(webServer)
+	deployment.Spec.Replicas = &replicas
+	deployment.Spec.Selector.MatchLabels["deployment"] = name
+	deployment.Spec.Template.Labels["deployment"] = name
+	stepSeconds := webserversv1alpha1.DefaultRolloutStepSeconds
+	if webServer.Spec.Rollout.StepSeconds != nil {
+		stepSeconds = *webServer.Spec.Rollout.StepSeconds
+	}
+	if stepSeconds > deployment.Spec.MinReadySeconds {
+		// The canary pods are only available once they stayed ready for a whole step
+		deployment.Spec.MinReadySeconds = stepSeconds
 	}
+	return deployment
+}
 
-	log.Info("generateUpdatedDeployment")
-	podTemplateSpec := r.generatePodTemplate(webServer, applicationImage)
-	deployment.ObjectMeta = objectMeta
-	spec := kbappsv1.DeploymentSpec{
-		Strategy: kbappsv1.DeploymentStrategy{
+// generateDeploymentStrategy returns the strategy of the Deployment: Recreate unless a rolling update is requested.
+func (r *WebServerReconciler) generateDeploymentStrategy(webServer *webserversv1alpha1.WebServer) kbappsv1.DeploymentStrategy {
+	updateStrategy := webServer.Spec.UpdateStrategy
+	if updateStrategy == nil || updateStrategy.Type != webserversv1alpha1.UpdateStrategyRollingUpdate {
+		return kbappsv1.DeploymentStrategy{
 			Type: kbappsv1.RecreateDeploymentStrategyType,
-		},
-		Replicas: &replicas,
-		Selector: &metav1.LabelSelector{
-			MatchLabels: r.generateSelectorLabelsForWeb(webServer),
-		},
-		Template: podTemplateSpec,
+		}
 	}
-	deployment.Spec = spec
-	err := controllerutil.SetControllerReference(webServer, deployment, r.Scheme)
-	if err != nil {
-		log.Error(err, "SetControllerReference was not successful")
+	strategy := kbappsv1.DeploymentStrategy{
+		Type: kbappsv1.RollingUpdateDeploymentStrategyType,
 	}
+	if updateStrategy.MaxSurge != nil || updateStrategy.MaxUnavailable != nil {
+		strategy.RollingUpdate = &kbappsv1.RollingUpdateDeployment{
+			MaxSurge:       updateStrategy.MaxSurge,
+			MaxUnavailable: updateStrategy.MaxUnavailable,
+		}
+	}
+	return strategy
 }
 
-func (r *WebServerReconciler) generateUpdatedStatefulSet(webServer *webserversv1alpha1.WebServer, statefulset *kbappsv1.StatefulSet, applicationImage string) {
-
-	replicas := webServer.Spec.Replicas
-	objectMeta := r.generateObjectMeta(webServer, webServer.Spec.ApplicationName)
-	objectMeta.Labels = r.generateLabelsForWeb(webServer)
-
-	if webServer.Spec.WebImage == nil {
-		objectMeta.Annotations = r.generateAnnotationsDeployment(webServer)
+// generateStatefulSetUpdateStrategy returns the rolling update of the StatefulSet, partitioned if requested.
+func (r *WebServerReconciler) generateStatefulSetUpdateStrategy(webServer *webserversv1alpha1.WebServer) kbappsv1.StatefulSetUpdateStrategy {
+	strategy := kbappsv1.StatefulSetUpdateStrategy{
+		Type: kbappsv1.RollingUpdateStatefulSetStrategyType,
 	}
-
-	log.Info("generateUpdatedStatefulSet")
-	statefulset.ObjectMeta = objectMeta
-
-	spec := kbappsv1.StatefulSetSpec{
-		ServiceName: webServer.Spec.ApplicationName,
-		UpdateStrategy: kbappsv1.StatefulSetUpdateStrategy{
-			Type: kbappsv1.RollingUpdateStatefulSetStrategyType,
-		},
-		Replicas: &replicas,
-		Selector: &metav1.LabelSelector{
-			MatchLabels: r.generateSelectorLabelsForWeb(webServer),
-		},
-		Template:             r.generatePodTemplate(webServer, applicationImage),
-		VolumeClaimTemplates: r.generatePersistentVolumeClaims(webServer),
-	}
-	statefulset.Spec = spec
-	err := controllerutil.SetControllerReference(webServer, statefulset, r.Scheme)
-	if err != nil {
-		log.Error(err, "SetControllerReference was not successful")
+	if webServer.Spec.UpdateStrategy != nil && webServer.Spec.UpdateStrategy.Partition != nil {
+		strategy.RollingUpdate = &kbappsv1.RollingUpdateStatefulSetStrategy{
+			Partition: webServer.Spec.UpdateStrategy.Partition,
+		}
 	}
+	return strategy
 }
 
 func (r *WebServerReconciler) generateImageStream(webServer *webserversv1alpha1.WebServer) *imagev1.ImageStream {
@@ -514,8 +692,10 @@ func (r *WebServerReconciler) generateBuildConfig(webServer *webserversv1alpha1.
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      webServer.Spec.ApplicationName,
 			Namespace: webServer.Namespace,
+			// The Builds inherit the labels of the BuildConfig, they are mapped to the WebServer with them.
 			Labels: map[string]string{
 				"web-image-source-hash": r.getWebImageStreamHash(webServer),
+				webServerLabel:          webServer.Name,
 			},
 		},
 		Spec: buildv1.BuildConfigSpec{
@@ -737,6 +917,8 @@ func (r *WebServerReconciler) generateRoute(webServer *webserversv1alpha1.WebSer
 		}
 	}
 
+	r.setRolloutBackends(webServer, route)
+
 	err := controllerutil.SetControllerReference(webServer, route, r.Scheme)
 	if err != nil {
 		log.Error(err, "SetControllerReference was not successful")
@@ -779,6 +961,8 @@ func (r *WebServerReconciler) generateSecureRoute(webServer *webserversv1alpha1.
 		}
 	}
 
+	r.setRolloutBackends(webServer, route)
+
 	err := controllerutil.SetControllerReference(webServer, route, r.Scheme)
 	if err != nil {
 		log.Error(err, "SetControllerReference was not successful")
@@ -793,6 +977,12 @@ func (r *WebServerReconciler) generateLoadBalancer(webServer *webserversv1alpha1
 	objectMeta.Annotations = map[string]string{
 		"description": "LoadBalancer for application's http service.",
 	}
+	if webServer.Spec.Exposure != nil {
+		// e.g. the annotations configuring the load balancer of the cloud provider
+		for key, value := range webServer.Spec.Exposure.Annotations {
+			objectMeta.Annotations[key] = value
+		}
+	}
 	service := &corev1.Service{
 		ObjectMeta: objectMeta,
 		Spec: corev1.ServiceSpec{
@@ -809,6 +999,15 @@ func (r *WebServerReconciler) generateLoadBalancer(webServer *webserversv1alpha1
 			Type: "LoadBalancer",
 		},
 	}
+	if deployment, ok := r.rolloutTrafficDeployment(webServer); ok {
+		if deployment == "" {
+			// The pods of both Deployments share the traffic in proportion of their replicas
+			delete(service.Spec.Selector, "deployment")
+			service.Spec.Selector["application"] = webServer.Spec.ApplicationName
+		} else {
+			service.Spec.Selector["deployment"] = deployment
+		}
+	}
 
 	err := controllerutil.SetControllerReference(webServer, service, r.Scheme)
 	if err != nil {
@@ -874,6 +1073,31 @@ func (r *WebServerReconciler) generatePodTemplate(webServer *webserversv1alpha1.
 			ImagePullSecrets: r.generateimagePullSecrets(webServer),
 		},
 	}
+	if replicatesSessions(webServer) && r.usesKubernetesMembership(webServer) {
+		// The membership provider lists the pods of the namespace
+		template.Spec.ServiceAccountName = membershipServiceAccountName(webServer)
+	}
+	if usesAJP(webServer) {
+		template.Spec.Containers[0].Ports = append(template.Spec.Containers[0].Ports, corev1.ContainerPort{
+			Name:          "ajp",
+			ContainerPort: serverxml.AJPPort,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	if mergesTomcatConfig(webServer) {
+		// Installs the tool merging the configuration of the operator into the files of the image, the image of the
+		// operator changes with each upgrade of the operator and the pods are then replaced (see the README)
+		template.Spec.InitContainers = append(template.Spec.InitContainers, corev1.Container{
+			Name:            "tomcat-config",
+			Image:           operatorImage(),
+			Command:         []string{"/tomcat-config", "install", tomcatConfigToolDir},
+			SecurityContext: generateSecurityContext(webServer.Spec.SecurityContext),
+			VolumeMounts: []corev1.VolumeMount{{
+				Name:      "tomcat-config",
+				MountPath: tomcatConfigToolDir,
+			}},
+		})
+	}
 	if webServer.Spec.IsNotJWS {
 		template.Spec.Containers[0].Command = append(template.Spec.Containers[0].Command, "/bin/sh")
 		template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args, "-c", "/opt/start/start.sh")
@@ -964,7 +1188,7 @@ const deployHashSuffix = "20b02fd04fded82dcd706406391af9ba"
 // Create the env for the pods we are starting.
 func (r *WebServerReconciler) generateEnvVars(webServer *webserversv1alpha1.WebServer) []corev1.EnvVar {
 	value := "webserver-" + webServer.Name
-	if r.getUseKUBEPing(webServer) && webServer.Spec.UseSessionClustering {
+	if r.usesKubernetesMembership(webServer) && replicatesSessions(webServer) {
 		value = webServer.Namespace
 	}
 	env := []corev1.EnvVar{
@@ -979,12 +1203,26 @@ func (r *WebServerReconciler) generateEnvVars(webServer *webserversv1alpha1.WebS
 			Value: "true",
 		})
 	}
-	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") || webServer.Spec.UseSessionClustering || webServer.Spec.PersistentLogsConfig.AccessLogs || webServer.Spec.PersistentLogsConfig.CatalinaLogs {
+	if usesEnvFiles(webServer) {
 		env = append(env, corev1.EnvVar{
 			Name:  "ENV_FILES",
 			Value: "/env/my-files/test.sh",
 		})
 	}
+	if usesAJP(webServer) {
+		env = append(env, corev1.EnvVar{
+			Name: ajpSecretEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: webServer.Spec.Connectors.AJP.SecretName},
+					Key:                  webserversv1alpha1.AJPSecretKey,
+				},
+			},
+		})
+	}
+	if usesSessionStore(webServer) {
+		env = append(env, generateSessionStoreEnvVars(webServer)...)
+	}
 	if webServer.Spec.PersistentLogsConfig.CatalinaLogs {
 		// custum logging.properties path
 		env = append(env, corev1.EnvVar{
@@ -1062,13 +1300,20 @@ func (r *WebServerReconciler) generateVolumeMounts(webServer *webserversv1alpha1
 		})
 	}
 
-	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") || webServer.Spec.UseSessionClustering || webServer.Spec.PersistentLogsConfig.AccessLogs || webServer.Spec.PersistentLogsConfig.CatalinaLogs {
+	if usesEnvFiles(webServer) {
 		volm = append(volm, corev1.VolumeMount{
 			Name:      "webserver-" + webServer.Name,
 			MountPath: "/env/my-files",
 		})
 	}
 
+	if mergesTomcatConfig(webServer) {
+		volm = append(volm, corev1.VolumeMount{
+			Name:      "tomcat-config",
+			MountPath: tomcatConfigToolDir,
+		})
+	}
+
 	if webServer.Spec.IsNotJWS {
 		volm = append(volm, corev1.VolumeMount{
 			Name:      "start-sh-webserver-" + webServer.Name,
@@ -1131,7 +1376,7 @@ func (r *WebServerReconciler) generateVolumeMounts(webServer *webserversv1alpha1
 		}
 	}
 
-	if webServer.Spec.TLSConfig.TLSSecret != "" {
+	if r.tlsSecretName(webServer) != "" {
 		volm = append(volm, corev1.VolumeMount{
 			Name:      "webserver-tls" + webServer.Name,
 			MountPath: "/tls",
@@ -1167,7 +1412,7 @@ func (r *WebServerReconciler) generateVolumes(webServer *webserversv1alpha1.WebS
 		})
 	}
 
-	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") || webServer.Spec.UseSessionClustering || webServer.Spec.PersistentLogsConfig.AccessLogs || webServer.Spec.PersistentLogsConfig.CatalinaLogs {
+	if usesEnvFiles(webServer) {
 		vol = append(vol, corev1.Volume{
 			Name: "webserver-" + webServer.Name,
 			VolumeSource: corev1.VolumeSource{
@@ -1181,6 +1426,15 @@ func (r *WebServerReconciler) generateVolumes(webServer *webserversv1alpha1.WebS
 
 	}
 
+	if mergesTomcatConfig(webServer) {
+		vol = append(vol, corev1.Volume{
+			Name: "tomcat-config",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+	}
+
 	if webServer.Spec.IsNotJWS {
 		executeMode := int32(0777)
 		vol = append(vol, corev1.Volume{
@@ -1271,7 +1525,33 @@ func (r *WebServerReconciler) generateVolumes(webServer *webserversv1alpha1.WebS
 		}
 	}
 
-	if webServer.Spec.TLSConfig.TLSSecret != "" {
+	if r.useCertManager(webServer) {
+		// The Secret of cert-manager uses the keys of a kubernetes.io/tls Secret, the ca.crt depends on the issuer
+		secretName := r.tlsSecretName(webServer)
+		optional := true
+		vol = append(vol, corev1.Volume{
+			Name: "webserver-tls" + webServer.Name,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+							Items: []corev1.KeyToPath{
+								{Key: corev1.TLSCertKey, Path: "server.crt"},
+								{Key: corev1.TLSPrivateKeyKey, Path: "server.key"},
+							},
+						},
+					}, {
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+							Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+							Optional:             &optional,
+						},
+					}},
+				},
+			},
+		})
+	} else if webServer.Spec.TLSConfig.TLSSecret != "" {
 		vol = append(vol, corev1.Volume{
 			Name: "webserver-tls" + webServer.Name,
 			VolumeSource: corev1.VolumeSource{
@@ -1291,7 +1571,7 @@ func (r *WebServerReconciler) generateVolumeMountPodBuilder(webServer *webserver
 		MountPath: "/auth",
 		ReadOnly:  true,
 	}}
-	if webServer.Spec.WebImage != nil && webServer.Spec.WebImage.WebApp != nil && webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" {
+	if webServer.Spec.WebImage != nil && webServer.Spec.WebImage.WebApp != nil && usesBuildFiles(webServer) {
 		volm = append(volm, corev1.VolumeMount{
 			Name:      "webserver-bd-" + webServer.Name,
 			MountPath: "/build/my-files",
@@ -1309,7 +1589,7 @@ func (r *WebServerReconciler) generateVolumePodBuilder(webServer *webserversv1al
 			Secret: &corev1.SecretVolumeSource{SecretName: webServer.Spec.WebImage.WebApp.WebAppWarImagePushSecret},
 		},
 	}}
-	if webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript != "" {
+	if usesBuildFiles(webServer) {
 		vol = append(vol, corev1.Volume{
 			Name: "webserver-bd-" + webServer.Name,
 			VolumeSource: corev1.VolumeSource{
@@ -1390,78 +1670,48 @@ func (r *WebServerReconciler) generateLivenessProbeScript(webServer *webserversv
 	return cmd
 }
 
-// create the shell script to modify server.xml
-func (r *WebServerReconciler) generateCommandForServerXml(webServer *webserversv1alpha1.WebServer) map[string]string {
+// create the shell script replacing the configuration files of the image, config holds the rendered files
+func (r *WebServerReconciler) generateCommandForServerXml(webServer *webserversv1alpha1.WebServer, config map[string]string) map[string]string {
 	cmd := make(map[string]string)
-	connector := ""
-	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") {
-		// "/tls" is the dir in which the secret's contents are mounted to the pod
-		connector +=
-			"https=\"<!-- No HTTPS configuration discovered -->\"\n" +
-				"if [ -f \"/tls/server.crt\" -a -f \"/tls/server.key\" -a -f \"/tls/ca.crt\" ] ; then\n" +
-
-				"https=\"" +
-				"<Connector port=\\\"8443\\\" protocol=\\\"HTTP/1.1\\\" " +
-				"maxThreads=\\\"200\\\" SSLEnabled=\\\"true\\\"> "
-		if webServer.Spec.TLSConfig.CertificateVerification == "required" || webServer.Spec.TLSConfig.CertificateVerification == "optional" {
-			connector += "<SSLHostConfig caCertificateFile=\\\"/tls/ca.crt\\\" certificateVerification=\\\"" + webServer.Spec.TLSConfig.CertificateVerification + "\\\"> "
-		} else {
-			connector += "<SSLHostConfig caCertificateFile=\\\"/tls/ca.crt\\\"> "
+	cmd["test.sh"] = ""
+	if len(config) > 0 {
+		// The other files are installed next to server.xml, in the conf directory
+		cmd["test.sh"] = "FILE=`find /opt -name server.xml`\n" +
+			"if [ -z \"${FILE}\" ]; then\n" +
+			"  FILE=`find /deployments -name server.xml`\n" +
+			"fi\n" +
+			"if [ -z \"${FILE}\" ]; then\n" +
+			"  echo \"server.xml not found, the configuration of the operator WILL NOT be applied.\"\n" +
+			"  exit 1\n" +
+			"fi\n" +
+			"for f in ${FILE}; do\n" +
+			"  CONF=`dirname ${f}`\n"
+		for _, file := range serverxml.Files {
+			if data, ok := config[file]; ok {
+				cmd[file] = data
+				cmd["test.sh"] = cmd["test.sh"] + "  cat /env/my-files/" + file + " > ${CONF}/" + file + "\n"
+			}
+			if data, ok := config[file+fragmentSuffix]; ok {
+				// The elements are merged into the file of the image by the tool of the tomcat-config init container
+				cmd[file+fragmentSuffix] = data
+				cmd["test.sh"] = cmd["test.sh"] + "  " + tomcatConfigToolDir + "/tomcat-config merge ${CONF}/" + file + " /env/my-files/" + file + fragmentSuffix + " || exit 1\n"
+			}
 		}
-		connector += "<Certificate certificateFile=\\\"/tls/server.crt\\\" " +
-			"certificateKeyFile=\\\"/tls/server.key\\\"/> " +
-			"</SSLHostConfig> " +
-			"</Connector>\"\n" +
-			"elif [ -d \"/tls\" -a -f \"/tls/server.crt\" -a -f \"/tls/server.key\" ] ; then\n" +
-			"https=\"" +
-			"<Connector port=\\\"8443\\\" protocol=\\\"HTTP/1.1\\\" " +
-			"maxThreads=\\\"200\\\" SSLEnabled=\\\"true\\\"> "
-		if webServer.Spec.TLSConfig.CertificateVerification == "required" || webServer.Spec.TLSConfig.CertificateVerification == "optional" {
-			connector += "<SSLHostConfig " + "certificateVerification=\\\"" + webServer.Spec.TLSConfig.CertificateVerification + "\\\"> "
-		} else {
-			connector += "<SSLHostConfig> "
+		if data, ok := config[redissonYaml]; ok {
+			// Redisson replaces the variables itself, the password is only configured when the Secret has one
+			cmd[redissonYaml] = data
+			cmd["test.sh"] = cmd["test.sh"] + "  cat /env/my-files/" + redissonYaml + " > ${CONF}/" + redissonYaml + "\n" +
+				"  if [ -n \"${" + sessionStorePasswordEnv + "}\" ]; then\n" +
+				"    echo '  password: \"${" + sessionStorePasswordEnv + "}\"' >> ${CONF}/" + redissonYaml + "\n" +
+				"  fi\n"
 		}
-		connector += "<Certificate certificateFile=\\\"/tls/server.crt\\\" " +
-			"certificateKeyFile=\\\"/tls/server.key\\\"/> " +
-			"</SSLHostConfig> " +
-			"</Connector>\"\n" +
-			"elif [ ! -f \"/tls/server.crt\" -o ! -f \"/tls/server.key\" ] ; then \n" +
-			"log_warning \"Partial HTTPS configuration, the https connector WILL NOT be configured.\" \n" +
-			"fi \n" +
-			"sed \"/<Service name=/a ${https}\" ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n"
-	}
-
-	cmd["test.sh"] = "FILE=`find /opt -name server.xml`\n" +
-		"if [ -z \"${FILE}\" ]; then\n" +
-		"  FILE=`find /deployments -name server.xml`\n" +
-		"fi\n"
-	if webServer.Spec.UseSessionClustering {
-		cmd["test.sh"] = cmd["test.sh"] +
-			"grep -q MembershipProvider ${FILE}\n" +
-			"if [ $? -ne 0 ]; then\n"
-		if r.getUseKUBEPing(webServer) {
-			cmd["test.sh"] = cmd["test.sh"] + "  sed '/cluster.html/a        <Cluster className=\"org.apache.catalina.ha.tcp.SimpleTcpCluster\" channelSendOptions=\"6\">\\n <Channel className=\"org.apache.catalina.tribes.group.GroupChannel\">\\n <Membership className=\"org.apache.catalina.tribes.membership.cloud.CloudMembershipService\" membershipProviderClassName=\"org.apache.catalina.tribes.membership.cloud.KubernetesMembershipProvider\"/>\\n </Channel>\\n </Cluster>\\n' ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n" +
-				"fi\n"
-			cmd["test.sh"] = cmd["test.sh"] + connector
-		} else {
-			cmd["test.sh"] = cmd["test.sh"] + "  sed '/cluster.html/a        <Cluster className=\"org.apache.catalina.ha.tcp.SimpleTcpCluster\" channelSendOptions=\"6\">\\n <Channel className=\"org.apache.catalina.tribes.group.GroupChannel\">\\n <Membership className=\"org.apache.catalina.tribes.membership.cloud.CloudMembershipService\" membershipProviderClassName=\"org.apache.catalina.tribes.membership.cloud.DNSMembershipProvider\"/>\\n </Channel>\\n </Cluster>\\n' ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n" +
-				"fi\n"
-			cmd["test.sh"] = cmd["test.sh"] + connector
+		if usesAJP(webServer) || usesSessionStore(webServer) {
+			// The secret of the AJP connector and the connection details of the session store are replaced by the
+			// environment variables
+			cmd["test.sh"] = cmd["test.sh"] + "  grep -q '^org.apache.tomcat.util.digester.PROPERTY_SOURCE=' ${CONF}/catalina.properties 2>/dev/null || " +
+				"echo 'org.apache.tomcat.util.digester.PROPERTY_SOURCE=org.apache.tomcat.util.digester.EnvironmentPropertySource' >> ${CONF}/catalina.properties\n"
 		}
-	} else {
-		cmd["test.sh"] = cmd["test.sh"] + connector
-	}
-	if webServer.Spec.PersistentLogsConfig.AccessLogs {
-		cmd["test.sh"] = cmd["test.sh"] + "grep -q directory='\"/proc/self/fd\"' ${FILE}\n" +
-			"if [ $? -eq 0 ]; then\n" +
-			"sed -i 's|directory=\"/proc/self/fd\"|directory=\"/opt/tomcat_logs\"|g' ${FILE}\n" +
-			"sed -i \"s|prefix=\\\"1\\\"|prefix=\\\"access-$HOSTNAME\\\"|g\" ${FILE}\n" +
-			"sed -i 's|suffix=\"\"|suffix=\".log\"|g' ${FILE}\n" +
-			"else\n" +
-			"sed 's|directory=\"logs\"|directory=\"/opt/tomcat_logs\"|g' ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n" +
-			"sed \"s|prefix=\\\"localhost_access_log\\\"|prefix=\\\"access-$HOSTNAME\\\"|g\" ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n" +
-			"sed 's|suffix=\".txt\"|suffix=\".log\"|g' ${FILE}> /tmp/tmp.xml; cat /tmp/tmp.xml > ${FILE}; rm /tmp/tmp.xml\n" +
-			"fi\n"
+		cmd["test.sh"] = cmd["test.sh"] + "done\n"
 	}
 	cmd["test.sh"] = cmd["test.sh"] + "FILE=`find /opt -name catalina.sh`\n" +
 		"if [ -z \"${FILE}\" ]; then\n" +
@@ -1474,9 +1724,33 @@ func (r *WebServerReconciler) generateCommandForServerXml(webServer *webserversv
 }
 
 // create the shell script to pod builder
-func (r *WebServerReconciler) generateCommandForBuider(script string) map[string]string {
+func (r *WebServerReconciler) generateCommandForBuider(webServer *webserversv1alpha1.WebServer) map[string]string {
 	cmd := make(map[string]string)
-	cmd["build.sh"] = script
+	cmd["build.sh"] = webServer.Spec.WebImage.WebApp.Builder.ApplicationBuildScript
+	if buildStrategy(webServer) != webserversv1alpha1.BuildStrategyBuildah {
+		return cmd
+	}
+	if cmd["build.sh"] == "" {
+		cmd["build.sh"] = "#!/bin/sh\n" +
+
+			"# Default build script of the Buildah strategy: it builds the war with maven\n" +
+			"# and moves it to /mnt where buildah takes it.\n" +
+			"set -e\n" +
+			"export HOME=/tmp\n" +
+			"cd /tmp\n" +
+			"git clone ${webAppSourceRepositoryRef:+--branch $webAppSourceRepositoryRef} $webAppSourceRepositoryURL sources\n" +
+			"cd sources\n" +
+			"COMMIT=`git rev-parse HEAD`\n" +
+			"cd ./${webAppSourceRepositoryContextDir}\n" +
+			"mvn -B package -DskipTests\n" +
+			"cp target/*.war /mnt/${webAppWarFileName:-ROOT.war}\n" +
+
+			"# The commit is recorded in the status of the WebServer\n" +
+			"echo \"commit=${COMMIT}\" > /dev/termination-log\n"
+	}
+	cmd["Dockerfile"] = "ARG webAppSourceImage\n" +
+		"FROM ${webAppSourceImage}\n" +
+		"COPY *.war /deployments/\n"
 	return cmd
 }
 
@@ -1498,24 +1772,8 @@ func (r *WebServerReconciler) generateLoggingProperties() map[string]string {
 
 // generateSecurityContext supplements a default SecurityContext and returns it.
 func generateSecurityContext(s *corev1.SecurityContext) *corev1.SecurityContext {
-	allowPrivilegeEscalation := new(bool)
-	*allowPrivilegeEscalation = false
-
-	runAsNonRoot := new(bool)
-	*runAsNonRoot = true
-
-	sTemplate := &corev1.SecurityContext{
-		AllowPrivilegeEscalation: allowPrivilegeEscalation,
-		Capabilities: &corev1.Capabilities{
-			Drop: []corev1.Capability{
-				"ALL",
-			},
-		},
-		RunAsNonRoot: runAsNonRoot,
-	}
 	if s != nil {
 		return s
 	}
-
-	return sTemplate
+	return webserversv1alpha1.DefaultSecurityContext()
 }
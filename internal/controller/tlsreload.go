@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+
+	kbappsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// tlsSecretHashAnnotation records the hash of the content of the TLS Secret on the pod template (Restart) or on
+	// the pods whose certificates have been reloaded (Jolokia).
+	tlsSecretHashAnnotation = "web.servers.org/tls-secret-hash"
+	// tlsRotationAnnotation marks a Deployment temporarily switched to a rolling update to rotate the certificates.
+	tlsRotationAnnotation = "web.servers.org/tls-rotation"
+	// secretIndex indexes the WebServers by the Secrets holding their certificates
+	secretIndex = ".spec.tlsConfig.secrets"
+	// jolokiaPort is the port of the Jolokia agent of the JWS images
+	jolokiaPort = 8778
+	// tlsReloadDelay leaves the kubelet the time to update the Secret mounted in the pods (1 minute by default)
+	tlsReloadDelay = 2 * time.Minute
+	// tlsReloadRetry is the delay before reloading again the certificates of the pods which failed
+	tlsReloadRetry = time.Minute
+)
+
+// certificateSecrets returns the Secrets holding the certificates of the WebServer: the Secret mounted in /tls
+// and the Secrets copied into the Route.
+func (r *WebServerReconciler) certificateSecrets(webServer *webserversv1alpha1.WebServer) []string {
+	var secrets []string
+	if name := r.tlsSecretName(webServer); name != "" {
+		secrets = append(secrets, name)
+	}
+	if route := webServer.Spec.Route; route != nil {
+		if route.CertificateSecret != "" {
+			secrets = append(secrets, route.CertificateSecret)
+		}
+		if route.DestinationCASecret != "" {
+			secrets = append(secrets, route.DestinationCASecret)
+		}
+	}
+	return secrets
+}
+
+// webServersForSecret maps a Secret to the WebServers using its certificates.
+func (r *WebServerReconciler) webServersForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	webServers := &webserversv1alpha1.WebServerList{}
+	err := r.List(ctx, webServers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{secretIndex: obj.GetName()})
+	if err != nil {
+		log.Error(err, "Failed to list the WebServers using Secret: "+obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, len(webServers.Items))
+	for i, webServer := range webServers.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: webServer.Name, Namespace: webServer.Namespace}}
+	}
+	return requests
+}
+
+// getTLSSecret returns the Secret mounted in /tls, nil when there is none.
+func (r *WebServerReconciler) getTLSSecret(ctx context.Context, webServer *webserversv1alpha1.WebServer) (*corev1.Secret, error) {
+	name := r.tlsSecretName(webServer)
+	if name == "" {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		log.Error(err, "Failed to get the TLS Secret: "+name)
+		return nil, err
+	}
+	return secret, nil
+}
+
+// tlsSecretHash returns the hash of the content of the TLS Secret.
+func tlsSecretHash(secret *corev1.Secret) string {
+	if secret == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key + ":"))
+		h.Write(secret.Data[key])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+// setTLSSecretHash records the hash of the TLS Secret on the pod template: a rotation of the certificates changes
+// the template and restarts the pods. With the Jolokia reload the certificates are reloaded in the running pods.
+func (r *WebServerReconciler) setTLSSecretHash(ctx context.Context, webServer *webserversv1alpha1.WebServer, template *corev1.PodTemplateSpec) error {
+	if webServer.Spec.TLSConfig.CertificateReload == webserversv1alpha1.CertificateReloadJolokia {
+		return nil
+	}
+	secret, err := r.getTLSSecret(ctx, webServer)
+	hash := tlsSecretHash(secret)
+	if err != nil || hash == "" {
+		return err
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[tlsSecretHashAnnotation] = hash
+	return nil
+}
+
+// tlsRotation returns true when the only change of the pod template is the rotation of the certificates.
+func tlsRotation(found, template *corev1.PodTemplateSpec) bool {
+	return found.Labels["webserver-hash"] == template.Labels["webserver-hash"] &&
+		found.Annotations[tlsSecretHashAnnotation] != template.Annotations[tlsSecretHashAnnotation]
+}
+
+// setTLSRotationStrategy replaces the pods of a Recreate Deployment one by one while the certificates are rotated,
+// the Recreate strategy is restored once all the pods run the new template.
+func setTLSRotationStrategy(deployment, found *kbappsv1.Deployment) {
+	if deployment.Spec.Strategy.Type != kbappsv1.RecreateDeploymentStrategyType {
+		return
+	}
+	rotating := found.Annotations[tlsRotationAnnotation] == "true" && !deploymentReady(found)
+	if !rotating && !tlsRotation(&found.Spec.Template, &deployment.Spec.Template) {
+		return
+	}
+	maxUnavailable := intstr.FromInt32(0)
+	maxSurge := intstr.FromInt32(1)
+	deployment.Spec.Strategy = kbappsv1.DeploymentStrategy{
+		Type: kbappsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &kbappsv1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+	if deployment.Annotations == nil {
+		deployment.Annotations = map[string]string{}
+	}
+	deployment.Annotations[tlsRotationAnnotation] = "true"
+}
+
+// reloadTLSCertificates reloads the rotated certificates in the running pods through Jolokia, the reloaded pods are
+// annotated with the hash of the TLS Secret. The reload waits for the kubelet to update the mounted Secret.
+func (r *WebServerReconciler) reloadTLSCertificates(ctx context.Context, webServer *webserversv1alpha1.WebServer) (ctrl.Result, error) {
+	if webServer.Spec.TLSConfig.CertificateReload != webserversv1alpha1.CertificateReloadJolokia {
+		return reconcile.Result{}, nil
+	}
+	secret, err := r.getTLSSecret(ctx, webServer)
+	hash := tlsSecretHash(secret)
+	if err != nil || hash == "" {
+		return reconcile.Result{}, err
+	}
+	podList, err := r.getPodList(ctx, webServer)
+	if err != nil {
+		log.Error(err, "Failed to get pod list.", "WebServer.Namespace", webServer.Namespace, "WebServer.Name", webServer.Name)
+		return reconcile.Result{}, err
+	}
+	var outdated []*corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if isPodReady(pod) && pod.Status.PodIP != "" && pod.Annotations[tlsSecretHashAnnotation] != hash {
+			outdated = append(outdated, pod)
+		}
+	}
+	if len(outdated) == 0 {
+		return reconcile.Result{}, nil
+	}
+	if wait := tlsReloadDelay - time.Since(secretUpdateTime(secret)); wait > 0 {
+		log.Info("TLS Secret " + secret.Name + " changed, reloading the certificates in " + wait.Round(time.Second).String())
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	agent, err := r.getJolokiaAgent(ctx, webServer)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.recordWarning(webServer, eventReasonTLSReloadFailed, "Failed to reload the certificates: "+err.Error())
+			return ctrl.Result{RequeueAfter: tlsReloadRetry}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	reloaded := 0
+	result := reconcile.Result{}
+	for _, pod := range outdated {
+		if err := agent.reloadSSLHostConfigs(ctx, pod); err != nil {
+			log.Info("Failed to reload the certificates of pod " + pod.Name + ": " + err.Error())
+			r.recordWarning(webServer, eventReasonTLSReloadFailed, "Failed to reload the certificates of pod "+pod.Name+": "+err.Error())
+			result = ctrl.Result{RequeueAfter: tlsReloadRetry}
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[tlsSecretHashAnnotation] = hash
+		if err := r.Patch(ctx, pod, patch); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to annotate pod: "+pod.Name)
+			return reconcile.Result{}, err
+		}
+		reloaded++
+	}
+	if reloaded > 0 {
+		r.recordEvent(webServer, eventReasonTLSReloaded, "Reloaded the certificates of Secret "+secret.Name+" in "+strconv.Itoa(reloaded)+" pods")
+	}
+	return result, nil
+}
+
+// jolokiaAgent is the connection to the Jolokia agents of the pods of a WebServer.
+type jolokiaAgent struct {
+	scheme   string
+	client   *http.Client
+	username string
+	password string
+}
+
+// getJolokiaAgent returns the connection to the Jolokia agents described by tlsConfig.jolokia: HTTPS by default,
+// with the credentials and the CA of its Secrets. The credentials are never sent to an agent which isn't verified.
+func (r *WebServerReconciler) getJolokiaAgent(ctx context.Context, webServer *webserversv1alpha1.WebServer) (*jolokiaAgent, error) {
+	spec := webServer.Spec.TLSConfig.Jolokia
+	if spec == nil {
+		spec = &webserversv1alpha1.JolokiaSpec{}
+	}
+	agent := &jolokiaAgent{scheme: "https", client: http.DefaultClient}
+	if spec.Protocol == webserversv1alpha1.JolokiaProtocolHTTP {
+		agent.scheme = "http"
+	}
+	if spec.CredentialsSecret != "" {
+		if agent.scheme == "http" || spec.CASecret == "" {
+			// The webhook rejects it, the WebServers validated before still have to be refused
+			return nil, fmt.Errorf("the credentials of Secret %s are only sent over HTTPS to an agent verified with the CA of caSecret", spec.CredentialsSecret)
+		}
+		secret, err := r.getJolokiaSecret(ctx, webServer, spec.CredentialsSecret)
+		if err != nil {
+			return nil, err
+		}
+		agent.username = string(secret.Data[webserversv1alpha1.JolokiaUsernameKey])
+		agent.password = string(secret.Data[webserversv1alpha1.JolokiaPasswordKey])
+	}
+	if agent.scheme == "http" {
+		return agent, nil
+	}
+
+	// The agent of the JWS images generates its own certificate, it is verified only against the given CA
+	tlsConfig := &tls.Config{InsecureSkipVerify: true} // #nosec G402 -- the chain is verified below when a CA is given
+	if spec.CASecret != "" {
+		secret, err := r.getJolokiaSecret(ctx, webServer, spec.CASecret)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(secret.Data[webserversv1alpha1.JolokiaCAKey]) {
+			return nil, fmt.Errorf("no certificate in %s of Secret %s", webserversv1alpha1.JolokiaCAKey, spec.CASecret)
+		}
+		tlsConfig.VerifyPeerCertificate = verifyChain(roots)
+	}
+	agent.client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}
+	return agent, nil
+}
+
+// getJolokiaSecret returns a Secret of tlsConfig.jolokia.
+func (r *WebServerReconciler) getJolokiaSecret(ctx context.Context, webServer *webserversv1alpha1.WebServer, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get the Jolokia Secret: "+name)
+	}
+	return secret, err
+}
+
+// verifyChain verifies the chain of the certificate of the peer against roots, whatever its host names: the pods
+// are reached by their IP, which the certificates don't name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no certificate")
+		}
+		certificates := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			certificate, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certificates[i] = certificate
+		}
+		intermediates := x509.NewCertPool()
+		for _, certificate := range certificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		_, err := certificates[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+// reloadSSLHostConfigs asks tomcat to reload the certificates of its HTTPS connector through Jolokia.
+func (agent *jolokiaAgent) reloadSSLHostConfigs(ctx context.Context, pod *corev1.Pod) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":      "exec",
+		"mbean":     "Catalina:type=ProtocolHandler,port=8443",
+		"operation": "reloadSslHostConfigs",
+		"arguments": []interface{}{},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	url := agent.scheme + "://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(jolokiaPort)) + "/jolokia/"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if agent.username != "" {
+		request.SetBasicAuth(agent.username, agent.password)
+	}
+	response, err := agent.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("jolokia answered %s", response.Status)
+	}
+	// Jolokia reports the errors of the operation in the status of the JSON response
+	reply := struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&reply); err != nil {
+		return err
+	}
+	if reply.Status != http.StatusOK {
+		return fmt.Errorf("jolokia status %d: %s", reply.Status, reply.Error)
+	}
+	return nil
+}
+
+// secretUpdateTime returns the time of the last update of the Secret recorded by the API server.
+func secretUpdateTime(secret *corev1.Secret) time.Time {
+	updated := secret.CreationTimestamp.Time
+	for _, entry := range secret.ManagedFields {
+		if entry.Time != nil && entry.Time.After(updated) {
+			updated = entry.Time.Time
+		}
+	}
+	return updated
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+// newTestCACertificate returns a self-signed CA certificate in PEM.
+func newTestCACertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jolokia-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestGetJolokiaAgentCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		jolokia  webserversorgv1alpha1.JolokiaSpec
+		username string
+		err      bool
+	}{
+		{"without credentials", webserversorgv1alpha1.JolokiaSpec{}, "", false},
+		{"with a verified agent", webserversorgv1alpha1.JolokiaSpec{CredentialsSecret: "jolokia", CASecret: "jolokia"}, "admin", false},
+		{"without CA", webserversorgv1alpha1.JolokiaSpec{CredentialsSecret: "jolokia"}, "", true},
+		{"over HTTP", webserversorgv1alpha1.JolokiaSpec{Protocol: webserversorgv1alpha1.JolokiaProtocolHTTP, CredentialsSecret: "jolokia", CASecret: "jolokia"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webServer := newConditionsTest()
+			webServer.Spec.TLSConfig.Jolokia = &tt.jolokia
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "jolokia", Namespace: "default"},
+				Data: map[string][]byte{
+					webserversorgv1alpha1.JolokiaUsernameKey: []byte("admin"),
+					webserversorgv1alpha1.JolokiaPasswordKey: []byte("secret"),
+					webserversorgv1alpha1.JolokiaCAKey:       newTestCACertificate(t),
+				},
+			}
+
+			agent, err := newTestReconciler(secret).getJolokiaAgent(context.Background(), webServer)
+			if tt.err {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(agent.username).To(Equal(tt.username))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// tomcatConfigHashAnnotation records the hash of the files of tomcatConfig on the pod template, their changes
+	// replace the pods.
+	tomcatConfigHashAnnotation = "web.servers.org/tomcat-config-hash"
+	// configMapIndex indexes the WebServers by the ConfigMaps holding their configuration files
+	configMapIndex = ".spec.tomcatConfig.configMaps"
+)
+
+// tomcatConfigFile returns the ConfigMap of a configuration file of tomcat, nil when the file isn't configured.
+func tomcatConfigFile(webServer *webserversv1alpha1.WebServer, file string) *webserversv1alpha1.TomcatConfigFile {
+	tomcatConfig := webServer.Spec.TomcatConfig
+	if tomcatConfig == nil {
+		return nil
+	}
+	switch file {
+	case serverxml.ServerXml:
+		return tomcatConfig.ServerXml
+	case serverxml.ContextXml:
+		return tomcatConfig.ContextXml
+	case serverxml.WebXml:
+		return tomcatConfig.WebXml
+	case serverxml.TomcatUsersXml:
+		return tomcatConfig.TomcatUsersXml
+	}
+	return nil
+}
+
+// tomcatConfigKey returns the key of the file in its ConfigMap.
+func tomcatConfigKey(spec *webserversv1alpha1.TomcatConfigFile, file string) string {
+	if spec.Key != "" {
+		return spec.Key
+	}
+	return file
+}
+
+// tomcatConfigPath returns the path of the file in the WebServer, used in the messages.
+func tomcatConfigPath(file string) string {
+	switch file {
+	case serverxml.ContextXml:
+		return "tomcatConfig.contextXml"
+	case serverxml.WebXml:
+		return "tomcatConfig.webXml"
+	case serverxml.TomcatUsersXml:
+		return "tomcatConfig.tomcatUsersXml"
+	}
+	return "tomcatConfig.serverXml"
+}
+
+// tomcatConfigData returns the content of a configuration file.
+func tomcatConfigData(spec *webserversv1alpha1.TomcatConfigFile, file string, configMaps map[string]*corev1.ConfigMap) ([]byte, error) {
+	key := tomcatConfigKey(spec, file)
+	configMap := configMaps[spec.ConfigMap]
+	if configMap == nil {
+		return nil, fmt.Errorf("%s: ConfigMap %s not found", tomcatConfigPath(file), spec.ConfigMap)
+	}
+	if data, ok := configMap.Data[key]; ok {
+		return []byte(data), nil
+	}
+	if data, ok := configMap.BinaryData[key]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s: key %s not found in ConfigMap %s", tomcatConfigPath(file), key, spec.ConfigMap)
+}
+
+// tomcatConfigMaps returns the ConfigMaps holding the configuration files of the WebServer.
+func tomcatConfigMaps(webServer *webserversv1alpha1.WebServer) []string {
+	var names []string
+	for _, file := range serverxml.Files {
+		if spec := tomcatConfigFile(webServer, file); spec != nil && !containsString(names, spec.ConfigMap) {
+			names = append(names, spec.ConfigMap)
+		}
+	}
+	return names
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// webServersForConfigMap maps a ConfigMap to the WebServers using its configuration files.
+func (r *WebServerReconciler) webServersForConfigMap(ctx context.Context, obj client.Object) []reconcile.Request {
+	webServers := &webserversv1alpha1.WebServerList{}
+	err := r.List(ctx, webServers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{configMapIndex: obj.GetName()})
+	if err != nil {
+		log.Error(err, "Failed to list the WebServers using ConfigMap: "+obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, len(webServers.Items))
+	for i, webServer := range webServers.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: webServer.Name, Namespace: webServer.Namespace}}
+	}
+	return requests
+}
+
+// getTomcatConfigMaps reads the ConfigMaps holding the configuration files of the WebServer.
+func (r *WebServerReconciler) getTomcatConfigMaps(ctx context.Context, webServer *webserversv1alpha1.WebServer) (map[string]*corev1.ConfigMap, error) {
+	configMaps := make(map[string]*corev1.ConfigMap)
+	for _, name := range tomcatConfigMaps(webServer) {
+		configMap := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webServer.Namespace}, configMap)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.recordWarning(webServer, eventReasonConfigMapNotFound, "ConfigMap "+name+" of tomcatConfig not found")
+			}
+			log.Error(err, "Failed to get the ConfigMap of tomcatConfig: "+name)
+			return nil, err
+		}
+		configMaps[name] = configMap
+	}
+	return configMaps, nil
+}
+
+// setTomcatConfigHash records the hash of the configuration files of tomcatConfig on the pod template: tomcat
+// reads them when it starts, their changes replace the pods.
+func (r *WebServerReconciler) setTomcatConfigHash(ctx context.Context, webServer *webserversv1alpha1.WebServer, template *corev1.PodTemplateSpec) error {
+	if webServer.Spec.TomcatConfig == nil {
+		return nil
+	}
+	configMaps, err := r.getTomcatConfigMaps(ctx, webServer)
+	if err != nil {
+		return err
+	}
+	files := make([]string, 0, len(serverxml.Files))
+	for _, file := range serverxml.Files {
+		if tomcatConfigFile(webServer, file) != nil {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, file := range files {
+		// A missing key is reported when the files are rendered
+		data, _ := tomcatConfigData(tomcatConfigFile(webServer, file), file, configMaps)
+		h.Write([]byte(file + ":"))
+		h.Write(data)
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[tomcatConfigHashAnnotation] = fmt.Sprintf("%x", h.Sum(nil))[:16]
+	return nil
+}
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The Gateway API, cert-manager and Shipwright have no Go types in the dependencies of the operator, their
+// resources are handled as unstructured objects of these kinds.
+var (
+	gatewayGVK            = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"}
+	httpRouteGVK          = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+	tlsRouteGVK           = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Kind: "TLSRoute"}
+	certificateGVK        = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+	shipwrightBuildGVK    = schema.GroupVersionKind{Group: "shipwright.io", Version: "v1beta1", Kind: "Build"}
+	shipwrightBuildRunGVK = schema.GroupVersionKind{Group: "shipwright.io", Version: "v1beta1", Kind: "BuildRun"}
+)
+
+// newUnstructured returns an empty resource of the kind.
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	return object
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// webServerLabel is the label carrying the name of the WebServer on the objects not owned by it
+	webServerLabel = "WebServer"
+)
+
+// PodCacheSelector selects the pods cached by the manager: the application pods and the build pods carry the
+// WebServer label, the other pods of the namespaces (or of the cluster) are never read from the cache.
+func PodCacheSelector() labels.Selector {
+	requirement, err := labels.NewRequirement(webServerLabel, selection.Exists, nil)
+	if err != nil {
+		panic(err)
+	}
+	return labels.NewSelector().Add(*requirement)
+}
+
+// webServerForObject maps an object to the WebServer controlling it, either through the controller
+// owner reference (log PersistentVolumeClaim) or through the WebServer label (application pods created
+// by the ReplicaSets, build pods created by the build Job, Builds created by the BuildConfig).
+func (r *WebServerReconciler) webServerForObject(_ context.Context, obj client.Object) []reconcile.Request {
+	if owner := metav1.GetControllerOf(obj); owner != nil {
+		if owner.Kind == "WebServer" && owner.APIVersion == webserversv1alpha1.GroupVersion.String() {
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: owner.Name, Namespace: obj.GetNamespace()}}}
+		}
+	}
+	if name, ok := obj.GetLabels()[webServerLabel]; ok && name != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}}}
+	}
+	return nil
+}
+
+// hasWebServerLabel filters out the objects which don't belong to a WebServer before they are mapped.
+var hasWebServerLabel = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	if _, ok := obj.GetLabels()[webServerLabel]; ok {
+		return true
+	}
+	owner := metav1.GetControllerOf(obj)
+	return owner != nil && owner.Kind == "WebServer"
+})
+
+// podStatusChanged only lets through the pod updates the WebServer status depends on:
+// phase, IP address, readiness and labels.
+var podStatusChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPod, ok := e.ObjectOld.(*corev1.Pod)
+		if !ok {
+			return false
+		}
+		newPod, ok := e.ObjectNew.(*corev1.Pod)
+		if !ok {
+			return false
+		}
+		return oldPod.Status.Phase != newPod.Status.Phase ||
+			oldPod.Status.PodIP != newPod.Status.PodIP ||
+			isPodReady(oldPod) != isPodReady(newPod) ||
+			!reflect.DeepEqual(oldPod.Labels, newPod.Labels)
+	},
+}
+
+// buildPhaseChanged only lets through the Build updates changing the phase of the build.
+var buildPhaseChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldBuild, ok := e.ObjectOld.(*buildv1.Build)
+		if !ok {
+			return false
+		}
+		newBuild, ok := e.ObjectNew.(*buildv1.Build)
+		if !ok {
+			return false
+		}
+		return oldBuild.Status.Phase != newBuild.Status.Phase
+	},
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -5,21 +5,30 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
-	"time"
 
+	buildv1 "github.com/openshift/api/build/v1"
+	routev1 "github.com/openshift/api/route/v1"
 	buildclient "github.com/openshift/client-go/build/clientset/versioned"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/platform"
 
 	kbappsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -40,19 +49,75 @@ var (
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func (r *WebServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	r.isOpenShift = isOpenShift(mgr.GetConfig())
+	r.isOpenShift = platform.IsOpenShift(mgr.GetConfig())
 	r.hasServiceMonitor = hasServiceMonitor(mgr.GetConfig())
-	if r.isOpenShift {
-		return ctrl.NewControllerManagedBy(mgr).
-			For(&webserversv1alpha1.WebServer{}).
-			Owns(&kbappsv1.Deployment{}).
-			Complete(r)
-	} else {
-		return ctrl.NewControllerManagedBy(mgr).
-			For(&webserversv1alpha1.WebServer{}).
-			Complete(r)
+	r.hasGatewayAPI = hasGatewayAPI(mgr.GetConfig())
+	r.hasTLSRoute = r.hasGatewayAPI && hasTLSRoute(mgr.GetConfig())
+	r.hasCertManager = hasCertManager(mgr.GetConfig())
+	r.hasShipwright = hasShipwright(mgr.GetConfig())
+
+	// The Secrets holding the certificates trigger the reconciliation of the WebServers using them, the watch caches
+	// only the metadata of the Secrets of WATCH_NAMESPACE, or of all the namespaces of the cluster without it
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &webserversv1alpha1.WebServer{}, secretIndex, func(obj client.Object) []string {
+		return r.certificateSecrets(obj.(*webserversv1alpha1.WebServer))
+	})
+	if err != nil {
+		return err
+	}
+
+	// The ConfigMaps holding the configuration files of tomcat trigger the reconciliation of the WebServers using them
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &webserversv1alpha1.WebServer{}, configMapIndex, func(obj client.Object) []string {
+		return tomcatConfigMaps(obj.(*webserversv1alpha1.WebServer))
+	})
+	if err != nil {
+		return err
 	}
 
+	// The status written by the operator doesn't need a reconciliation.
+	webServerChanged := predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&webserversv1alpha1.WebServer{}, builder.WithPredicates(webServerChanged)).
+		Owns(&kbappsv1.Deployment{}).
+		Owns(&kbappsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&batchv1.Job{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.webServerForObject),
+			builder.WithPredicates(hasWebServerLabel, podStatusChanged)).
+		Watches(&corev1.PersistentVolumeClaim{}, handler.EnqueueRequestsFromMapFunc(r.webServerForObject),
+			builder.WithPredicates(hasWebServerLabel)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.webServersForSecret),
+			builder.OnlyMetadata, builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.webServersForConfigMap),
+			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&autoscalingv2.HorizontalPodAutoscaler{}, handler.EnqueueRequestsFromMapFunc(r.webServerForAutoscaler))
+
+	if r.isOpenShift {
+		b = b.Owns(&routev1.Route{}).
+			Owns(&buildv1.BuildConfig{}).
+			Watches(&buildv1.Build{}, handler.EnqueueRequestsFromMapFunc(r.webServerForObject),
+				builder.WithPredicates(hasWebServerLabel, buildPhaseChanged))
+	}
+	if r.hasServiceMonitor {
+		b = b.Owns(&monitoringv1.ServiceMonitor{})
+	}
+	if r.hasCertManager {
+		b = b.Owns(newUnstructured(certificateGVK))
+	}
+	if r.hasShipwright {
+		b = b.Owns(newUnstructured(shipwrightBuildGVK)).
+			Owns(newUnstructured(shipwrightBuildRunGVK))
+	}
+	if !r.isOpenShift {
+		b = b.Owns(&networkingv1.Ingress{})
+		if r.hasGatewayAPI {
+			b = b.Owns(newUnstructured(httpRouteGVK))
+		}
+		if r.hasTLSRoute {
+			b = b.Owns(newUnstructured(tlsRouteGVK))
+		}
+	}
+	return b.Complete(r)
 }
 
 // var _ reconcile.Reconciler = &WebServerReconciler{}
@@ -65,43 +130,64 @@ type WebServerReconciler struct {
 	// scheme      *runtime.Scheme
 	client.Client
 	*runtime.Scheme
+	// APIReader reads the Secrets directly from the API server, only their metadata is cached
+	APIReader         client.Reader
 	isOpenShift       bool
 	hasServiceMonitor bool
+	hasGatewayAPI     bool
+	hasTLSRoute       bool
+	hasCertManager    bool
+	hasShipwright     bool
 	BuildClient       *buildclient.Clientset
+	Recorder          record.EventRecorder
 }
 
 // It seems we shouldn't mess up directly in role.yaml...
 // and it is probably needing a _very_ careful check here too !!
-// +kubebuilder:rbac:groups="core",resources=configmaps,verbs=create;get;list;delete;watch;update
-// +kubebuilder:rbac:groups="core",resources=pods,verbs=create;get;list;delete;watch
-// +kubebuilder:rbac:groups="core",resources=services,verbs=create;get;list;delete;watch
+// +kubebuilder:rbac:groups="core",resources=configmaps,verbs=create;get;list;delete;watch;update;patch
+// +kubebuilder:rbac:groups="core",resources=pods,verbs=create;get;list;delete;watch;patch
+// +kubebuilder:rbac:groups="core",resources=services,verbs=create;get;list;delete;watch;update;patch
 // +kubebuilder:rbac:groups="core",resources=persistentvolumeclaims,verbs=create;get;list;delete;watch
 // +kubebuilder:rbac:groups="core",resources=services/finalizers,verbs=update
 // +kubebuilder:rbac:groups="core",resources=namespaces,verbs=get
+// +kubebuilder:rbac:groups="core",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="core",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",resources=serviceaccounts,verbs=create;get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles;rolebindings,verbs=create;get;list;watch;update;patch;delete
 
 // +kubebuilder:rbac:groups="apps",resources=jws-operator,verbs=update
 // +kubebuilder:rbac:groups="apps",resources=deployments,verbs=create;get;list;delete;watch;update;patch
 // +kubebuilder:rbac:groups="apps",resources=deployments/finalizers,verbs=update
 // +kubebuilder:rbac:groups="apps",resources=statefulsets,verbs=create;get;list;delete;watch;update;patch
+// +kubebuilder:rbac:groups="apps",resources=replicasets;controllerrevisions,verbs=get;list;watch
+
+// +kubebuilder:rbac:groups="batch",resources=jobs,verbs=create;get;list;delete;watch
+// +kubebuilder:rbac:groups="autoscaling",resources=horizontalpodautoscalers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=shipwright.io,resources=builds,verbs=create;get;list;delete;watch;update;patch
+// +kubebuilder:rbac:groups=shipwright.io,resources=buildruns,verbs=create;get;list;delete;watch
 
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=create;get;
 
-// +kubebuilder:rbac:groups=image.openshift.io,resources=imagestreams,verbs=create;get;list;delete;watch
+// +kubebuilder:rbac:groups=image.openshift.io,resources=imagestreams,verbs=create;get;list;delete;watch;update;patch
 
-// +kubebuilder:rbac:groups=build.openshift.io,resources=buildconfigs,verbs=create;get;list;delete;update;watch
+// +kubebuilder:rbac:groups=build.openshift.io,resources=buildconfigs,verbs=create;get;list;delete;update;watch;patch
 // +kubebuilder:rbac:groups=build.openshift.io,resources=buildconfigs/instantiate,verbs=create;get;list;delete;update;watch
 // +kubebuilder:rbac:groups=build.openshift.io,resources=builds,verbs=create;get;list;delete;watch
 
-// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=create;get;list;delete;watch
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=create;get;list;delete;watch;update;patch
 // +kubebuilder:rbac:groups=route.openshift.io,resources=routes/custom-host,verbs=create;get;
 
 // +kubebuilder:rbac:groups=web.servers.org,resources=webservers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=web.servers.org,resources=webservers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=web.servers.org,resources=webservers/finalizers,verbs=update
 
-// +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=rolebindings,verbs=create;grant;get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=create;delete;get;list;watch;update;patch
 
-// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=create;delete;get;list;watch
+// +kubebuilder:rbac:groups="core",resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=create;get;list;delete;watch;update;patch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes;tlsroutes,verbs=create;get;list;delete;watch;update;patch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=create;get;list;delete;watch;update;patch
 
 // Reconcile reads that state of the cluster for a WebServer object and makes changes based on the state read
 // and what is in the WebServer.Spec
@@ -134,6 +220,13 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
+	if !webServer.DeletionTimestamp.IsZero() {
+		return r.finalizeWebServer(ctx, webServer)
+	}
+	if err := r.addFinalizer(ctx, webServer); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	webServer = r.setDefaultValues(webServer)
 
 	// Set the selector label, this should be done for the pods as well to allow targeting the CR with HPA
@@ -143,30 +236,27 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if r.hasServiceMonitor {
 
 		if serviceMonitor, err := r.GetOrCreateNewServiceMonitor(webServer, ctx, r.generateLabelsForWeb(webServer)); err != nil {
-			return reconcile.Result{}, err
+			return r.reconcileStep(ctx, webServer, "ServiceMonitor", reconcile.Result{}, err)
 		} else if serviceMonitor == nil {
 			log.Info("Webserver: Create Prometheus ServiceMonitor and requeue reconciliation")
-			return reconcile.Result{Requeue: true}, nil
+			return r.reconcileStep(ctx, webServer, "ServiceMonitor", reconcile.Result{Requeue: true}, nil)
 		}
 		if servicePrometeus, err := r.GetOrCreateNewPrometheusService(webServer, ctx, r.generateLabelsForWeb(webServer)); err != nil {
-			return reconcile.Result{}, err
+			return r.reconcileStep(ctx, webServer, "Prometheus Service", reconcile.Result{}, err)
 		} else if servicePrometeus == nil {
 			log.Info("Webserver: Create Prometheus Service and requeue reconciliation")
 			log.Info("Webserver resource (TLSSecret) " + webServer.Spec.TLSConfig.TLSSecret)
 			log.Info("Webserver resource (RouteHostname) " + webServer.Spec.TLSConfig.RouteHostname)
-			return reconcile.Result{Requeue: true}, nil
+			return r.reconcileStep(ctx, webServer, "Prometheus Service", reconcile.Result{Requeue: true}, nil)
 		}
 	}
 
 	if webServer.Spec.WebImageStream != nil && webServer.Spec.WebImage != nil {
-		log.Error(err, "Both the WebImageStream and WebImage fields are being used. Only one can be used.")
-		return ctrl.Result{}, err
+		return r.invalidSpec(ctx, webServer, "Both the WebImageStream and WebImage fields are being used. Only one can be used.")
 	} else if webServer.Spec.WebImageStream == nil && webServer.Spec.WebImage == nil {
-		log.Error(err, "WebImageStream or WebImage required")
-		return ctrl.Result{}, err
+		return r.invalidSpec(ctx, webServer, "WebImageStream or WebImage required")
 	} else if webServer.Spec.WebImageStream != nil && isKubernetes {
-		log.Error(err, "Image Streams can only be used in an Openshift cluster")
-		return ctrl.Result{}, nil
+		return r.invalidSpec(ctx, webServer, "Image Streams can only be used in an Openshift cluster")
 	}
 
 	// Check if a Service for routing already exists, and if not create a new one
@@ -182,25 +272,51 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		log.Info("generating routing service with port 8080 " + "with TLSSecret= " + webServer.Spec.TLSConfig.TLSSecret)
 		routingService = r.generateRoutingService(webServer, 8080)
 	}
-	result, err = r.createService(ctx, routingService, routingService.Name, routingService.Namespace)
+	result, err = r.applyResource(ctx, webServer, routingService)
 	if err != nil || result != (ctrl.Result{}) {
-		return result, err
+		return r.reconcileStep(ctx, webServer, "routing Service", result, err)
 	}
 
-	if webServer.Spec.UseSessionClustering {
-		result, err = r.useSessionClusteringConfig(ctx, webServer)
+	if webServer.Spec.TLSConfig.CertManager != nil && !r.hasCertManager {
+		log.Info("cert-manager isn't installed in the cluster, tlsConfig.certManager is ignored")
+	} else if r.useCertManager(webServer) {
+		issued, result, err := r.applyCertificate(ctx, webServer)
+		if err != nil || result != (ctrl.Result{}) {
+			return r.reconcileStep(ctx, webServer, "Certificate", result, err)
+		}
+		if !issued {
+			// The status update of the Certificate triggers the next reconciliation
+			return r.reconcileStep(ctx, webServer, "Certificate issuance", ctrl.Result{}, nil)
+		}
+	}
 
-		if err != nil {
-			return result, err
+	if replicatesSessions(webServer) {
+		result, err = r.useSessionClusteringConfig(ctx, webServer)
+		if err != nil || result != (ctrl.Result{}) {
+			return r.reconcileStep(ctx, webServer, "session clustering", result, err)
 		}
 	}
 
 	// Check if exists a ConfigMap for the server.xml <Cluster/> definition otherwise create it.
-	if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") || webServer.Spec.UseSessionClustering || webServer.Spec.PersistentLogsConfig.AccessLogs || webServer.Spec.PersistentLogsConfig.CatalinaLogs {
-		configMap := r.generateConfigMapForDNSTLS(webServer)
-		result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
+	if usesEnvFiles(webServer) {
+		// The HTTPS connector depends on the certificates of the TLS Secret
+		tlsSecret, err := r.getTLSSecret(ctx, webServer)
+		if err != nil {
+			return r.reconcileStep(ctx, webServer, "TLS Secret", reconcile.Result{}, err)
+		}
+		configMaps, err := r.getTomcatConfigMaps(ctx, webServer)
+		if err != nil {
+			return r.reconcileStep(ctx, webServer, "tomcatConfig ConfigMaps", reconcile.Result{}, err)
+		}
+		config, err := r.renderTomcatConfig(webServer, tlsSecret, configMaps)
+		if err != nil {
+			// The ConfigMaps are watched, there is no point retrying before they change
+			return r.invalidSpec(ctx, webServer, err.Error())
+		}
+		configMap := r.generateConfigMapForDNSTLS(webServer, config)
+		result, err = r.applyResource(ctx, webServer, configMap)
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "ConfigMap "+configMap.Name, result, err)
 		}
 	}
 
@@ -213,16 +329,16 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if health != nil {
 		if health.ServerLivenessScript != "" {
 			configMap := r.generateConfigMapForLivenessProbe(webServer)
-			result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
+			result, err = r.applyResource(ctx, webServer, configMap)
 			if err != nil || result != (ctrl.Result{}) {
-				return result, err
+				return r.reconcileStep(ctx, webServer, "ConfigMap "+configMap.Name, result, err)
 			}
 		}
 		if health.ServerReadinessScript != "" {
 			configMap := r.generateConfigMapForReadinessProbe(webServer)
-			result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
+			result, err = r.applyResource(ctx, webServer, configMap)
 			if err != nil || result != (ctrl.Result{}) {
-				return result, err
+				return r.reconcileStep(ctx, webServer, "ConfigMap "+configMap.Name, result, err)
 			}
 		}
 	}
@@ -230,25 +346,25 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if webServer.Spec.IsNotJWS {
 		// Check if exists a ConfigMap for ASF image start otherwise create it.
 		configMap := r.generateConfigMapForASFStart(webServer)
-		result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
+		result, err = r.applyResource(ctx, webServer, configMap)
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "ConfigMap "+configMap.Name, result, err)
 		}
 	}
 
 	if webServer.Spec.PersistentLogsConfig.CatalinaLogs || webServer.Spec.PersistentLogsConfig.AccessLogs {
 		// Check if exists a ConfigMap for the LoggingProperties otherwise create it.
 		configMap := r.generateConfigMapForLoggingProperties(webServer)
-		result, err = r.createConfigMap(ctx, configMap, configMap.Name, configMap.Namespace)
+		result, err = r.applyResource(ctx, webServer, configMap)
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "ConfigMap "+configMap.Name, result, err)
 		}
 
 		// Check if exists a PersistentVolumeClaim for logs otherwise create it.
 		persistentVolumeClaim := r.generatePersistentVolumeClaimForLogging(webServer)
-		result, err = r.createPersistentVolumeClaim(ctx, persistentVolumeClaim, persistentVolumeClaim.Name, persistentVolumeClaim.Namespace)
+		result, err = r.createPersistentVolumeClaim(ctx, webServer, persistentVolumeClaim, persistentVolumeClaim.Name, persistentVolumeClaim.Namespace)
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "PersistentVolumeClaim "+persistentVolumeClaim.Name, result, err)
 		}
 
 	}
@@ -259,9 +375,12 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 			// Check if a Route already exists, and if not create a new one
 			route := r.generateRoute(webServer)
-			result, err = r.createRoute(ctx, route, route.Name, route.Namespace)
+			if err := r.configureRoute(ctx, webServer, route); err != nil {
+				return r.reconcileStep(ctx, webServer, "Route", reconcile.Result{}, err)
+			}
+			result, err = r.applyResource(ctx, webServer, route)
 			if err != nil || result != (ctrl.Result{}) {
-				return result, err
+				return r.reconcileStep(ctx, webServer, "Route", result, err)
 			}
 
 			hosts := make([]string, len(route.Status.Ingress))
@@ -278,9 +397,12 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		} else if strings.HasPrefix(webServer.Spec.TLSConfig.RouteHostname, "tls") {
 			// Check if a Route already exists, and if not create a new one
 			route := r.generateSecureRoute(webServer)
-			result, err = r.createRoute(ctx, route, route.Name, route.Namespace)
+			if err := r.configureRoute(ctx, webServer, route); err != nil {
+				return r.reconcileStep(ctx, webServer, "Route", reconcile.Result{}, err)
+			}
+			result, err = r.applyResource(ctx, webServer, route)
 			if err != nil || result != (ctrl.Result{}) {
-				return result, err
+				return r.reconcileStep(ctx, webServer, "Route", result, err)
 			}
 
 			hosts := make([]string, len(route.Status.Ingress))
@@ -296,25 +418,17 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 		}
 	} else {
-		// on kuberntes we use a loadbalancer service
-		loadbalancer := r.generateLoadBalancer(webServer)
-		result, err = r.createService(ctx, loadbalancer, loadbalancer.Name, loadbalancer.Namespace)
-		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+		if exposureType(webServer) == webserversv1alpha1.ExposureGateway && !r.hasGatewayAPI {
+			return r.invalidSpec(ctx, webServer, "The Gateway API (HTTPRoute) isn't installed in the cluster")
 		}
-		if len(loadbalancer.Status.LoadBalancer.Ingress) == 0 {
-			return ctrl.Result{Requeue: true}, nil
+		if exposureType(webServer) == webserversv1alpha1.ExposureGateway && gatewayRouteGVK(webServer) == tlsRouteGVK && !r.hasTLSRoute {
+			return r.invalidSpec(ctx, webServer, "The TLSRoute of the Gateway API experimental channel isn't installed in the cluster")
 		}
-
-		hosts := make([]string, len(loadbalancer.Status.LoadBalancer.Ingress))
-		for i, ingress := range loadbalancer.Status.LoadBalancer.Ingress {
-			hosts[i] = ingress.Hostname
-			log.Info("Status.Hosts have: " + hosts[i])
+		hosts, result, err := r.exposeApplication(ctx, webServer)
+		if err != nil || result != (ctrl.Result{}) {
+			return r.reconcileStep(ctx, webServer, "exposure "+exposureType(webServer), result, err)
 		}
-		log.Info("Status.Hosts number of Ingress: " + strconv.Itoa(len(loadbalancer.Status.LoadBalancer.Ingress)))
-
-		sort.Strings(hosts)
-		if !reflect.DeepEqual(hosts, webServer.Status.Hosts) {
+		if !reflect.DeepEqual(hosts, webServer.Status.Hosts) && (len(hosts) > 0 || len(webServer.Status.Hosts) > 0) {
 			updateStatus = true
 			webServer.Status.Hosts = hosts
 			log.Info("Status.Hosts update scheduled")
@@ -325,33 +439,36 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		result, err = r.webImageConfiguration(ctx, webServer)
 
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "application deployment", result, err)
 		}
 
 	} else if webServer.Spec.WebImageStream != nil {
 		result, err = r.webImageSourceConfiguration(ctx, webServer)
 
 		if err != nil || result != (ctrl.Result{}) {
-			return result, err
+			return r.reconcileStep(ctx, webServer, "application deployment", result, err)
 		}
 	}
 
 	err = r.checkOwnedObjects(ctx, webServer)
 
 	if err != nil {
-		return ctrl.Result{}, err
+		return r.reconcileStep(ctx, webServer, "owned objects cleanup", ctrl.Result{}, err)
 	}
 
 	// List of pods which belongs under this webServer instance
 	podList, err := r.getPodList(ctx, webServer)
 	if err != nil {
 		log.Error(err, "Failed to get pod list.", "WebServer.Namespace", webServer.Namespace, "WebServer.Name", webServer.Name)
-		return reconcile.Result{}, err
+		return r.reconcileStep(ctx, webServer, "pod list", reconcile.Result{}, err)
 	}
 
+	// The replicas of the WebServer, or of the workload when a HorizontalPodAutoscaler scales it
+	foundReplicas, readyReplicas, desiredReplicas := r.getReplicaStatus(ctx, webServer)
+
 	// Make sure the number of active pods is the desired replica size.
 	numberOfDeployedPods := int32(len(podList.Items))
-	if numberOfDeployedPods != webServer.Spec.Replicas {
+	if numberOfDeployedPods != desiredReplicas {
 		log.Info("The number of deployed pods does not match the WebServer specification, reconciliation requeue scheduled")
 		requeue = true
 	}
@@ -368,7 +485,6 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	// Update the replicas
-	foundReplicas := r.getReplicaStatus(ctx, webServer)
 	if webServer.Status.Replicas != foundReplicas {
 		log.Info("Status.Replicas update scheduled")
 		webServer.Status.Replicas = foundReplicas
@@ -376,13 +492,34 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	// Update the scaledown
-	numberOfPodsToScaleDown := foundReplicas - webServer.Spec.Replicas
+	numberOfPodsToScaleDown := foundReplicas - desiredReplicas
 	if webServer.Status.ScalingdownPods != numberOfPodsToScaleDown {
 		log.Info("Status.ScalingdownPods update scheduled")
 		webServer.Status.ScalingdownPods = numberOfPodsToScaleDown
 		updateStatus = true
 	}
 
+	// Reload the rotated certificates in the running pods
+	reloadResult, err := r.reloadTLSCertificates(ctx, webServer)
+	if err != nil {
+		return r.reconcileStep(ctx, webServer, "certificate reload", ctrl.Result{}, err)
+	}
+
+	// Roll back a new revision of the pods which doesn't become ready
+	revisionChanged, result, err := r.checkRevision(ctx, webServer)
+	if err != nil {
+		return r.reconcileStep(ctx, webServer, "revision check", ctrl.Result{}, err)
+	}
+	if revisionChanged {
+		log.Info("Status revisions update scheduled")
+		updateStatus = true
+	}
+
+	if r.setFinalConditions(webServer, desiredReplicas, readyReplicas, requeue) {
+		log.Info("Status.Conditions update scheduled")
+		updateStatus = true
+	}
+
 	if updateStatus {
 
 		if err := r.Status().Update(ctx, webServer); err != nil {
@@ -398,10 +535,13 @@ func (r *WebServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	if requeue {
-		log.Info("Requeuing reconciliation")
-		return ctrl.Result{RequeueAfter: (500 * time.Millisecond)}, nil
+		// The watches on the pods and on the Deployment or StatefulSet trigger the next reconciliation
+		log.Info("Waiting for the pods to be ready")
 	}
 
 	log.Info("Reconciliation complete")
-	return ctrl.Result{}, nil
+	if result == (ctrl.Result{}) {
+		result = reloadResult
+	}
+	return result, nil
 }
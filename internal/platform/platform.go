@@ -0,0 +1,32 @@
+// Package platform discovers the kind of cluster the operator runs in, it is shared by the controller and
+// the webhooks.
+package platform
+
+import (
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("platform")
+
+// IsOpenShift returns true when the route.openshift.io API group is served by the cluster.
+func IsOpenShift(c *rest.Config) bool {
+	dcclient, err := discovery.NewDiscoveryClientForConfig(c)
+	if err != nil {
+		log.Info("isOpenShift discovery.NewDiscoveryClientForConfig has encountered a problem")
+		return false
+	}
+	apiList, err := dcclient.ServerGroups()
+	if err != nil {
+		log.Info("isOpenShift client.ServerGroups has encountered a problem")
+		return false
+	}
+	for _, v := range apiList.Groups {
+		if v.Name == "route.openshift.io" {
+			log.Info("route.openshift.io was found in apis, platform is OpenShift")
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package serverxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Element is an XML element of a configuration file of tomcat, it keeps the elements and the attributes the
+// operator doesn't model. A comment is an Element without Name.
+type Element struct {
+	Name     string
+	Attrs    []xml.Attr
+	Text     string
+	Comment  string
+	Children []*Element
+}
+
+// Parse parses a well-formed XML document and returns its root element.
+func Parse(data []byte) (*Element, error) {
+	elements, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var root *Element
+	for _, element := range elements {
+		if element.Name == "" {
+			continue
+		}
+		if root != nil {
+			return nil, errors.New("more than one root element: " + root.Name + " and " + element.Name)
+		}
+		root = element
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	}
+	return root, nil
+}
+
+// ParseFragment parses a well-formed sequence of XML elements.
+func ParseFragment(data []byte) ([]*Element, error) {
+	return parse(data)
+}
+
+// parse builds the elements, the prefixes of the names are kept as they are written.
+func parse(data []byte) ([]*Element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root := &Element{}
+	stack := []*Element{root}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &Element{Name: qualifiedName(t.Name), Attrs: t.Attr}
+			parent.Children = append(parent.Children, element)
+			stack = append(stack, element)
+		case xml.EndElement:
+			if len(stack) == 1 || qualifiedName(t.Name) != parent.Name {
+				line, _ := decoder.InputPos()
+				return nil, fmt.Errorf("line %d: unexpected end element </%s>", line, qualifiedName(t.Name))
+			}
+			parent.Text = strings.TrimSpace(parent.Text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 1 {
+				if strings.TrimSpace(string(t)) != "" {
+					return nil, errors.New("text outside of the elements")
+				}
+				continue
+			}
+			parent.Text += string(t)
+		case xml.Comment:
+			parent.Children = append(parent.Children, &Element{Comment: string(t)})
+		}
+	}
+	if len(stack) != 1 {
+		return nil, errors.New("element <" + stack[len(stack)-1].Name + "> not closed")
+	}
+	return root.Children, nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// ParseElement parses the XML of an element, like the rendering of a Connector or a Cluster.
+func ParseElement(element interface{}) (*Element, error) {
+	data, err := encodeFragment(element)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Attr returns the value of an attribute, empty when it isn't set.
+func (e *Element) Attr(name string) string {
+	for _, attr := range e.Attrs {
+		if qualifiedName(attr.Name) == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Child returns the first child with the name, nil when there is none.
+func (e *Element) Child(name string) *Element {
+	for _, child := range e.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Set replaces the first child for which same returns true by element, or adds element after the last child with
+// the same name, otherwise before the first child named in before, otherwise at the end.
+func (e *Element) Set(element *Element, same func(*Element) bool, before ...string) {
+	last := -1
+	for i, child := range e.Children {
+		if child.Name != element.Name {
+			continue
+		}
+		if same != nil && same(child) {
+			e.Children[i] = element
+			return
+		}
+		last = i
+	}
+	position := len(e.Children)
+	if last >= 0 {
+		position = last + 1
+	} else {
+		for i, child := range e.Children {
+			if contains(before, child.Name) {
+				position = i
+				break
+			}
+		}
+	}
+	e.Children = append(e.Children[:position], append([]*Element{element}, e.Children[position:]...)...)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sameAttr matches the elements with the same value of the attribute.
+func sameAttr(element *Element, name string) func(*Element) bool {
+	value := element.Attr(name)
+	return func(child *Element) bool {
+		return child.Attr(name) == value
+	}
+}
+
+// sameName matches the first element with the same name.
+func sameName(*Element) bool {
+	return true
+}
+
+// Render returns the XML document of the root element.
+func (e *Element) Render() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	e.render(&buffer, "")
+	return buffer.Bytes()
+}
+
+// RenderElements returns the XML of a sequence of elements, a fragment merged into a file by Merge.
+func RenderElements(elements []*Element) []byte {
+	var buffer bytes.Buffer
+	for _, element := range elements {
+		element.render(&buffer, "")
+	}
+	return buffer.Bytes()
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "\n", "&#xA;", "\t", "&#x9;")
+)
+
+func (e *Element) render(buffer *bytes.Buffer, indent string) {
+	buffer.WriteString(indent)
+	if e.Name == "" {
+		buffer.WriteString("<!--" + e.Comment + "-->\n")
+		return
+	}
+	buffer.WriteString("<" + e.Name)
+	for _, attr := range e.Attrs {
+		buffer.WriteString(" " + qualifiedName(attr.Name) + "=\"" + attrEscaper.Replace(attr.Value) + "\"")
+	}
+	switch {
+	case len(e.Children) > 0:
+		buffer.WriteString(">\n")
+		for _, child := range e.Children {
+			child.render(buffer, indent+"  ")
+		}
+		buffer.WriteString(indent + "</" + e.Name + ">\n")
+	case e.Text != "":
+		buffer.WriteString(">" + textEscaper.Replace(e.Text) + "</" + e.Name + ">\n")
+	default:
+		buffer.WriteString("/>\n")
+	}
+}
@@ -0,0 +1,165 @@
+package serverxml
+
+import (
+	"errors"
+)
+
+// The configuration files of tomcat in conf/
+const (
+	ServerXml      = "server.xml"
+	ContextXml     = "context.xml"
+	WebXml         = "web.xml"
+	TomcatUsersXml = "tomcat-users.xml"
+)
+
+// Files lists the configuration files of tomcat in the order they are installed.
+var Files = []string{ServerXml, ContextXml, WebXml, TomcatUsersXml}
+
+const defaultContextXml = `<Context>
+  <WatchedResource>WEB-INF/web.xml</WatchedResource>
+  <WatchedResource>WEB-INF/tomcat-web.xml</WatchedResource>
+  <WatchedResource>${catalina.base}/conf/web.xml</WatchedResource>
+</Context>`
+
+const defaultTomcatUsersXml = `<tomcat-users xmlns="http://tomcat.apache.org/xml" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://tomcat.apache.org/xml tomcat-users.xsd" version="1.0"/>`
+
+// DefaultFile returns the default content of a configuration file of tomcat, web.xml has no default: its servlets
+// and MIME mappings depend on the version of tomcat.
+func DefaultFile(file string) (*Element, error) {
+	switch file {
+	case ServerXml:
+		return ParseElement(NewServer())
+	case ContextXml:
+		return Parse([]byte(defaultContextXml))
+	case TomcatUsersXml:
+		return Parse([]byte(defaultTomcatUsersXml))
+	}
+	return nil, errors.New("no default " + file)
+}
+
+// Add adds an element of a fragment to a configuration file where tomcat expects it, the element replaces the
+// element it configures again: the Connector on the same port, the Valve of the same class...
+func Add(file string, root *Element, element *Element) error {
+	if element.Name == "" {
+		// The comments of the fragments are dropped
+		return nil
+	}
+	switch file {
+	case ServerXml:
+		return addToServer(root, element)
+	case ContextXml:
+		switch element.Name {
+		case "Manager", "Loader", "Resources", "CookieProcessor", "JarScanner":
+			root.Set(element, sameName)
+		case "Resource", "ResourceLink", "Environment", "Parameter":
+			root.Set(element, sameAttr(element, "name"))
+		case "Valve", "Listener":
+			root.Set(element, sameAttr(element, "className"))
+		default:
+			root.Set(element, nil)
+		}
+	case TomcatUsersXml:
+		switch element.Name {
+		case "role":
+			root.Set(element, sameAttr(element, "rolename"))
+		case "group":
+			root.Set(element, sameAttr(element, "groupname"))
+		case "user":
+			root.Set(element, sameAttr(element, "username"))
+		default:
+			return errors.New("<" + element.Name + "> can't be added to " + file)
+		}
+	default:
+		return errors.New("fragments can't be added to " + file)
+	}
+	return nil
+}
+
+// Merge adds the elements of a fragment to a configuration file of tomcat and returns the merged file. The pods
+// merge the fragments of the operator into the files of their image with it before tomcat starts.
+func Merge(file string, data []byte, fragment []byte) ([]byte, error) {
+	root, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := ParseFragment(fragment)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if err := Add(file, root, element); err != nil {
+			return nil, err
+		}
+	}
+	return root.Render(), nil
+}
+
+// addToServer adds the element to the Server, its GlobalNamingResources, its Service, the Engine or the default
+// Host depending on its name.
+func addToServer(server *Element, element *Element) error {
+	switch element.Name {
+	case "Listener":
+		server.Set(element, sameAttr(element, "className"), "GlobalNamingResources", "Service")
+		return nil
+	case "Resource", "ResourceLink", "Environment":
+		resources := server.Child("GlobalNamingResources")
+		if resources == nil {
+			resources = &Element{Name: "GlobalNamingResources"}
+			server.Set(resources, sameName, "Service")
+		}
+		resources.Set(element, sameAttr(element, "name"))
+		return nil
+	}
+
+	service := server.Child("Service")
+	if service == nil {
+		return errors.New("server.xml has no <Service>")
+	}
+	switch element.Name {
+	case "Executor":
+		service.Set(element, sameAttr(element, "name"), "Connector", "Engine")
+		return nil
+	case "Connector":
+		service.Set(element, sameAttr(element, "port"), "Engine")
+		return nil
+	}
+
+	engine := service.Child("Engine")
+	if engine == nil {
+		return errors.New("server.xml has no <Engine>")
+	}
+	switch element.Name {
+	case "Cluster":
+		engine.Set(element, sameName, "Realm", "Host", "Valve")
+		return nil
+	case "Realm":
+		engine.Set(element, sameName, "Host", "Valve")
+		return nil
+	}
+
+	host := defaultHost(engine)
+	if host == nil {
+		return errors.New("server.xml has no <Host>")
+	}
+	switch element.Name {
+	case "Valve":
+		host.Set(element, sameAttr(element, "className"))
+	case "Context":
+		host.Set(element, sameAttr(element, "path"))
+	case "Alias":
+		host.Set(element, nil, "Valve", "Context")
+	default:
+		return errors.New("<" + element.Name + "> can't be added to server.xml")
+	}
+	return nil
+}
+
+// defaultHost returns the Host processing the requests of the Engine.
+func defaultHost(engine *Element) *Element {
+	for _, child := range engine.Children {
+		if child.Name == "Host" && child.Attr("name") == engine.Attr("defaultHost") {
+			return child
+		}
+	}
+	return engine.Child("Host")
+}
@@ -0,0 +1,493 @@
+// Package serverxml models the elements of the server.xml of tomcat configured by the operator: the connectors
+// and their SSLHostConfig, the Cluster used for the session replication and the valves of the Host. It merges
+// them with the configuration files and the fragments supplied by the users.
+package serverxml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Server is the root element of server.xml.
+type Server struct {
+	XMLName               xml.Name               `xml:"Server"`
+	Comment               string                 `xml:",comment"`
+	Port                  int                    `xml:"port,attr"`
+	Shutdown              string                 `xml:"shutdown,attr"`
+	Listeners             []Listener             `xml:"Listener"`
+	GlobalNamingResources *GlobalNamingResources `xml:"GlobalNamingResources,omitempty"`
+	Services              []Service              `xml:"Service"`
+}
+
+// Listener is a LifecycleListener of the Server.
+type Listener struct {
+	ClassName string `xml:"className,attr"`
+}
+
+// GlobalNamingResources holds the global JNDI resources of the Server.
+type GlobalNamingResources struct {
+	Resources []Resource `xml:"Resource"`
+}
+
+// Resource is a JNDI resource.
+type Resource struct {
+	Name        string `xml:"name,attr"`
+	Auth        string `xml:"auth,attr,omitempty"`
+	Type        string `xml:"type,attr"`
+	Description string `xml:"description,attr,omitempty"`
+	Factory     string `xml:"factory,attr,omitempty"`
+	Pathname    string `xml:"pathname,attr,omitempty"`
+}
+
+// Service groups the connectors sharing an Engine.
+type Service struct {
+	Name       string      `xml:"name,attr"`
+	Connectors []Connector `xml:"Connector"`
+	Engine     Engine      `xml:"Engine"`
+}
+
+// Connector is an HTTP, HTTPS or AJP connector, the HTTPS connectors have SSLEnabled and an SSLHostConfig.
+type Connector struct {
+	Port                 int               `xml:"port,attr"`
+	Protocol             string            `xml:"protocol,attr"`
+	Address              string            `xml:"address,attr,omitempty"`
+	ConnectionTimeout    int               `xml:"connectionTimeout,attr,omitempty"`
+	KeepAliveTimeout     *int              `xml:"keepAliveTimeout,attr,omitempty"`
+	MaxKeepAliveRequests *int              `xml:"maxKeepAliveRequests,attr,omitempty"`
+	RedirectPort         int               `xml:"redirectPort,attr,omitempty"`
+	MaxThreads           int               `xml:"maxThreads,attr,omitempty"`
+	AcceptCount          int               `xml:"acceptCount,attr,omitempty"`
+	Compression          string            `xml:"compression,attr,omitempty"`
+	CompressibleMimeType string            `xml:"compressibleMimeType,attr,omitempty"`
+	CompressionMinSize   *int              `xml:"compressionMinSize,attr,omitempty"`
+	Secret               string            `xml:"secret,attr,omitempty"`
+	SSLEnabled           bool              `xml:"SSLEnabled,attr,omitempty"`
+	UpgradeProtocols     []UpgradeProtocol `xml:"UpgradeProtocol"`
+	SSLHostConfigs       []SSLHostConfig   `xml:"SSLHostConfig"`
+}
+
+// UpgradeProtocol is a protocol the clients of a connector can upgrade to, like HTTP/2.
+type UpgradeProtocol struct {
+	ClassName string `xml:"className,attr"`
+}
+
+// SSLHostConfig configures the TLS of an HTTPS connector.
+type SSLHostConfig struct {
+	Protocols               string        `xml:"protocols,attr,omitempty"`
+	Ciphers                 string        `xml:"ciphers,attr,omitempty"`
+	CACertificateFile       string        `xml:"caCertificateFile,attr,omitempty"`
+	CertificateVerification string        `xml:"certificateVerification,attr,omitempty"`
+	Certificates            []Certificate `xml:"Certificate"`
+}
+
+// Certificate is a certificate and its key in PEM format.
+type Certificate struct {
+	CertificateFile    string `xml:"certificateFile,attr"`
+	CertificateKeyFile string `xml:"certificateKeyFile,attr"`
+}
+
+// Engine processes the requests of the connectors of its Service.
+type Engine struct {
+	Name        string   `xml:"name,attr"`
+	DefaultHost string   `xml:"defaultHost,attr"`
+	Cluster     *Cluster `xml:"Cluster,omitempty"`
+	Realm       *Realm   `xml:"Realm,omitempty"`
+	Hosts       []Host   `xml:"Host"`
+}
+
+// Realm authenticates the users, a Realm can nest other realms (LockOutRealm, CombinedRealm).
+type Realm struct {
+	ClassName    string  `xml:"className,attr"`
+	ResourceName string  `xml:"resourceName,attr,omitempty"`
+	Realms       []Realm `xml:"Realm"`
+}
+
+// Host is a virtual host.
+type Host struct {
+	Name       string  `xml:"name,attr"`
+	AppBase    string  `xml:"appBase,attr"`
+	UnpackWARs bool    `xml:"unpackWARs,attr"`
+	AutoDeploy bool    `xml:"autoDeploy,attr"`
+	Valves     []Valve `xml:"Valve"`
+}
+
+// Valve is a valve of the Host, its attributes depend on its class.
+type Valve struct {
+	ClassName string     `xml:"className,attr"`
+	Attrs     []xml.Attr `xml:",any,attr"`
+}
+
+// Cluster replicates the sessions between the pods.
+type Cluster struct {
+	ClassName          string   `xml:"className,attr"`
+	ChannelSendOptions int      `xml:"channelSendOptions,attr,omitempty"`
+	Manager            *Manager `xml:"Manager,omitempty"`
+	Channel            *Channel `xml:"Channel,omitempty"`
+}
+
+// Manager manages the sessions of the applications: the template of the managers of a Cluster, or the Manager
+// of context.xml.
+type Manager struct {
+	ClassName                    string `xml:"className,attr"`
+	ExpireSessionsOnShutdown     *bool  `xml:"expireSessionsOnShutdown,attr,omitempty"`
+	NotifyListenersOnReplication bool   `xml:"notifyListenersOnReplication,attr,omitempty"`
+	MapSendOptions               int    `xml:"mapSendOptions,attr,omitempty"`
+	SaveOnRestart                bool   `xml:"saveOnRestart,attr,omitempty"`
+	MaxIdleBackup                *int   `xml:"maxIdleBackup,attr,omitempty"`
+	ConfigPath                   string `xml:"configPath,attr,omitempty"`
+	ReadMode                     string `xml:"readMode,attr,omitempty"`
+	UpdateMode                   string `xml:"updateMode,attr,omitempty"`
+	URI                          string `xml:"uri,attr,omitempty"`
+	Store                        *Store `xml:"Store,omitempty"`
+}
+
+// Store persists the sessions of a PersistentManager.
+type Store struct {
+	ClassName          string `xml:"className,attr"`
+	Directory          string `xml:"directory,attr,omitempty"`
+	DriverName         string `xml:"driverName,attr,omitempty"`
+	ConnectionURL      string `xml:"connectionURL,attr,omitempty"`
+	ConnectionName     string `xml:"connectionName,attr,omitempty"`
+	ConnectionPassword string `xml:"connectionPassword,attr,omitempty"`
+}
+
+// Channel is the group communication of the Cluster.
+type Channel struct {
+	ClassName    string        `xml:"className,attr"`
+	Membership   *Membership   `xml:"Membership,omitempty"`
+	Receiver     *Receiver     `xml:"Receiver,omitempty"`
+	Interceptors []Interceptor `xml:"Interceptor"`
+}
+
+// Receiver receives the messages of the other members of the Channel.
+type Receiver struct {
+	ClassName string `xml:"className,attr"`
+	Address   string `xml:"address,attr,omitempty"`
+	Port      int    `xml:"port,attr"`
+}
+
+// Interceptor processes the messages of the Channel, the StaticMembershipInterceptor has static members.
+type Interceptor struct {
+	ClassName string         `xml:"className,attr"`
+	Members   []StaticMember `xml:"Member"`
+}
+
+// StaticMember is a member of the Channel which is always considered alive.
+type StaticMember struct {
+	ClassName string `xml:"className,attr"`
+	Host      string `xml:"host,attr"`
+	Port      int    `xml:"port,attr"`
+	UniqueID  string `xml:"uniqueId,attr"`
+}
+
+// Membership finds the members of the Cluster.
+type Membership struct {
+	ClassName                   string `xml:"className,attr"`
+	MembershipProviderClassName string `xml:"membershipProviderClassName,attr,omitempty"`
+}
+
+const (
+	// HTTPPort is the port of the HTTP connector
+	HTTPPort = 8080
+	// HTTPSPort is the port of the HTTPS connector
+	HTTPSPort = 8443
+	// AJPPort is the port of the AJP connector
+	AJPPort = 8009
+	// HTTP11Nio2Protocol is the HTTP protocol using NIO2, HTTP/1.1 uses NIO
+	HTTP11Nio2Protocol = "org.apache.coyote.http11.Http11Nio2Protocol"
+	// AJPNio2Protocol is the AJP protocol using NIO2, AJP/1.3 uses NIO
+	AJPNio2Protocol = "org.apache.coyote.ajp.AjpNio2Protocol"
+	// HTTP2Protocol is the UpgradeProtocol of HTTP/2
+	HTTP2Protocol = "org.apache.coyote.http2.Http2Protocol"
+	// KubernetesMembershipProvider finds the members with the API server, it needs to view the pods
+	KubernetesMembershipProvider = "org.apache.catalina.tribes.membership.cloud.KubernetesMembershipProvider"
+	// DNSMembershipProvider finds the members with the DNS records of a headless Service
+	DNSMembershipProvider = "org.apache.catalina.tribes.membership.cloud.DNSMembershipProvider"
+	// ReplicationPort is the default port of the Receiver of the Cluster
+	ReplicationPort = 4000
+	// SynchronousSendOptions waits for the acknowledgement of the messages (SYNCHRONIZED_ACK and USE_ACK)
+	SynchronousSendOptions = 6
+	// AsynchronousSendOptions sends the messages in the background
+	AsynchronousSendOptions = 8
+)
+
+// NewServer returns the server.xml of tomcat with the access logs written to the standard output and the client
+// address taken from the X-Forwarded-For header of the router.
+func NewServer() *Server {
+	return &Server{
+		Comment:  " Generated by the JWS operator ",
+		Port:     8005,
+		Shutdown: "SHUTDOWN",
+		Listeners: []Listener{
+			{ClassName: "org.apache.catalina.startup.VersionLoggerListener"},
+			{ClassName: "org.apache.catalina.core.AprLifecycleListener"},
+			{ClassName: "org.apache.catalina.core.JreMemoryLeakPreventionListener"},
+			{ClassName: "org.apache.catalina.mbeans.GlobalResourcesLifecycleListener"},
+			{ClassName: "org.apache.catalina.core.ThreadLocalLeakPreventionListener"},
+		},
+		GlobalNamingResources: &GlobalNamingResources{
+			Resources: []Resource{{
+				Name:        "UserDatabase",
+				Auth:        "Container",
+				Type:        "org.apache.catalina.UserDatabase",
+				Description: "User database that can be updated and saved",
+				Factory:     "org.apache.catalina.users.MemoryUserDatabaseFactory",
+				Pathname:    "conf/tomcat-users.xml",
+			}},
+		},
+		Services: []Service{{
+			Name:       "Catalina",
+			Connectors: []Connector{HTTPConnector()},
+			Engine: Engine{
+				Name:        "Catalina",
+				DefaultHost: "localhost",
+				Realm: &Realm{
+					ClassName: "org.apache.catalina.realm.LockOutRealm",
+					Realms: []Realm{{
+						ClassName:    "org.apache.catalina.realm.UserDatabaseRealm",
+						ResourceName: "UserDatabase",
+					}},
+				},
+				Hosts: []Host{{
+					Name:       "localhost",
+					AppBase:    "webapps",
+					UnpackWARs: true,
+					AutoDeploy: true,
+					Valves: []Valve{
+						RemoteIPValve(),
+						AccessLogValve("/proc/self/fd", "1", ""),
+					},
+				}},
+			},
+		}},
+	}
+}
+
+// HTTPConnector returns the HTTP connector of tomcat.
+func HTTPConnector() Connector {
+	return Connector{
+		Port:              HTTPPort,
+		Protocol:          "HTTP/1.1",
+		ConnectionTimeout: 20000,
+		RedirectPort:      HTTPSPort,
+	}
+}
+
+// HTTPSConnector returns the HTTPS connector of tomcat for the certificate and the key, caCertificateFile verifies
+// the client certificates following certificateVerification.
+func HTTPSConnector(certificateFile, certificateKeyFile, caCertificateFile, certificateVerification string) Connector {
+	return Connector{
+		Port:       HTTPSPort,
+		Protocol:   "HTTP/1.1",
+		MaxThreads: 200,
+		SSLEnabled: true,
+		SSLHostConfigs: []SSLHostConfig{{
+			CACertificateFile:       caCertificateFile,
+			CertificateVerification: certificateVerification,
+			Certificates: []Certificate{{
+				CertificateFile:    certificateFile,
+				CertificateKeyFile: certificateKeyFile,
+			}},
+		}},
+	}
+}
+
+// AJPConnector returns an AJP connector listening on all the addresses, the proxy must send the secret.
+func AJPConnector(secret string) Connector {
+	return Connector{
+		Port:         AJPPort,
+		Protocol:     "AJP/1.3",
+		Address:      "0.0.0.0",
+		RedirectPort: HTTPSPort,
+		Secret:       secret,
+	}
+}
+
+// CloudCluster returns a Cluster replicating the sessions between the members found by membershipProvider.
+func CloudCluster(membershipProvider string) *Cluster {
+	return &Cluster{
+		ClassName:          "org.apache.catalina.ha.tcp.SimpleTcpCluster",
+		ChannelSendOptions: SynchronousSendOptions,
+		Channel: &Channel{
+			ClassName: "org.apache.catalina.tribes.group.GroupChannel",
+			Membership: &Membership{
+				ClassName:                   "org.apache.catalina.tribes.membership.cloud.CloudMembershipService",
+				MembershipProviderClassName: membershipProvider,
+			},
+		},
+	}
+}
+
+// DeltaManager returns the manager replicating the changes of the sessions to all the members of the Cluster.
+func DeltaManager() *Manager {
+	expireSessionsOnShutdown := false
+	return &Manager{
+		ClassName:                    "org.apache.catalina.ha.session.DeltaManager",
+		ExpireSessionsOnShutdown:     &expireSessionsOnShutdown,
+		NotifyListenersOnReplication: true,
+	}
+}
+
+// BackupManager returns the manager replicating each session to a single backup member of the Cluster.
+func BackupManager(mapSendOptions int) *Manager {
+	return &Manager{
+		ClassName:                    "org.apache.catalina.ha.session.BackupManager",
+		NotifyListenersOnReplication: true,
+		MapSendOptions:               mapSendOptions,
+	}
+}
+
+// PersistentManager returns the manager of context.xml saving the sessions in the files of directory, they are
+// saved once idle for a minute and when tomcat stops.
+func PersistentManager(directory string) *Manager {
+	maxIdleBackup := 60
+	return &Manager{
+		ClassName:     "org.apache.catalina.session.PersistentManager",
+		SaveOnRestart: true,
+		MaxIdleBackup: &maxIdleBackup,
+		Store: &Store{
+			ClassName: "org.apache.catalina.session.FileStore",
+			Directory: directory,
+		},
+	}
+}
+
+// RedissonManager returns the manager of context.xml keeping the sessions in Redis with the Redisson client
+// configured by the YAML file configPath. The attributes of the sessions are read from Redis on each request, so
+// any pod can serve any session.
+func RedissonManager(configPath string) *Manager {
+	return &Manager{
+		ClassName:  "org.redisson.tomcat.RedissonSessionManager",
+		ConfigPath: configPath,
+		ReadMode:   "REDIS",
+		UpdateMode: "DEFAULT",
+	}
+}
+
+// HotRodManager returns the manager of context.xml keeping the sessions in a remote Infinispan cluster reached
+// with the HotRod uri.
+func HotRodManager(uri string) *Manager {
+	return &Manager{
+		ClassName: "org.wildfly.clustering.tomcat.hotrod.HotRodManager",
+		URI:       uri,
+	}
+}
+
+// JDBCManager returns the manager of context.xml saving the sessions in a database with the JDBC driver, they are
+// saved once the background processor of tomcat runs and when tomcat stops. The sessions stay in memory, another
+// tomcat reads one only when it doesn't hold it: the database fails the sessions over, it doesn't share them.
+func JDBCManager(driverName, url, username, password string) *Manager {
+	maxIdleBackup := 0
+	return &Manager{
+		ClassName:     "org.apache.catalina.session.PersistentManager",
+		SaveOnRestart: true,
+		MaxIdleBackup: &maxIdleBackup,
+		Store: &Store{
+			ClassName:          "org.apache.catalina.session.JDBCStore",
+			DriverName:         driverName,
+			ConnectionURL:      url,
+			ConnectionName:     username,
+			ConnectionPassword: password,
+		},
+	}
+}
+
+// NioReceiver returns the Receiver listening on port on the address of the pod.
+func NioReceiver(port int) *Receiver {
+	return &Receiver{
+		ClassName: "org.apache.catalina.tribes.transport.nio.NioReceiver",
+		Address:   "auto",
+		Port:      port,
+	}
+}
+
+// StaticMembershipInterceptors returns the interceptors of a Channel with the static members listening on port,
+// the interceptors of the Cluster must be listed with them. The unique id of a member is derived from its host.
+func StaticMembershipInterceptors(port int, hosts ...string) []Interceptor {
+	static := Interceptor{ClassName: "org.apache.catalina.tribes.group.interceptors.StaticMembershipInterceptor"}
+	for _, host := range hosts {
+		sum := sha256.Sum256([]byte(host))
+		id := make([]string, 16)
+		for i := range id {
+			// The bytes are parsed as signed values
+			id[i] = strconv.Itoa(int(int8(sum[i])))
+		}
+		static.Members = append(static.Members, StaticMember{
+			ClassName: "org.apache.catalina.tribes.membership.StaticMember",
+			Host:      host,
+			Port:      port,
+			UniqueID:  "{" + strings.Join(id, ",") + "}",
+		})
+	}
+	return []Interceptor{
+		{ClassName: "org.apache.catalina.tribes.group.interceptors.TcpPingInterceptor"},
+		{ClassName: "org.apache.catalina.tribes.group.interceptors.TcpFailureDetector"},
+		{ClassName: "org.apache.catalina.tribes.group.interceptors.MessageDispatchInterceptor"},
+		static,
+	}
+}
+
+// AccessLogValve returns the valve writing the access logs in directory, the names of the files are made of the
+// prefix, the date when suffix isn't empty, and the suffix.
+func AccessLogValve(directory, prefix, suffix string) Valve {
+	attrs := []xml.Attr{
+		{Name: xml.Name{Local: "directory"}, Value: directory},
+		{Name: xml.Name{Local: "prefix"}, Value: prefix},
+		{Name: xml.Name{Local: "suffix"}, Value: suffix},
+	}
+	if suffix == "" {
+		// A stream like /proc/self/fd/1 can't be rotated
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "rotatable"}, Value: "false"})
+	}
+	attrs = append(attrs,
+		xml.Attr{Name: xml.Name{Local: "requestAttributesEnabled"}, Value: "true"},
+		xml.Attr{Name: xml.Name{Local: "pattern"}, Value: `%h %l %u %t "%r" %s %b`},
+	)
+	return Valve{ClassName: "org.apache.catalina.valves.AccessLogValve", Attrs: attrs}
+}
+
+// RemoteIPValve returns the valve replacing the address and the scheme of the router by the ones of the client.
+func RemoteIPValve() Valve {
+	return Valve{
+		ClassName: "org.apache.catalina.valves.RemoteIpValve",
+		Attrs: []xml.Attr{
+			{Name: xml.Name{Local: "remoteIpHeader"}, Value: "X-Forwarded-For"},
+			{Name: xml.Name{Local: "protocolHeader"}, Value: "X-Forwarded-Proto"},
+		},
+	}
+}
+
+// Render returns the server.xml document.
+func (s *Server) Render() ([]byte, error) {
+	fragment, err := RenderFragment(s)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), fragment...), nil
+}
+
+// RenderFragment returns the XML of an element of server.xml, like a Connector or a Cluster. The elements without
+// content are self-closed like in the files of tomcat.
+func RenderFragment(element interface{}) ([]byte, error) {
+	parsed, err := ParseElement(element)
+	if err != nil {
+		return nil, err
+	}
+	return RenderElements([]*Element{parsed}), nil
+}
+
+// encodeFragment returns the XML of an element of server.xml as encoded by encoding/xml, which closes the elements
+// without content with an end tag.
+func encodeFragment(element interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(element); err != nil {
+		return nil, err
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package serverxml
+
+import (
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// go test ./internal/serverxml -args -update rewrites the golden files
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// expectGolden compares the rendered XML with testdata/name.
+func expectGolden(name string, rendered []byte) {
+	golden := filepath.Join("testdata", name)
+	if *update {
+		Expect(os.WriteFile(golden, rendered, 0644)).To(Succeed())
+	}
+	expected, err := os.ReadFile(golden)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(rendered)).To(Equal(string(expected)))
+	// The golden files must stay well-formed
+	Expect(xml.Unmarshal(rendered, &struct{}{})).To(Succeed())
+}
+
+// tunedHTTPSConnector returns an HTTPS connector using NIO2 and HTTP/2 with compression and TLSv1.3 only.
+func tunedHTTPSConnector() Connector {
+	connector := HTTPSConnector("/tls/server.crt", "/tls/server.key", "", "")
+	keepAliveTimeout, maxKeepAliveRequests := 5000, -1
+	connector.Protocol = HTTP11Nio2Protocol
+	connector.MaxThreads = 400
+	connector.AcceptCount = 200
+	connector.KeepAliveTimeout = &keepAliveTimeout
+	connector.MaxKeepAliveRequests = &maxKeepAliveRequests
+	connector.Compression = "on"
+	connector.CompressibleMimeType = "text/html,application/json"
+	connector.UpgradeProtocols = []UpgradeProtocol{{ClassName: HTTP2Protocol}}
+	connector.SSLHostConfigs[0].Protocols = "TLSv1.3"
+	connector.SSLHostConfigs[0].Ciphers = "HIGH:!aNULL:!MD5"
+	return connector
+}
+
+// backupCluster returns an asynchronous Cluster with a BackupManager, a Receiver on port 5000 and static members.
+func backupCluster() *Cluster {
+	cluster := CloudCluster(DNSMembershipProvider)
+	cluster.ChannelSendOptions = AsynchronousSendOptions
+	cluster.Manager = BackupManager(AsynchronousSendOptions)
+	cluster.Channel.Receiver = NioReceiver(5000)
+	cluster.Channel.Interceptors = StaticMembershipInterceptors(5000, "tomcat-0.webserver-tomcat.other.svc")
+	return cluster
+}
+
+// add adds the rendered elements to the configuration file.
+func add(file string, root *Element, elements ...interface{}) {
+	for _, element := range elements {
+		parsed, err := ParseElement(element)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Add(file, root, parsed)).To(Succeed())
+	}
+}
+
+var _ = Describe("server.xml", func() {
+	It("Should render the default server.xml", func() {
+		server, err := DefaultFile(ServerXml)
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("default.xml", server.Render())
+	})
+
+	It("Should render the HTTPS connector, the cluster and the access logs", func() {
+		server, err := DefaultFile(ServerXml)
+		Expect(err).NotTo(HaveOccurred())
+		add(ServerXml, server,
+			HTTPSConnector("/tls/server.crt", "/tls/server.key", "/tls/ca.crt", "required"),
+			CloudCluster(KubernetesMembershipProvider),
+			AccessLogValve("/opt/tomcat_logs", "access-${pod_name}", ".log"))
+		expectGolden("tls-cluster-access-logs.xml", server.Render())
+
+		// The access log valve replaces the one of the default server.xml
+		host := server.Child("Service").Child("Engine").Child("Host")
+		Expect(host.Children).To(HaveLen(2))
+	})
+
+	DescribeTable("Should render the fragments",
+		func(golden string, element interface{}) {
+			rendered, err := RenderFragment(element)
+			Expect(err).NotTo(HaveOccurred())
+			expectGolden(golden, rendered)
+		},
+		Entry("HTTPS connector without CA", "https-connector.xml", HTTPSConnector("/tls/server.crt", "/tls/server.key", "", "")),
+		Entry("Tuned HTTPS connector", "tuned-https-connector.xml", tunedHTTPSConnector()),
+		Entry("AJP connector", "ajp-connector.xml", AJPConnector("${AJP_SECRET}")),
+		Entry("Backup cluster with static members", "backup-cluster.xml", backupCluster()),
+		Entry("Delta manager", "delta-manager.xml", DeltaManager()),
+		Entry("Persistent manager", "persistent-manager.xml", PersistentManager("/sessions")),
+		Entry("Redisson manager", "redisson-manager.xml", RedissonManager("${catalina.base}/conf/redisson.yaml")),
+		Entry("HotRod manager", "hotrod-manager.xml", HotRodManager("${SESSION_STORE_URI}")),
+		Entry("JDBC manager", "jdbc-manager.xml", JDBCManager("org.postgresql.Driver", "${SESSION_STORE_URL}", "${SESSION_STORE_USERNAME}", "${SESSION_STORE_PASSWORD}")),
+		Entry("DNS cluster", "dns-cluster.xml", CloudCluster(DNSMembershipProvider)),
+		Entry("Remote IP valve", "remote-ip-valve.xml", RemoteIPValve()),
+	)
+})
+
+var _ = Describe("Configuration files", func() {
+	It("Should merge the fragments and the elements of the operator into server.xml", func() {
+		data, err := os.ReadFile(filepath.Join("testdata", "user-server.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		server, err := Parse(data)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err = os.ReadFile(filepath.Join("testdata", "server-fragment.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		fragment, err := ParseFragment(data)
+		Expect(err).NotTo(HaveOccurred())
+		for _, element := range fragment {
+			Expect(Add(ServerXml, server, element)).To(Succeed())
+		}
+		add(ServerXml, server, HTTPSConnector("/tls/server.crt", "/tls/server.key", "", ""), CloudCluster(DNSMembershipProvider))
+		expectGolden("merged-server.xml", server.Render())
+	})
+
+	It("Should merge a rendered fragment into the server.xml of an image", func() {
+		data, err := os.ReadFile(filepath.Join("testdata", "server-fragment.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		fragment, err := ParseFragment(data)
+		Expect(err).NotTo(HaveOccurred())
+		for _, element := range []interface{}{HTTPSConnector("/tls/server.crt", "/tls/server.key", "", ""), CloudCluster(DNSMembershipProvider)} {
+			parsed, err := ParseElement(element)
+			Expect(err).NotTo(HaveOccurred())
+			fragment = append(fragment, parsed)
+		}
+
+		data, err = os.ReadFile(filepath.Join("testdata", "user-server.xml"))
+		Expect(err).NotTo(HaveOccurred())
+		merged, err := Merge(ServerXml, data, RenderElements(fragment))
+		Expect(err).NotTo(HaveOccurred())
+		expectGolden("merged-server.xml", merged)
+
+		_, err = Merge(ServerXml, data, []byte(`<Engine/>`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should add the fragments to context.xml and tomcat-users.xml", func() {
+		context, err := DefaultFile(ContextXml)
+		Expect(err).NotTo(HaveOccurred())
+		fragment, err := ParseFragment([]byte(`<Resource name="jdbc/db" auth="Container" type="javax.sql.DataSource" url="jdbc:postgresql://db:5432/app?ssl=true&amp;sslmode=require"/>
+<!-- sessions aren't persisted -->
+<Manager pathname=""/>`))
+		Expect(err).NotTo(HaveOccurred())
+		for _, element := range fragment {
+			Expect(Add(ContextXml, context, element)).To(Succeed())
+		}
+		expectGolden("context.xml", context.Render())
+
+		users, err := DefaultFile(TomcatUsersXml)
+		Expect(err).NotTo(HaveOccurred())
+		fragment, err = ParseFragment([]byte(`<role rolename="manager-gui"/><user username="admin" password="secret" roles="manager-gui"/>`))
+		Expect(err).NotTo(HaveOccurred())
+		for _, element := range fragment {
+			Expect(Add(TomcatUsersXml, users, element)).To(Succeed())
+		}
+		expectGolden("tomcat-users.xml", users.Render())
+	})
+
+	It("Should reject the elements tomcat doesn't expect", func() {
+		server, err := DefaultFile(ServerXml)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Add(ServerXml, server, &Element{Name: "Engine"})).NotTo(Succeed())
+		users, err := DefaultFile(TomcatUsersXml)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Add(TomcatUsersXml, users, &Element{Name: "Realm"})).NotTo(Succeed())
+		_, err = DefaultFile(WebXml)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Should reject the malformed files",
+		func(data string) {
+			_, err := Parse([]byte(data))
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("end element mismatch", `<Server><Service></Server></Service>`),
+		Entry("element not closed", `<Server><Service>`),
+		Entry("two roots", `<Server/><Server/>`),
+		Entry("no root", `<!-- empty -->`),
+		Entry("text outside of the root", `<Server/>text`),
+		Entry("unquoted attribute", `<Server port=8005/>`),
+	)
+})
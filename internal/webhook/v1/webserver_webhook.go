@@ -0,0 +1,91 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	webserversv1 "github.com/web-servers/jws-operator/api/v1"
+)
+
+// log is for logging in this package.
+var webserverlog = logf.Log.WithName("webserver-v1-resource")
+
+// SetupWebServerWebhookWithManager registers the conversion webhook for WebServer in the manager.
+// v1alpha1 is the hub (and the storage version), v1 is converted from and to it. The validating
+// and defaulting webhooks of v1alpha1 also handle the v1 requests once converted by the API server,
+// the v1 validating webhook only rejects what v1alpha1 can't express.
+func SetupWebServerWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&webserversv1.WebServer{}).
+		WithValidator(&WebServerCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-web-servers-org-v1-webserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=web.servers.org,resources=webservers,verbs=create;update,versions=v1,name=vwebserver-v1.kb.io,admissionReviewVersions=v1
+
+// WebServerCustomValidator rejects the v1 WebServers which would change when converted to v1alpha1.
+type WebServerCustomValidator struct{}
+
+var _ webhook.CustomValidator = &WebServerCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type WebServer.
+func (v *WebServerCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	webserver, ok := obj.(*webserversv1.WebServer)
+	if !ok {
+		return nil, fmt.Errorf("expected a WebServer object but got %T", obj)
+	}
+	webserverlog.Info("Validation for WebServer upon creation", "name", webserver.GetName())
+	return nil, validateConversion(webserver)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type WebServer.
+func (v *WebServerCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	webserver, ok := newObj.(*webserversv1.WebServer)
+	if !ok {
+		return nil, fmt.Errorf("expected a WebServer object for the newObj but got %T", newObj)
+	}
+	webserverlog.Info("Validation for WebServer upon update", "name", webserver.GetName())
+	return nil, validateConversion(webserver)
+}
+
+// ValidateDelete implements webhook.CustomValidator, nothing is checked on deletion.
+func (v *WebServerCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateConversion rejects the fields v1alpha1 has no room for: its routeHostname (tls, NONE or a hostname)
+// can't enable TLS without the route.
+func validateConversion(webserver *webserversv1.WebServer) error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	if webserver.Spec.TLS.Enabled && webserver.Spec.Exposure.Route.Disabled {
+		errs = append(errs, field.Forbidden(specPath.Child("exposure", "route", "disabled"), "the route can't be disabled when tls is enabled"))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(webserversv1.GroupVersion.WithKind("WebServer").GroupKind(), webserver.Name, errs)
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webserversorgv1 "github.com/web-servers/jws-operator/api/v1"
+	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+)
+
+var _ = Describe("WebServer Webhook", func() {
+	var (
+		hub   *webserversorgv1alpha1.WebServer
+		spoke *webserversorgv1.WebServer
+	)
+
+	BeforeEach(func() {
+		hub = &webserversorgv1alpha1.WebServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+			Spec: webserversorgv1alpha1.WebServerSpec{
+				ApplicationName: "example-app",
+				Replicas:        2,
+			},
+		}
+		spoke = &webserversorgv1.WebServer{}
+	})
+
+	// roundTrip converts the hub to v1 and back.
+	roundTrip := func() *webserversorgv1alpha1.WebServer {
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		converted := &webserversorgv1alpha1.WebServer{}
+		Expect(spoke.ConvertTo(converted)).To(Succeed())
+		return converted
+	}
+
+	Context("When converting WebServer under Conversion Webhook", func() {
+		It("Should convert an image with a pod build", func() {
+			hub.Spec.IsNotJWS = true
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{
+				ApplicationImage: "quay.io/jfclere/tomcat10:latest",
+				ImagePullSecret:  "pull-secret",
+				WebApp: &webserversorgv1alpha1.WebAppSpec{
+					Name:                     "demo.war",
+					SourceRepositoryURL:      "https://github.com/jfclere/demo-webapp.git",
+					SourceRepositoryRef:      "main",
+					WebAppWarImage:           "quay.io/jfclere/demo:latest",
+					WebAppWarImagePushSecret: "push-secret",
+					Builder:                  &webserversorgv1alpha1.BuilderSpec{Image: "quay.io/jfclere/tomcat10-buildah"},
+				},
+				WebServerHealthCheck: &webserversorgv1alpha1.WebServerHealthCheckSpec{ServerReadinessScript: "true"},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.Distribution).To(Equal(webserversorgv1.DistributionTomcat))
+			Expect(spoke.Spec.Source.Image).To(Equal("quay.io/jfclere/tomcat10:latest"))
+			Expect(spoke.Spec.Source.Build.Repository.URL).To(Equal("https://github.com/jfclere/demo-webapp.git"))
+			Expect(spoke.Spec.Source.Build.Pod.WarName).To(Equal("demo.war"))
+			Expect(spoke.Spec.Source.Build.S2I).To(BeNil())
+			Expect(spoke.Spec.HealthCheck.ReadinessScript).To(Equal("true"))
+		})
+
+		It("Should convert an imagestream with an s2i build and keep the deprecated webhook secrets", func() {
+			hub.Spec.WebImageStream = &webserversorgv1alpha1.WebImageStreamSpec{
+				ImageStreamName:      "jboss-webserver",
+				ImageStreamNamespace: "openshift",
+				WebSources: &webserversorgv1alpha1.WebSourcesSpec{
+					SourceRepositoryURL:    "https://github.com/jboss-openshift/openshift-quickstarts.git",
+					SourceRepositorySecret: "git-secret",
+					ContextDir:             "tomcat-websocket-chat",
+					WebSourcesParams: &webserversorgv1alpha1.WebSourcesParamsSpec{
+						MavenMirrorURL:      "https://maven.example.com",
+						GithubWebhookSecret: "qwerty",
+					},
+					WebhookSecrets: &webserversorgv1alpha1.WebhookSecrets{Generic: "generic-secret"},
+				},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.Source.ImageStream.Name).To(Equal("jboss-webserver"))
+			Expect(spoke.Spec.Source.Build.S2I.RepositorySecret).To(Equal("git-secret"))
+			Expect(spoke.Spec.Source.Build.S2I.WebhookSecrets.Generic).To(Equal("generic-secret"))
+			Expect(spoke.Annotations).To(HaveKeyWithValue("web.servers.org/v1alpha1-github-webhook-secret", "qwerty"))
+		})
+
+		It("Should keep the imagePullSecret and the pod build of an imagestream", func() {
+			spoke.Spec = webserversorgv1.WebServerSpec{
+				ApplicationName: "example-app",
+				Replicas:        2,
+				Distribution:    webserversorgv1.DistributionJWS,
+				Source: webserversorgv1.SourceSpec{
+					ImageStream:     &webserversorgv1.ImageStreamSource{Name: "jboss-webserver", Namespace: "openshift"},
+					ImagePullSecret: "pull-secret",
+					Build: &webserversorgv1.BuildSpec{
+						Repository: webserversorgv1.RepositorySpec{URL: "https://github.com/jfclere/demo-webapp.git"},
+						Pod:        &webserversorgv1.PodBuildSpec{WarName: "demo.war", BuilderImage: "quay.io/jfclere/tomcat10-buildah"},
+					},
+				},
+			}
+			Expect(spoke.ConvertTo(hub)).To(Succeed())
+			Expect(hub.Annotations).To(HaveKeyWithValue("web.servers.org/v1-image-pull-secret", "pull-secret"))
+
+			converted := &webserversorgv1.WebServer{}
+			Expect(converted.ConvertFrom(hub)).To(Succeed())
+			Expect(converted.Spec).To(Equal(spoke.Spec))
+			Expect(converted.Annotations).To(BeEmpty())
+		})
+
+		It("Should convert the routeHostname to the exposure and the TLS settings", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			for routeHostname, expected := range map[string]webserversorgv1.RouteSpec{
+				"":                 {},
+				"NONE":             {Disabled: true},
+				"www.example.com":  {Host: "www.example.com"},
+				"tls":              {},
+				"tls:www.test.com": {Host: "www.test.com"},
+			} {
+				hub.Spec.TLSConfig.RouteHostname = routeHostname
+				Expect(roundTrip()).To(Equal(hub))
+				Expect(spoke.Spec.Exposure.Route).To(Equal(expected), routeHostname)
+				Expect(spoke.Spec.TLS.Enabled).To(Equal(routeHostname == "tls" || routeHostname == "tls:www.test.com"), routeHostname)
+			}
+		})
+
+		It("Should convert the rollout", func() {
+			stepSeconds := int32(30)
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Spec.Rollout = &webserversorgv1alpha1.RolloutSpec{
+				Strategy:    webserversorgv1alpha1.RolloutStrategyCanary,
+				Steps:       []int32{25, 50},
+				StepSeconds: &stepSeconds,
+			}
+			hub.Status.Rollout = &webserversorgv1alpha1.RolloutStatus{
+				Phase:       webserversorgv1alpha1.RolloutPhaseProgressing,
+				StableImage: "quay.io/jfclere/tomcat10:1.0",
+				CanaryImage: "quay.io/jfclere/tomcat10:latest",
+				Weight:      25,
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.Rollout.Steps).To(Equal([]int32{25, 50}))
+			Expect(spoke.Status.Rollout.Weight).To(Equal(int32(25)))
+		})
+
+		It("Should convert the Kubernetes exposure", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Spec.TLSConfig.RouteHostname = "tls"
+			hub.Spec.Exposure = &webserversorgv1alpha1.ExposureSpec{
+				Type:        webserversorgv1alpha1.ExposureGateway,
+				Host:        "www.example.com",
+				Annotations: map[string]string{"example.com/team": "web"},
+				Gateway:     &webserversorgv1alpha1.GatewayReference{Name: "gateway", Namespace: "infra", SectionName: "https"},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.Exposure.Kubernetes.Gateway.SectionName).To(Equal("https"))
+			Expect(spoke.Spec.TLS.Enabled).To(BeTrue())
+		})
+
+		It("Should convert the TLS termination of the route", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Spec.TLSConfig.RouteHostname = "tls:www.example.com"
+			hub.Spec.Route = &webserversorgv1alpha1.RouteSpec{
+				Termination:                   webserversorgv1alpha1.RouteTerminationReencrypt,
+				CertificateSecret:             "route-certificate",
+				DestinationCASecret:           "service-ca",
+				InsecureEdgeTerminationPolicy: "Redirect",
+				Annotations:                   map[string]string{"haproxy.router.openshift.io/timeout": "2m"},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.Exposure.Route.Host).To(Equal("www.example.com"))
+			Expect(spoke.Spec.Exposure.Route.Termination).To(Equal(webserversorgv1alpha1.RouteTerminationReencrypt))
+		})
+
+		It("Should convert the cert-manager certificate", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Spec.TLSConfig.RouteHostname = "tls"
+			hub.Spec.TLSConfig.CertManager = &webserversorgv1alpha1.CertManagerSpec{
+				IssuerRef:   webserversorgv1alpha1.IssuerReference{Name: "ca-issuer", Kind: "ClusterIssuer"},
+				DNSNames:    []string{"www.example.com"},
+				RenewBefore: &metav1.Duration{Duration: 240 * time.Hour},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Spec.TLS.CertManager.IssuerRef.Name).To(Equal("ca-issuer"))
+		})
+
+		It("Should convert the tuning of the connectors", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Spec.TLSConfig.RouteHostname = "tls"
+			maxThreads := int32(400)
+			hub.Spec.Connectors = &webserversorgv1alpha1.ConnectorsSpec{
+				HTTP: &webserversorgv1alpha1.ConnectorSpec{Compression: "on", HTTP2: true},
+				HTTPS: &webserversorgv1alpha1.HTTPSConnectorSpec{
+					ConnectorSpec: webserversorgv1alpha1.ConnectorSpec{Protocol: webserversorgv1alpha1.ConnectorProtocolNIO2, MaxThreads: &maxThreads},
+					Ciphers:       "HIGH:!aNULL:!MD5",
+					Protocols:     []string{"TLSv1.3"},
+				},
+				AJP: &webserversorgv1alpha1.AJPConnectorSpec{SecretName: "ajp-secret"},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(*spoke.Spec.Connectors.HTTPS.MaxThreads).To(Equal(int32(400)))
+		})
+
+		It("Should convert the status", func() {
+			hub.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			hub.Status = webserversorgv1alpha1.WebServerStatus{
+				Replicas: 1,
+				Pods:     []webserversorgv1alpha1.PodStatus{{Name: "example-0", PodIP: "10.0.0.1", State: webserversorgv1alpha1.PodStateActive}},
+				Hosts:    []string{"example.apps.example.com"},
+				LastReadyRevision: &webserversorgv1alpha1.Revision{
+					Image: "quay.io/jfclere/tomcat10:1.0",
+					Hash:  "AhashA",
+				},
+				Conditions: []metav1.Condition{{
+					Type:   webserversorgv1alpha1.ConditionAvailable,
+					Status: metav1.ConditionTrue,
+					Reason: "Ready",
+				}},
+			}
+
+			Expect(roundTrip()).To(Equal(hub))
+			Expect(spoke.Status.Pods).To(HaveLen(1))
+			Expect(spoke.Status.LastReadyRevision.Hash).To(Equal("AhashA"))
+		})
+	})
+
+	Context("When converting the exposure of a v1 WebServer", func() {
+		DescribeTable("Should keep the route and the TLS settings through v1alpha1",
+			func(route webserversorgv1.RouteSpec, tlsEnabled bool, routeHostname string) {
+				spoke.ObjectMeta = hub.ObjectMeta
+				spoke.Spec = webserversorgv1.WebServerSpec{
+					ApplicationName: "example-app",
+					Replicas:        2,
+					Distribution:    webserversorgv1.DistributionJWS,
+					Source:          webserversorgv1.SourceSpec{Image: "quay.io/jfclere/tomcat10:latest"},
+					Exposure:        webserversorgv1.ExposureSpec{Route: route},
+					TLS:             webserversorgv1.TLSSpec{Enabled: tlsEnabled},
+				}
+				Expect((&WebServerCustomValidator{}).ValidateCreate(context.TODO(), spoke)).Error().NotTo(HaveOccurred())
+
+				converted := &webserversorgv1alpha1.WebServer{}
+				Expect(spoke.ConvertTo(converted)).To(Succeed())
+				Expect(converted.Spec.TLSConfig.RouteHostname).To(Equal(routeHostname))
+				back := &webserversorgv1.WebServer{}
+				Expect(back.ConvertFrom(converted)).To(Succeed())
+				Expect(back.Spec).To(Equal(spoke.Spec))
+			},
+			Entry("with the generated hostname", webserversorgv1.RouteSpec{}, false, ""),
+			Entry("with a hostname", webserversorgv1.RouteSpec{Host: "www.example.com"}, false, "www.example.com"),
+			Entry("without route", webserversorgv1.RouteSpec{Disabled: true}, false, "NONE"),
+			Entry("with TLS", webserversorgv1.RouteSpec{}, true, "tls"),
+			Entry("with TLS and a hostname", webserversorgv1.RouteSpec{Host: "www.test.com"}, true, "tls:www.test.com"),
+		)
+
+		It("Should deny TLS without route", func() {
+			spoke.Spec.TLS.Enabled = true
+			spoke.Spec.Exposure.Route.Disabled = true
+			Expect((&WebServerCustomValidator{}).ValidateCreate(context.TODO(), spoke)).Error().To(HaveOccurred())
+			Expect((&WebServerCustomValidator{}).ValidateUpdate(context.TODO(), &webserversorgv1.WebServer{}, spoke)).Error().To(HaveOccurred())
+		})
+	})
+
+})
@@ -0,0 +1,618 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"strings"
+
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/serverxml"
+)
+
+// +kubebuilder:rbac:groups="core",resources=secrets,verbs=get
+
+// validateWebServer gathers all the problems of the WebServer in a single Invalid error,
+// the problems which don't prevent the WebServer from being deployed are returned as warnings.
+// oldWebServer is nil on creation.
+func (v *WebServerCustomValidator) validateWebServer(ctx context.Context, oldWebServer, webserver *webserversv1alpha1.WebServer) (admission.Warnings, error) {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+
+	errs = append(errs, v.validateImageSource(webserver, specPath)...)
+	errs = append(errs, validateWebApp(webserver, specPath.Child("webImage", "webApp"))...)
+	errs = append(errs, validateTLSConfig(webserver, specPath.Child("tlsConfig"))...)
+	errs = append(errs, validateVolumes(webserver, specPath.Child("volumeSpec"))...)
+	errs = append(errs, validateUpdateStrategy(webserver, specPath.Child("updateStrategy"))...)
+	errs = append(errs, validateRollout(webserver, specPath.Child("rollout"))...)
+	errs = append(errs, validateExposure(webserver, specPath.Child("exposure"))...)
+	errs = append(errs, validateRoute(webserver, specPath.Child("route"))...)
+	errs = append(errs, validateTomcatConfig(webserver, specPath.Child("tomcatConfig"))...)
+	errs = append(errs, validateConnectors(webserver, specPath.Child("connectors"))...)
+	errs = append(errs, validateSessionClustering(webserver, specPath.Child("sessionClustering"))...)
+	errs = append(errs, validateSessionStore(webserver, specPath.Child("sessionStore"))...)
+	if oldWebServer != nil {
+		errs = append(errs, validateImmutableFields(oldWebServer, webserver, specPath)...)
+	}
+	if err := v.checkApplicationName(ctx, webserver, specPath.Child("applicationName")); err != nil {
+		errs = append(errs, err)
+	}
+
+	warnings := v.checkReferences(ctx, webserver, specPath)
+	if webserver.Spec.Exposure != nil && v.isOpenShift {
+		warnings = append(warnings, specPath.Child("exposure").String()+" is ignored on OpenShift, the application is exposed by a Route")
+	}
+	if webserver.Spec.Route != nil && !v.isOpenShift {
+		warnings = append(warnings, specPath.Child("route").String()+" is ignored outside of OpenShift")
+	}
+	if webserver.Spec.SessionStore != nil && webserver.Spec.UseSessionClustering {
+		warnings = append(warnings, specPath.Child("useSessionClustering").String()+" is ignored, the sessions are kept in "+specPath.Child("sessionStore").String())
+	}
+
+	if len(errs) > 0 {
+		return warnings, apierrors.NewInvalid(webserversv1alpha1.GroupVersion.WithKind("WebServer").GroupKind(), webserver.Name, errs)
+	}
+	return warnings, nil
+}
+
+// validateImageSource makes sure exactly one of webImage and webImageStream is set and
+// that image streams are only used on OpenShift.
+func (v *WebServerCustomValidator) validateImageSource(webserver *webserversv1alpha1.WebServer, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if webserver.Spec.WebImage != nil && webserver.Spec.WebImageStream != nil {
+		errs = append(errs, field.Forbidden(specPath.Child("webImageStream"), "both webImage and webImageStream are set, only one can be used"))
+	}
+	if webserver.Spec.WebImage == nil && webserver.Spec.WebImageStream == nil {
+		errs = append(errs, field.Required(specPath.Child("webImage"), "webImage or webImageStream is required"))
+	}
+	if webserver.Spec.WebImageStream != nil && !v.isOpenShift {
+		errs = append(errs, field.Forbidden(specPath.Child("webImageStream"), "webImageStream can only be used in an OpenShift cluster"))
+	}
+	return errs
+}
+
+// validateWebApp checks the fields needed to build the web application.
+func validateWebApp(webserver *webserversv1alpha1.WebServer, webAppPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if webserver.Spec.WebImage == nil || webserver.Spec.WebImage.WebApp == nil {
+		return errs
+	}
+	webApp := webserver.Spec.WebImage.WebApp
+	if webApp.SourceRepositoryURL == "" {
+		errs = append(errs, field.Required(webAppPath.Child("sourceRepositoryURL"), "the sources of the web application are needed to build it"))
+	}
+	if webApp.WebAppWarImage == "" {
+		errs = append(errs, field.Required(webAppPath.Child("webAppWarImage"), "the image to push the built application to is needed"))
+	}
+	if webApp.WebAppWarImagePushSecret == "" {
+		errs = append(errs, field.Required(webAppPath.Child("webAppWarImagePushSecret"), "the secret to push the built image is needed"))
+	}
+	switch {
+	case webApp.Builder == nil:
+		errs = append(errs, field.Required(webAppPath.Child("builder"), "the builder is needed to build the web application"))
+	case webApp.Builder.Strategy == webserversv1alpha1.BuildStrategyShipwright:
+		if webApp.Builder.Shipwright == nil {
+			errs = append(errs, field.Required(webAppPath.Child("builder", "shipwright"), "the Shipwright build strategy is needed to build the web application with Shipwright"))
+		}
+	case webApp.Builder.Image == "":
+		errs = append(errs, field.Required(webAppPath.Child("builder", "image"), "the image of the builder is needed to build the web application"))
+	}
+	return errs
+}
+
+// validateTLSConfig checks the routeHostname ([tls[:hostname]], NONE or a hostname) and the certificateVerification.
+func validateTLSConfig(webserver *webserversv1alpha1.WebServer, tlsPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	routeHostname := webserver.Spec.TLSConfig.RouteHostname
+	hostnamePath := tlsPath.Child("routeHostname")
+	switch {
+	case routeHostname == "" || routeHostname == "NONE" || routeHostname == "tls":
+	case strings.HasPrefix(routeHostname, "tls:"):
+		for _, msg := range validation.IsDNS1123Subdomain(routeHostname[4:]) {
+			errs = append(errs, field.Invalid(hostnamePath, routeHostname, msg))
+		}
+	case strings.HasPrefix(routeHostname, "tls"):
+		errs = append(errs, field.Invalid(hostnamePath, routeHostname, "must be tls, tls:hostname, NONE or a hostname not starting with tls"))
+	default:
+		for _, msg := range validation.IsDNS1123Subdomain(routeHostname) {
+			errs = append(errs, field.Invalid(hostnamePath, routeHostname, msg))
+		}
+	}
+
+	switch webserver.Spec.TLSConfig.CertificateVerification {
+	case "", "required", "optional":
+	default:
+		errs = append(errs, field.NotSupported(tlsPath.Child("certificateVerification"), webserver.Spec.TLSConfig.CertificateVerification, []string{"required", "optional"}))
+	}
+
+	if webserver.Spec.TLSConfig.CertificateReload == webserversv1alpha1.CertificateReloadJolokia && !strings.HasPrefix(routeHostname, "tls") {
+		errs = append(errs, field.Forbidden(tlsPath.Child("certificateReload"), "tomcat only serves the certificates with routeHostname tls"))
+	}
+
+	if jolokia := webserver.Spec.TLSConfig.Jolokia; jolokia != nil && jolokia.CredentialsSecret != "" {
+		if jolokia.Protocol == webserversv1alpha1.JolokiaProtocolHTTP || jolokia.CASecret == "" {
+			errs = append(errs, field.Forbidden(tlsPath.Child("jolokia", "credentialsSecret"), "the credentials are only sent over HTTPS to an agent verified with the CA of caSecret"))
+		}
+	}
+
+	if certManager := webserver.Spec.TLSConfig.CertManager; certManager != nil {
+		certManagerPath := tlsPath.Child("certManager")
+		if certManager.IssuerRef.Name == "" {
+			errs = append(errs, field.Required(certManagerPath.Child("issuerRef", "name"), "the issuer of the certificate is required"))
+		}
+		for i, name := range certManager.DNSNames {
+			for _, msg := range validation.IsDNS1123Subdomain(strings.TrimPrefix(name, "*.")) {
+				errs = append(errs, field.Invalid(certManagerPath.Child("dnsNames").Index(i), name, msg))
+			}
+		}
+		if webserver.Spec.TLSConfig.TLSSecret == "" {
+			for _, msg := range validation.IsDNS1123Subdomain(webserver.Spec.ApplicationName + "-tls") {
+				errs = append(errs, field.Invalid(tlsPath.Child("tlsSecret"), webserver.Spec.ApplicationName+"-tls", "invalid name for the Secret of the certificate: "+msg))
+			}
+		}
+	}
+	return errs
+}
+
+// validateVolumes checks the names of the volumes generated for the pods from the volumeSpec:
+// they must be unique and valid volume names.
+func validateVolumes(webserver *webserversv1alpha1.WebServer, volumePath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if webserver.Spec.Volume == nil {
+		return errs
+	}
+	check := func(path *field.Path, prefix string, names []string) {
+		seen := map[string]bool{}
+		for i, name := range names {
+			if seen[name] {
+				errs = append(errs, field.Duplicate(path.Index(i), name))
+				continue
+			}
+			seen[name] = true
+			for _, msg := range validation.IsDNS1123Label(prefix + name) {
+				errs = append(errs, field.Invalid(path.Index(i), name, "invalid volume name "+prefix+name+": "+msg))
+			}
+		}
+	}
+	check(volumePath.Child("persistentVolumeClaims"), "persistent-vol-", webserver.Spec.Volume.PersistentVolumeClaims)
+	check(volumePath.Child("secrets"), "secret-vol-", webserver.Spec.Volume.Secrets)
+	check(volumePath.Child("configMaps"), "configmap-vol-", webserver.Spec.Volume.ConfigMaps)
+	return errs
+}
+
+// validateUpdateStrategy checks the settings of the rolling updates against the workload used:
+// the Deployment takes maxSurge, maxUnavailable and progressDeadlineSeconds, the StatefulSet the partition.
+func validateUpdateStrategy(webserver *webserversv1alpha1.WebServer, strategyPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	updateStrategy := webserver.Spec.UpdateStrategy
+	if updateStrategy == nil {
+		return errs
+	}
+	if updateStrategy.RollbackDeadlineSeconds != nil && webserver.Spec.Rollout != nil {
+		errs = append(errs, field.Forbidden(strategyPath.Child("rollbackDeadlineSeconds"), "a rollout is aborted after rollout.progressDeadlineSeconds instead"))
+	}
+	statefulSet := webserver.Spec.Volume != nil && len(webserver.Spec.Volume.VolumeClaimTemplates) > 0
+	rollingUpdate := updateStrategy.Type == webserversv1alpha1.UpdateStrategyRollingUpdate
+	if statefulSet {
+		if updateStrategy.Type == webserversv1alpha1.UpdateStrategyRecreate {
+			errs = append(errs, field.Forbidden(strategyPath.Child("type"), "the StatefulSet used with volumeClaimTemplates is always updated by a rolling update"))
+		}
+		if updateStrategy.MaxSurge != nil {
+			errs = append(errs, field.Forbidden(strategyPath.Child("maxSurge"), "maxSurge only applies to a Deployment"))
+		}
+		if updateStrategy.MaxUnavailable != nil {
+			errs = append(errs, field.Forbidden(strategyPath.Child("maxUnavailable"), "maxUnavailable only applies to a Deployment"))
+		}
+		if updateStrategy.ProgressDeadlineSeconds != nil {
+			errs = append(errs, field.Forbidden(strategyPath.Child("progressDeadlineSeconds"), "progressDeadlineSeconds only applies to a Deployment"))
+		}
+		return errs
+	}
+
+	if updateStrategy.Partition != nil {
+		errs = append(errs, field.Forbidden(strategyPath.Child("partition"), "partition only applies to the StatefulSet used with volumeClaimTemplates"))
+	}
+	if !rollingUpdate {
+		if updateStrategy.MaxSurge != nil {
+			errs = append(errs, field.Forbidden(strategyPath.Child("maxSurge"), "maxSurge requires the RollingUpdate type"))
+		}
+		if updateStrategy.MaxUnavailable != nil {
+			errs = append(errs, field.Forbidden(strategyPath.Child("maxUnavailable"), "maxUnavailable requires the RollingUpdate type"))
+		}
+	}
+	for _, value := range []struct {
+		name  string
+		value *intstr.IntOrString
+	}{{"maxSurge", updateStrategy.MaxSurge}, {"maxUnavailable", updateStrategy.MaxUnavailable}} {
+		if value.value == nil {
+			continue
+		}
+		if scaled, err := intstr.GetScaledValueFromIntOrPercent(value.value, 100, true); err != nil || scaled < 0 {
+			errs = append(errs, field.Invalid(strategyPath.Child(value.name), value.value.String(), "must be a positive number or a percentage"))
+		}
+	}
+	if rollingUpdate && isZero(updateStrategy.MaxSurge) && isZero(updateStrategy.MaxUnavailable) {
+		errs = append(errs, field.Invalid(strategyPath.Child("maxUnavailable"), updateStrategy.MaxUnavailable.String(), "may not be 0 when maxSurge is 0"))
+	}
+	if updateStrategy.ProgressDeadlineSeconds != nil && *updateStrategy.ProgressDeadlineSeconds <= updateStrategy.MinReadySeconds {
+		errs = append(errs, field.Invalid(strategyPath.Child("progressDeadlineSeconds"), *updateStrategy.ProgressDeadlineSeconds, "must be greater than minReadySeconds"))
+	}
+	return errs
+}
+
+// validateRollout makes sure the rollout applies to an image deployed by a Deployment and checks the canary steps.
+func validateRollout(webserver *webserversv1alpha1.WebServer, rolloutPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	rollout := webserver.Spec.Rollout
+	if rollout == nil {
+		return errs
+	}
+	if webserver.Spec.WebImage == nil {
+		errs = append(errs, field.Forbidden(rolloutPath, "only the webImage.applicationImage can be rolled out"))
+	} else if webserver.Spec.WebImage.WebApp != nil {
+		errs = append(errs, field.Forbidden(rolloutPath, "the image built from webImage.webApp can't be rolled out"))
+	}
+	if webserver.Spec.Volume != nil && len(webserver.Spec.Volume.VolumeClaimTemplates) > 0 {
+		errs = append(errs, field.Forbidden(rolloutPath, "the StatefulSet used with volumeClaimTemplates can't be rolled out"))
+	}
+	for _, msg := range validation.IsDNS1035Label(webserver.Spec.ApplicationName + "-canary") {
+		errs = append(errs, field.Invalid(rolloutPath, webserver.Spec.ApplicationName+"-canary", "invalid name for the canary Deployment and Service: "+msg))
+	}
+	if rollout.StepSeconds != nil && rollout.ProgressDeadlineSeconds != nil && *rollout.ProgressDeadlineSeconds <= *rollout.StepSeconds {
+		errs = append(errs, field.Invalid(rolloutPath.Child("progressDeadlineSeconds"), *rollout.ProgressDeadlineSeconds, "must be greater than stepSeconds"))
+	}
+	if rollout.Strategy != webserversv1alpha1.RolloutStrategyCanary {
+		if len(rollout.Steps) > 0 {
+			errs = append(errs, field.Forbidden(rolloutPath.Child("steps"), "steps only apply to the Canary strategy"))
+		}
+		return errs
+	}
+	for i := 1; i < len(rollout.Steps); i++ {
+		if rollout.Steps[i] <= rollout.Steps[i-1] {
+			errs = append(errs, field.Invalid(rolloutPath.Child("steps").Index(i), rollout.Steps[i], "the steps must increase the traffic sent to the new image"))
+		}
+	}
+	return errs
+}
+
+// validateExposure checks that the fields of the exposure apply to its type and that the host is a valid hostname.
+func validateExposure(webserver *webserversv1alpha1.WebServer, exposurePath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	exposure := webserver.Spec.Exposure
+	if exposure == nil {
+		return errs
+	}
+	exposureType := exposure.Type
+	if exposureType == "" {
+		exposureType = webserversv1alpha1.ExposureLoadBalancer
+	}
+	if exposureType == webserversv1alpha1.ExposureGateway && exposure.Gateway == nil {
+		errs = append(errs, field.Required(exposurePath.Child("gateway"), "the Gateway of the routes is required"))
+	}
+	if exposureType != webserversv1alpha1.ExposureGateway && exposure.Gateway != nil {
+		errs = append(errs, field.Forbidden(exposurePath.Child("gateway"), "gateway only applies to the Gateway type"))
+	}
+	if exposureType != webserversv1alpha1.ExposureNodePort && exposure.NodePort != 0 {
+		errs = append(errs, field.Forbidden(exposurePath.Child("nodePort"), "nodePort only applies to the NodePort type"))
+	}
+	if exposureType != webserversv1alpha1.ExposureIngress && exposure.IngressClassName != nil {
+		errs = append(errs, field.Forbidden(exposurePath.Child("ingressClassName"), "ingressClassName only applies to the Ingress type"))
+	}
+	if exposure.Host != "" {
+		if exposureType != webserversv1alpha1.ExposureIngress && exposureType != webserversv1alpha1.ExposureGateway {
+			errs = append(errs, field.Forbidden(exposurePath.Child("host"), "host only applies to the Ingress and Gateway types"))
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimPrefix(exposure.Host, "*.")) {
+			errs = append(errs, field.Invalid(exposurePath.Child("host"), exposure.Host, msg))
+		}
+	}
+	return errs
+}
+
+// validateRoute checks the TLS termination of the Route against the TLS configuration of tomcat: the router
+// sends HTTP to tomcat with Edge and TLS with Reencrypt.
+func validateRoute(webserver *webserversv1alpha1.WebServer, routePath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	route := webserver.Spec.Route
+	if route == nil {
+		return errs
+	}
+	routeHostname := webserver.Spec.TLSConfig.RouteHostname
+	if routeHostname == "NONE" {
+		return append(errs, field.Forbidden(routePath, "the Route is disabled by tlsConfig.routeHostname NONE"))
+	}
+	tomcatTLS := strings.HasPrefix(routeHostname, "tls")
+	switch route.Termination {
+	case webserversv1alpha1.RouteTerminationEdge:
+		if tomcatTLS {
+			errs = append(errs, field.Invalid(routePath.Child("termination"), route.Termination, "tomcat serves HTTPS with tlsConfig.routeHostname tls, use Reencrypt"))
+		}
+	case webserversv1alpha1.RouteTerminationReencrypt:
+		if !tomcatTLS {
+			errs = append(errs, field.Invalid(routePath.Child("termination"), route.Termination, "Reencrypt requires tomcat to serve HTTPS with tlsConfig.routeHostname tls"))
+		}
+	default:
+		if route.CertificateSecret != "" {
+			errs = append(errs, field.Forbidden(routePath.Child("certificateSecret"), "the certificate only applies to the Edge and Reencrypt terminations"))
+		}
+	}
+	if route.DestinationCASecret != "" && route.Termination != webserversv1alpha1.RouteTerminationReencrypt {
+		errs = append(errs, field.Forbidden(routePath.Child("destinationCASecret"), "the destination CA only applies to the Reencrypt termination"))
+	}
+	switch {
+	case route.InsecureEdgeTerminationPolicy == "":
+	case route.Termination == "" && !tomcatTLS:
+		errs = append(errs, field.Forbidden(routePath.Child("insecureEdgeTerminationPolicy"), "the Route has no TLS"))
+	case route.Termination == "" && route.InsecureEdgeTerminationPolicy == string(routev1.InsecureEdgeTerminationPolicyAllow):
+		errs = append(errs, field.NotSupported(routePath.Child("insecureEdgeTerminationPolicy"), route.InsecureEdgeTerminationPolicy, []string{"Redirect", "None"}))
+	}
+	return errs
+}
+
+// tomcatConfigFiles returns the configuration files of tomcatConfig by file name, with their field names.
+func tomcatConfigFiles(tomcatConfig *webserversv1alpha1.TomcatConfigSpec) ([]string, []string, []*webserversv1alpha1.TomcatConfigFile) {
+	files := []string{serverxml.ServerXml, serverxml.ContextXml, serverxml.WebXml, serverxml.TomcatUsersXml}
+	fields := []string{"serverXml", "contextXml", "webXml", "tomcatUsersXml"}
+	specs := []*webserversv1alpha1.TomcatConfigFile{tomcatConfig.ServerXml, tomcatConfig.ContextXml, tomcatConfig.WebXml, tomcatConfig.TomcatUsersXml}
+	return files, fields, specs
+}
+
+// validateTomcatConfig checks the references to the ConfigMaps of the configuration files, web.xml can't be built
+// from fragments: its servlets and MIME mappings depend on the version of tomcat.
+func validateTomcatConfig(webserver *webserversv1alpha1.WebServer, tomcatConfigPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if webserver.Spec.TomcatConfig == nil {
+		return errs
+	}
+	_, fields, specs := tomcatConfigFiles(webserver.Spec.TomcatConfig)
+	for i, spec := range specs {
+		if spec == nil {
+			continue
+		}
+		filePath := tomcatConfigPath.Child(fields[i])
+		for _, msg := range validation.IsDNS1123Subdomain(spec.ConfigMap) {
+			errs = append(errs, field.Invalid(filePath.Child("configMap"), spec.ConfigMap, msg))
+		}
+		if spec.Key != "" {
+			for _, msg := range validation.IsConfigMapKey(spec.Key) {
+				errs = append(errs, field.Invalid(filePath.Child("key"), spec.Key, msg))
+			}
+		}
+		if fields[i] == "webXml" && spec.Mode == webserversv1alpha1.TomcatConfigFragment {
+			errs = append(errs, field.NotSupported(filePath.Child("mode"), spec.Mode, []string{webserversv1alpha1.TomcatConfigReplace}))
+		}
+	}
+	return errs
+}
+
+// validateConnectors checks the HTTPS connector is served by tomcat and the Secret of the AJP connector.
+func validateConnectors(webserver *webserversv1alpha1.WebServer, connectorsPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	connectors := webserver.Spec.Connectors
+	if connectors == nil {
+		return errs
+	}
+	if connectors.HTTPS != nil && !strings.HasPrefix(webserver.Spec.TLSConfig.RouteHostname, "tls") {
+		errs = append(errs, field.Forbidden(connectorsPath.Child("https"), "tomcat only serves HTTPS with tlsConfig.routeHostname tls"))
+	}
+	if connectors.AJP != nil {
+		for _, msg := range validation.IsDNS1123Subdomain(connectors.AJP.SecretName) {
+			errs = append(errs, field.Invalid(connectorsPath.Child("ajp", "secretName"), connectors.AJP.SecretName, msg))
+		}
+	}
+	return errs
+}
+
+// validateSessionClustering checks the options of the session clustering against its manager: the Persistent
+// manager saves the sessions in a directory and doesn't replicate them.
+func validateSessionClustering(webserver *webserversv1alpha1.WebServer, clusteringPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	clustering := webserver.Spec.SessionClustering
+	if clustering == nil {
+		return errs
+	}
+	if !webserver.Spec.UseSessionClustering {
+		errs = append(errs, field.Forbidden(clusteringPath, "the session clustering is enabled by useSessionClustering"))
+	}
+	directoryPath := clusteringPath.Child("persistentDirectory")
+	if clustering.Manager != webserversv1alpha1.SessionManagerPersistent {
+		if clustering.PersistentDirectory != "" {
+			errs = append(errs, field.Forbidden(directoryPath, "the directory only applies to the Persistent manager"))
+		}
+		for i, host := range clustering.StaticMembers {
+			if net.ParseIP(host) != nil {
+				continue
+			}
+			for _, msg := range validation.IsDNS1123Subdomain(host) {
+				errs = append(errs, field.Invalid(clusteringPath.Child("staticMembers").Index(i), host, msg))
+			}
+		}
+		return errs
+	}
+
+	switch {
+	case clustering.PersistentDirectory == "":
+		errs = append(errs, field.Required(directoryPath, "the Persistent manager saves the sessions in a directory"))
+	case !path.IsAbs(clustering.PersistentDirectory):
+		errs = append(errs, field.Invalid(directoryPath, clustering.PersistentDirectory, "must be an absolute path"))
+	}
+	const notReplicated = "the Persistent manager doesn't replicate the sessions"
+	if clustering.MembershipProvider != "" {
+		errs = append(errs, field.Forbidden(clusteringPath.Child("membershipProvider"), notReplicated))
+	}
+	if clustering.ReplicationPort != nil {
+		errs = append(errs, field.Forbidden(clusteringPath.Child("replicationPort"), notReplicated))
+	}
+	if clustering.SendOptions != "" {
+		errs = append(errs, field.Forbidden(clusteringPath.Child("sendOptions"), notReplicated))
+	}
+	if len(clustering.StaticMembers) > 0 {
+		errs = append(errs, field.Forbidden(clusteringPath.Child("staticMembers"), notReplicated))
+	}
+	return errs
+}
+
+// validateSessionStore checks the Secret of the session store and that the JDBC driver is only set for JDBC.
+func validateSessionStore(webserver *webserversv1alpha1.WebServer, storePath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	store := webserver.Spec.SessionStore
+	if store == nil {
+		return errs
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(store.SecretName) {
+		errs = append(errs, field.Invalid(storePath.Child("secretName"), store.SecretName, msg))
+	}
+	if store.DriverName != "" && store.Type != webserversv1alpha1.SessionStoreJDBC {
+		errs = append(errs, field.Forbidden(storePath.Child("driverName"), "the driver only applies to the JDBC session store"))
+	}
+	return errs
+}
+
+// isZero returns true for 0 and 0%, the Deployment defaults (25%) are used when the value is not set.
+func isZero(value *intstr.IntOrString) bool {
+	if value == nil {
+		return false
+	}
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(value, 100, true)
+	return err == nil && scaled == 0
+}
+
+// validateImmutableFields rejects the changes the operator can't apply to the existing resources:
+// the volumeClaimTemplates of a StatefulSet can't be changed (switching between Deployment and StatefulSet is fine).
+func validateImmutableFields(oldWebServer, webserver *webserversv1alpha1.WebServer, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	var oldTemplates, newTemplates []corev1.PersistentVolumeClaimSpec
+	if oldWebServer.Spec.Volume != nil {
+		oldTemplates = oldWebServer.Spec.Volume.VolumeClaimTemplates
+	}
+	if webserver.Spec.Volume != nil {
+		newTemplates = webserver.Spec.Volume.VolumeClaimTemplates
+	}
+	if len(oldTemplates) > 0 && len(newTemplates) > 0 && !reflect.DeepEqual(oldTemplates, newTemplates) {
+		errs = append(errs, field.Forbidden(specPath.Child("volumeSpec", "volumeClaimTemplates"), "the volumeClaimTemplates of the StatefulSet can't be changed"))
+	}
+	return errs
+}
+
+// checkReferences warns about the Secrets, ConfigMaps and PersistentVolumeClaims referenced by the WebServer
+// which don't exist (yet), the pods won't start until they are created. The data of the Secrets isn't read.
+func (v *WebServerCustomValidator) checkReferences(ctx context.Context, webserver *webserversv1alpha1.WebServer, specPath *field.Path) admission.Warnings {
+	var warnings admission.Warnings
+	if v.APIReader == nil {
+		return warnings
+	}
+	check := func(path *field.Path, kind string, obj client.Object, name string) {
+		err := v.APIReader.Get(ctx, client.ObjectKey{Namespace: webserver.Namespace, Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s %s not found in namespace %s", path.String(), kind, name, webserver.Namespace))
+		} else if err != nil {
+			webserverlog.Info("Unable to check "+kind+" "+name, "error", err.Error())
+		}
+	}
+	if webserver.Spec.Volume != nil {
+		volumePath := specPath.Child("volumeSpec")
+		for i, name := range webserver.Spec.Volume.PersistentVolumeClaims {
+			check(volumePath.Child("persistentVolumeClaims").Index(i), "PersistentVolumeClaim", &corev1.PersistentVolumeClaim{}, name)
+		}
+		for i, name := range webserver.Spec.Volume.Secrets {
+			check(volumePath.Child("secrets").Index(i), "Secret", secretMetadata(), name)
+		}
+		for i, name := range webserver.Spec.Volume.ConfigMaps {
+			check(volumePath.Child("configMaps").Index(i), "ConfigMap", &corev1.ConfigMap{}, name)
+		}
+	}
+	if webserver.Spec.TLSConfig.TLSSecret != "" && webserver.Spec.TLSConfig.CertManager == nil {
+		// The Secret of cert-manager is created once the certificate is issued
+		check(specPath.Child("tlsConfig", "tlsSecret"), "Secret", secretMetadata(), webserver.Spec.TLSConfig.TLSSecret)
+	}
+	if tomcatConfig := webserver.Spec.TomcatConfig; tomcatConfig != nil {
+		files, fields, specs := tomcatConfigFiles(tomcatConfig)
+		for i, spec := range specs {
+			if spec == nil {
+				continue
+			}
+			configMap := &corev1.ConfigMap{}
+			filePath := specPath.Child("tomcatConfig", fields[i])
+			check(filePath.Child("configMap"), "ConfigMap", configMap, spec.ConfigMap)
+			if configMap.Name != "" {
+				warnings = append(warnings, checkTomcatConfigFile(filePath, configMap, files[i], spec)...)
+			}
+		}
+	}
+	if connectors := webserver.Spec.Connectors; connectors != nil && connectors.AJP != nil {
+		check(specPath.Child("connectors", "ajp", "secretName"), "Secret", secretMetadata(), connectors.AJP.SecretName)
+	}
+	if store := webserver.Spec.SessionStore; store != nil {
+		check(specPath.Child("sessionStore", "secretName"), "Secret", secretMetadata(), store.SecretName)
+	}
+	if route := webserver.Spec.Route; route != nil {
+		if route.CertificateSecret != "" {
+			check(specPath.Child("route", "certificateSecret"), "Secret", secretMetadata(), route.CertificateSecret)
+		}
+		if route.DestinationCASecret != "" {
+			check(specPath.Child("route", "destinationCASecret"), "Secret", secretMetadata(), route.DestinationCASecret)
+		}
+	}
+	return warnings
+}
+
+// secretMetadata returns the object to check that a Secret exists, only its metadata is read: a key missing from the
+// Secret shows up as the CreateContainerConfigError of the pods.
+func secretMetadata() *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}}
+}
+
+// checkTomcatConfigFile warns about a configuration file missing from its ConfigMap or not well-formed, the
+// operator reports it again when the ConfigMap is changed after the WebServer.
+func checkTomcatConfigFile(filePath *field.Path, configMap *corev1.ConfigMap, file string, spec *webserversv1alpha1.TomcatConfigFile) admission.Warnings {
+	key := spec.Key
+	if key == "" {
+		key = file
+	}
+	data, ok := configMap.Data[key]
+	if !ok {
+		binaryData, ok := configMap.BinaryData[key]
+		if !ok {
+			return admission.Warnings{fmt.Sprintf("%s: key %s not found in ConfigMap %s", filePath.String(), key, configMap.Name)}
+		}
+		data = string(binaryData)
+	}
+	var err error
+	if spec.Mode == webserversv1alpha1.TomcatConfigFragment {
+		_, err = serverxml.ParseFragment([]byte(data))
+	} else {
+		_, err = serverxml.Parse([]byte(data))
+	}
+	if err != nil {
+		return admission.Warnings{fmt.Sprintf("%s: %s/%s is not well-formed: %s", filePath.String(), configMap.Name, key, err.Error())}
+	}
+	return nil
+}
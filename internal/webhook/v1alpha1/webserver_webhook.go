@@ -18,30 +18,76 @@ package v1alpha1
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"sync"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	webserversv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
+	"github.com/web-servers/jws-operator/internal/platform"
 )
 
 // nolint:unused
 // log is for logging in this package.
 var webserverlog = logf.Log.WithName("webserver-resource")
 
+// applicationNameIndex indexes the WebServers by spec.applicationName
+const applicationNameIndex = "spec.applicationName"
+
 // SetupWebServerWebhookWithManager registers the webhook for WebServer in the manager.
 func SetupWebServerWebhookWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &webserversv1alpha1.WebServer{}, applicationNameIndex, applicationNameIndexer)
+	if err != nil {
+		return err
+	}
 	return ctrl.NewWebhookManagedBy(mgr).For(&webserversv1alpha1.WebServer{}).
-		WithValidator(&WebServerCustomValidator{}).
+		WithValidator(&WebServerCustomValidator{
+			Client:      mgr.GetClient(),
+			APIReader:   mgr.GetAPIReader(),
+			isOpenShift: platform.IsOpenShift(mgr.GetConfig()),
+		}).
+		WithDefaulter(&WebServerCustomDefaulter{}).
 		Complete()
 }
 
+// applicationNameIndexer extracts the applicationName of a WebServer for the field index.
+func applicationNameIndexer(obj client.Object) []string {
+	webserver, ok := obj.(*webserversv1alpha1.WebServer)
+	if !ok || webserver.Spec.ApplicationName == "" {
+		return nil
+	}
+	return []string{webserver.Spec.ApplicationName}
+}
+
+// +kubebuilder:webhook:path=/mutate-web-servers-org-v1alpha1-webserver,mutating=true,failurePolicy=fail,sideEffects=None,groups=web.servers.org,resources=webservers,verbs=create;update,versions=v1alpha1,name=mwebserver-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// WebServerCustomDefaulter struct is responsible for setting default values on the WebServer resource
+// when it is created or updated, so the stored WebServer shows what the operator deploys.
+//
+// NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
+// as this struct is used only for temporary operations and does not need to be deeply copied.
+type WebServerCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &WebServerCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type WebServer.
+func (d *WebServerCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	webserver, ok := obj.(*webserversv1alpha1.WebServer)
+	if !ok {
+		return fmt.Errorf("expected a WebServer object but got %T", obj)
+	}
+	webserverlog.Info("Defaulting for WebServer", "name", webserver.GetName())
+
+	webserversv1alpha1.SetDefaults(webserver)
+	return nil
+}
+
 // TODO(user): EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -55,26 +101,18 @@ func SetupWebServerWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type WebServerCustomValidator struct {
-	// TODO(user): Add more fields as needed for validation
-}
-
-type AppRegistry struct {
-	mu      sync.RWMutex
-	allApps map[string][]WebApp
-}
-
-type WebApp struct {
-	WebServerName string
-	AppName       string
+	// Client reads the WebServers from the cache of the manager, it needs the spec.applicationName index
+	Client client.Reader
+	// APIReader reads the objects referenced by the WebServer directly from the API server (they are not cached)
+	APIReader client.Reader
+	// isOpenShift is true when the route.openshift.io API group is served by the cluster
+	isOpenShift bool
 }
 
 var _ webhook.CustomValidator = &WebServerCustomValidator{}
-var registry = AppRegistry{
-	allApps: make(map[string][]WebApp),
-}
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type WebServer.
-func (v *WebServerCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *WebServerCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	webserver, ok := obj.(*webserversv1alpha1.WebServer)
 	if !ok {
 		return nil, fmt.Errorf("expected a WebServer object but got %T", obj)
@@ -82,19 +120,29 @@ func (v *WebServerCustomValidator) ValidateCreate(_ context.Context, obj runtime
 	webserverlog.Info("Validation for WebServer upon creation", "name", webserver.GetName())
 
 	printWebServer(webserver)
-	return checkApplicationName(webserver)
+	return v.validateWebServer(ctx, nil, webserver)
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type WebServer.
-func (v *WebServerCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+func (v *WebServerCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
 	webserver, ok := newObj.(*webserversv1alpha1.WebServer)
 	if !ok {
 		return nil, fmt.Errorf("expected a WebServer object for the newObj but got %T", newObj)
 	}
+	oldWebserver, ok := oldObj.(*webserversv1alpha1.WebServer)
+	if !ok {
+		return nil, fmt.Errorf("expected a WebServer object for the oldObj but got %T", oldObj)
+	}
 	webserverlog.Info("Validation for WebServer upon update", "name", webserver.GetName())
 
+	// The updates of the metadata, like the finalizer added and removed by the operator, and the updates of a
+	// WebServer being deleted must not be blocked by a spec the rules reject since it was admitted
+	if webserver.DeletionTimestamp != nil || equality.Semantic.DeepEqual(oldWebserver.Spec, webserver.Spec) {
+		return nil, nil
+	}
+
 	printWebServer(webserver)
-	return checkApplicationName(webserver)
+	return v.validateWebServer(ctx, oldWebserver, webserver)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type WebServer.
@@ -105,15 +153,6 @@ func (v *WebServerCustomValidator) ValidateDelete(ctx context.Context, obj runti
 	}
 	webserverlog.Info("Validation for WebServer upon deletion", "name", webserver.GetName())
 
-	list := registry.allApps[webserver.Namespace]
-	for i, web := range list {
-		if web.WebServerName == webserver.Name {
-			// Remove the item by slicing around it
-			registry.allApps[webserver.Namespace] = append(list[:i], list[i+1:]...)
-			break
-		}
-	}
-
 	return nil, nil
 }
 
@@ -123,45 +162,25 @@ func printWebServer(webserver *webserversv1alpha1.WebServer) {
 	fmt.Printf("namespace: %s\n", webserver.Namespace)
 }
 
-func checkApplicationName(webserver *webserversv1alpha1.WebServer) (admission.Warnings, error) {
-	// Acquire a Write Lock immediately
-	registry.mu.Lock()
-	// Ensure the lock is released when the function exits
-	defer registry.mu.Unlock()
-
-	appName := webserver.Spec.ApplicationName
-	webserverName := webserver.Name
-	namespace := webserver.Namespace
-
-	list := registry.allApps[namespace]
-
-	for i := range list {
-		// Webserver is managing existing app - OK
-		if list[i].WebServerName == webserverName && list[i].AppName == appName {
-			return nil, nil
-		}
-
-		// Webserver updated app name - need to search whether app name is available in that namespace
-		if list[i].WebServerName == webserverName && list[i].AppName != appName {
-			for j := range list {
-				if list[j].AppName == appName {
-					// Application name already used - return error
-					return nil, errors.New("application name is already used")
-				}
-			}
-			// Application name can be used
-			list[i].AppName = appName
-			return nil, nil
-		}
+// checkApplicationName rejects a WebServer using the applicationName of another WebServer in the namespace,
+// the WebServers are read from the API (through the cache) so the check survives restarts and works with
+// several replicas of the webhook.
+func (v *WebServerCustomValidator) checkApplicationName(ctx context.Context, webserver *webserversv1alpha1.WebServer, path *field.Path) *field.Error {
+	webservers := &webserversv1alpha1.WebServerList{}
+	err := v.Client.List(ctx, webservers,
+		client.InNamespace(webserver.Namespace),
+		client.MatchingFields{applicationNameIndex: webserver.Spec.ApplicationName},
+	)
+	if err != nil {
+		webserverlog.Error(err, "Failed to list the WebServers", "namespace", webserver.Namespace)
+		return field.InternalError(path, fmt.Errorf("unable to check the application name: %w", err))
+	}
 
-		// Application name is used by other webserver
-		if list[i].WebServerName != webserverName && list[i].AppName == appName {
-			return nil, errors.New("application name is already used")
+	for _, other := range webservers.Items {
+		if other.Name != webserver.Name && other.Spec.ApplicationName == webserver.Spec.ApplicationName {
+			return field.Duplicate(path, webserver.Spec.ApplicationName+" (used by WebServer "+other.Name+")")
 		}
 	}
 
-	// Application name can be used
-	registry.allApps[namespace] = append(registry.allApps[namespace], WebApp{WebServerName: webserverName, AppName: appName})
-
-	return nil, nil
+	return nil
 }
@@ -20,6 +20,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
 	webserversorgv1alpha1 "github.com/web-servers/jws-operator/api/v1alpha1"
 	// TODO (user): Add any additional imports if needed
 )
@@ -29,13 +36,40 @@ var _ = Describe("WebServer Webhook", func() {
 		obj       *webserversorgv1alpha1.WebServer
 		oldObj    *webserversorgv1alpha1.WebServer
 		validator WebServerCustomValidator
+		defaulter WebServerCustomDefaulter
 	)
 
 	BeforeEach(func() {
 		obj = &webserversorgv1alpha1.WebServer{}
 		oldObj = &webserversorgv1alpha1.WebServer{}
-		validator = WebServerCustomValidator{}
+		existing := &webserversorgv1alpha1.WebServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+			Spec: webserversorgv1alpha1.WebServerSpec{
+				ApplicationName: "existing-app",
+				WebImage:        &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"},
+			},
+		}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "existing-secret", Namespace: "default"}}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "tomcat-config", Namespace: "default"},
+			Data: map[string]string{
+				"server.xml": `<Server port="-1"><Service name="Catalina"/></Server>`,
+				"users.xml":  `<role rolename="admin"/><user username="admin" password="admin" roles="admin"/>`,
+				"broken.xml": `<Server><Service></Server>`,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithIndex(&webserversorgv1alpha1.WebServer{}, applicationNameIndex, applicationNameIndexer).
+			WithObjects(existing, secret, configMap).
+			Build()
+		validator = WebServerCustomValidator{
+			Client:    fakeClient,
+			APIReader: fakeClient,
+		}
+		defaulter = WebServerCustomDefaulter{}
 		Expect(validator).NotTo(BeNil(), "Expected validator to be initialized")
+		Expect(defaulter).NotTo(BeNil(), "Expected defaulter to be initialized")
 		Expect(oldObj).NotTo(BeNil(), "Expected oldObj to be initialized")
 		Expect(obj).NotTo(BeNil(), "Expected obj to be initialized")
 		// TODO (user): Add any setup logic common to all tests
@@ -45,6 +79,57 @@ var _ = Describe("WebServer Webhook", func() {
 		// TODO (user): Add any teardown logic common to all tests
 	})
 
+	Context("When creating WebServer under Defaulting Webhook", func() {
+		It("Should apply the defaults the operator deploys with", func() {
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{
+				ApplicationImage: "quay.io/jfclere/tomcat10:latest",
+				WebApp:           &webserversorgv1alpha1.WebAppSpec{SourceRepositoryURL: "https://github.com/jfclere/demo-webapp.git"},
+			}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.WebImage.WebApp.Name).To(Equal(webserversorgv1alpha1.DefaultWebAppName))
+			Expect(obj.Spec.SecurityContext).To(Equal(webserversorgv1alpha1.DefaultSecurityContext()))
+			Expect(obj.Spec.Replicas).To(BeZero())
+		})
+
+		It("Should keep the values set by the user", func() {
+			runAsNonRoot := false
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{
+				ApplicationImage: "quay.io/jfclere/tomcat10:latest",
+				WebApp:           &webserversorgv1alpha1.WebAppSpec{Name: "demo.war"},
+			}
+			obj.Spec.SecurityContext = &corev1.SecurityContext{RunAsNonRoot: &runAsNonRoot}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.WebImage.WebApp.Name).To(Equal("demo.war"))
+			Expect(*obj.Spec.SecurityContext.RunAsNonRoot).To(BeFalse())
+			Expect(obj.Spec.SecurityContext.Capabilities).To(BeNil())
+		})
+
+		It("Should default the canary steps and timings of the rollout", func() {
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			obj.Spec.Rollout = &webserversorgv1alpha1.RolloutSpec{Strategy: webserversorgv1alpha1.RolloutStrategyCanary}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Rollout.Steps).To(Equal(webserversorgv1alpha1.DefaultRolloutSteps()))
+			Expect(*obj.Spec.Rollout.StepSeconds).To(Equal(webserversorgv1alpha1.DefaultRolloutStepSeconds))
+			Expect(*obj.Spec.Rollout.ProgressDeadlineSeconds).To(Equal(webserversorgv1alpha1.DefaultRolloutProgressDeadlineSeconds))
+
+			By("using the BlueGreen strategy")
+			obj.Spec.Rollout = &webserversorgv1alpha1.RolloutSpec{Strategy: webserversorgv1alpha1.RolloutStrategyBlueGreen}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Rollout.Steps).To(BeEmpty())
+		})
+
+		It("Should default the insecure edge termination policy of a TLS Route", func() {
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			obj.Spec.Route = &webserversorgv1alpha1.RouteSpec{Termination: webserversorgv1alpha1.RouteTerminationEdge}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Route.InsecureEdgeTerminationPolicy).To(Equal(webserversorgv1alpha1.InsecureEdgeTerminationPolicyNone))
+
+			obj.Spec.Route = &webserversorgv1alpha1.RouteSpec{Annotations: map[string]string{"haproxy.router.openshift.io/timeout": "2m"}}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Route.InsecureEdgeTerminationPolicy).To(BeEmpty())
+		})
+	})
+
 	Context("When creating or updating WebServer under Validating Webhook", func() {
 		// TODO (user): Add logic for validating webhooks
 		// Example:
@@ -66,6 +151,462 @@ var _ = Describe("WebServer Webhook", func() {
 		//     obj.SomeRequiredField = "updated_value"
 		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
 		// })
+
+		It("Should deny creation if both webImage and webImageStream are set", func() {
+			obj.Name = "both-images"
+			obj.Spec.ApplicationName = "both-images"
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			obj.Spec.WebImageStream = &webserversorgv1alpha1.WebImageStreamSpec{ImageStreamName: "jws-app"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny creation if neither webImage nor webImageStream is set", func() {
+			obj.Name = "no-image"
+			obj.Spec.ApplicationName = "no-image"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny webImageStream outside of OpenShift", func() {
+			obj.Name = "image-stream"
+			obj.Spec.ApplicationName = "image-stream"
+			obj.Spec.WebImageStream = &webserversorgv1alpha1.WebImageStreamSpec{ImageStreamName: "jws-app"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			validator.isOpenShift = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny creation if the application name is already used in the namespace", func() {
+			obj.Name = "duplicate"
+			obj.Namespace = "default"
+			obj.Spec.ApplicationName = "existing-app"
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("using the same application name in another namespace")
+			obj.Namespace = "other"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit the update of the WebServer owning the application name", func() {
+			oldObj.Name = "existing"
+			oldObj.Namespace = "default"
+			oldObj.Spec.ApplicationName = "existing-app"
+			oldObj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			obj = oldObj.DeepCopy()
+			obj.Spec.Replicas = 2
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an update removing the image", func() {
+			oldObj.Name = "update-image"
+			oldObj.Spec.ApplicationName = "update-image"
+			oldObj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			obj.Name = "update-image"
+			obj.Spec.ApplicationName = "update-image"
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should admit the updates of the metadata and of a WebServer being deleted", func() {
+			oldObj.Name = "update-metadata"
+			oldObj.Spec.ApplicationName = "update-metadata"
+			oldObj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+			oldObj.Spec.WebImageStream = &webserversorgv1alpha1.WebImageStreamSpec{ImageStreamName: "test", ImageStreamNamespace: "test"}
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = []string{"web.servers.org/finalizer"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.Replicas = 2
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+
+			now := metav1.Now()
+			obj.DeletionTimestamp = &now
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When validating the WebServer specification", func() {
+		BeforeEach(func() {
+			obj.Name = "spec-check"
+			obj.Namespace = "default"
+			obj.Spec.ApplicationName = "spec-check"
+			obj.Spec.WebImage = &webserversorgv1alpha1.WebImageSpec{ApplicationImage: "quay.io/jfclere/tomcat10:latest"}
+		})
+
+		It("Should check the routeHostname syntax", func() {
+			for _, hostname := range []string{"", "NONE", "tls", "tls:www.example.com", "www.example.com"} {
+				obj.Spec.TLSConfig.RouteHostname = hostname
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred(), hostname)
+			}
+			for _, hostname := range []string{"tls:", "tlsexample.com", "Not_A_Host"} {
+				obj.Spec.TLSConfig.RouteHostname = hostname
+				Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred(), hostname)
+			}
+		})
+
+		It("Should check the certificateVerification values", func() {
+			obj.Spec.TLSConfig.CertificateVerification = "optional"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			obj.Spec.TLSConfig.CertificateVerification = "always"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should require the builder fields of the webApp", func() {
+			obj.Spec.WebImage.WebApp = &webserversorgv1alpha1.WebAppSpec{
+				SourceRepositoryURL: "https://github.com/jfclere/demo-webapp",
+				WebAppWarImage:      "quay.io/jfclere/test",
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			obj.Spec.WebImage.WebApp.WebAppWarImagePushSecret = "secretfortests"
+			obj.Spec.WebImage.WebApp.Builder = &webserversorgv1alpha1.BuilderSpec{Image: "quay.io/jfclere/tomcat10-buildah"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should require the build strategy of Shipwright instead of the builder image", func() {
+			obj.Spec.WebImage.WebApp = &webserversorgv1alpha1.WebAppSpec{
+				SourceRepositoryURL:      "https://github.com/jfclere/demo-webapp",
+				WebAppWarImage:           "quay.io/jfclere/test",
+				WebAppWarImagePushSecret: "secretfortests",
+				Builder:                  &webserversorgv1alpha1.BuilderSpec{Strategy: webserversorgv1alpha1.BuildStrategyShipwright},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			obj.Spec.WebImage.WebApp.Builder.Shipwright = &webserversorgv1alpha1.ShipwrightSpec{StrategyName: "buildah-shipwright-managed-push"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.WebImage.WebApp.Builder.Strategy = webserversorgv1alpha1.BuildStrategyBuildah
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny duplicate volumes", func() {
+			obj.Spec.Volume = &webserversorgv1alpha1.VolumeSpec{Secrets: []string{"existing-secret", "existing-secret"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should warn about missing references", func() {
+			obj.Spec.Volume = &webserversorgv1alpha1.VolumeSpec{Secrets: []string{"existing-secret"}}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			obj.Spec.Volume.ConfigMaps = []string{"missing-configmap"}
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("Should check the update strategy against the workload", func() {
+			maxSurge := intstr.FromString("25%")
+			zero := intstr.FromInt32(0)
+			partition := int32(1)
+			obj.Spec.UpdateStrategy = &webserversorgv1alpha1.UpdateStrategy{
+				Type:     webserversorgv1alpha1.UpdateStrategyRollingUpdate,
+				MaxSurge: &maxSurge,
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("setting maxSurge and maxUnavailable to 0")
+			obj.Spec.UpdateStrategy.MaxSurge = &zero
+			obj.Spec.UpdateStrategy.MaxUnavailable = &zero
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("using maxSurge with the Recreate type")
+			obj.Spec.UpdateStrategy = &webserversorgv1alpha1.UpdateStrategy{
+				Type:     webserversorgv1alpha1.UpdateStrategyRecreate,
+				MaxSurge: &maxSurge,
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("partitioning a Deployment")
+			obj.Spec.UpdateStrategy = &webserversorgv1alpha1.UpdateStrategy{Partition: &partition}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("partitioning a StatefulSet")
+			obj.Spec.Volume = &webserversorgv1alpha1.VolumeSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaimSpec{{}}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny the automatic rollback of a rollout", func() {
+			rollbackDeadlineSeconds := int32(300)
+			obj.Spec.UpdateStrategy = &webserversorgv1alpha1.UpdateStrategy{RollbackDeadlineSeconds: &rollbackDeadlineSeconds}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.Rollout = &webserversorgv1alpha1.RolloutSpec{Strategy: webserversorgv1alpha1.RolloutStrategyBlueGreen}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should check the rollout against the image and the steps", func() {
+			obj.Spec.Rollout = &webserversorgv1alpha1.RolloutSpec{
+				Strategy: webserversorgv1alpha1.RolloutStrategyCanary,
+				Steps:    []int32{20, 50, 80},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("decreasing the traffic of a step")
+			obj.Spec.Rollout.Steps = []int32{50, 20}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("setting steps with the BlueGreen strategy")
+			obj.Spec.Rollout.Strategy = webserversorgv1alpha1.RolloutStrategyBlueGreen
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("rolling out an image built from a webApp")
+			obj.Spec.Rollout.Steps = nil
+			obj.Spec.WebImage.WebApp = &webserversorgv1alpha1.WebAppSpec{
+				SourceRepositoryURL:      "https://github.com/jfclere/demo-webapp",
+				WebAppWarImage:           "quay.io/jfclere/test",
+				WebAppWarImagePushSecret: "secretfortests",
+				Builder:                  &webserversorgv1alpha1.BuilderSpec{Image: "quay.io/jfclere/tomcat10-buildah"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("rolling out a StatefulSet")
+			obj.Spec.WebImage.WebApp = nil
+			obj.Spec.Volume = &webserversorgv1alpha1.VolumeSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaimSpec{{}}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should check the exposure fields against its type", func() {
+			obj.Spec.Exposure = &webserversorgv1alpha1.ExposureSpec{
+				Type: webserversorgv1alpha1.ExposureIngress,
+				Host: "app.example.com",
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("using a wildcard host")
+			obj.Spec.Exposure.Host = "*.example.com"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("using an invalid host")
+			obj.Spec.Exposure.Host = "app.example.com:8080"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("using a Gateway without its reference")
+			obj.Spec.Exposure.Host = ""
+			obj.Spec.Exposure.Type = webserversorgv1alpha1.ExposureGateway
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.Exposure.Gateway = &webserversorgv1alpha1.GatewayReference{Name: "gateway"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("setting the node port of a LoadBalancer")
+			obj.Spec.Exposure = &webserversorgv1alpha1.ExposureSpec{NodePort: 30080}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.Exposure.Type = webserversorgv1alpha1.ExposureNodePort
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("setting the host of a NodePort")
+			obj.Spec.Exposure.Host = "app.example.com"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should warn that the exposure is ignored on OpenShift", func() {
+			obj.Spec.Exposure = &webserversorgv1alpha1.ExposureSpec{Type: webserversorgv1alpha1.ExposureNone}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			validator.isOpenShift = true
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("Should check the Route termination against the TLS of tomcat", func() {
+			validator.isOpenShift = true
+			obj.Spec.Route = &webserversorgv1alpha1.RouteSpec{
+				Termination:                   webserversorgv1alpha1.RouteTerminationEdge,
+				InsecureEdgeTerminationPolicy: "Redirect",
+				Annotations:                   map[string]string{"haproxy.router.openshift.io/timeout": "2m"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("terminating at the edge a tomcat serving HTTPS")
+			obj.Spec.TLSConfig.RouteHostname = "tls"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.Route.Termination = webserversorgv1alpha1.RouteTerminationReencrypt
+			obj.Spec.Route.DestinationCASecret = "existing-secret"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("re-encrypting to a tomcat serving HTTP")
+			obj.Spec.TLSConfig.RouteHostname = ""
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("allowing HTTP with a passthrough Route")
+			obj.Spec.TLSConfig.RouteHostname = "tls"
+			obj.Spec.Route = &webserversorgv1alpha1.RouteSpec{InsecureEdgeTerminationPolicy: "Allow"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.Route.InsecureEdgeTerminationPolicy = "Redirect"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("setting a certificate without termination")
+			obj.Spec.Route.CertificateSecret = "existing-secret"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should check the cert-manager certificate and its reload", func() {
+			obj.Spec.TLSConfig = webserversorgv1alpha1.TLSConfig{
+				TLSSecret:     "issued-secret",
+				RouteHostname: "tls",
+				CertManager: &webserversorgv1alpha1.CertManagerSpec{
+					IssuerRef: webserversorgv1alpha1.IssuerReference{Name: "ca-issuer", Kind: "ClusterIssuer"},
+					DNSNames:  []string{"*.example.com", "app.example.com"},
+				},
+			}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty(), "the Secret is created by cert-manager")
+
+			By("using an invalid DNS name")
+			obj.Spec.TLSConfig.CertManager.DNSNames = []string{"app_example"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("reloading the certificates of a tomcat without HTTPS")
+			obj.Spec.TLSConfig.CertManager.DNSNames = nil
+			obj.Spec.TLSConfig.CertificateReload = webserversorgv1alpha1.CertificateReloadJolokia
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			obj.Spec.TLSConfig.RouteHostname = ""
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.TLSConfig.RouteHostname = "tls"
+
+			By("sending the Jolokia credentials to an agent which isn't verified")
+			obj.Spec.TLSConfig.Jolokia = &webserversorgv1alpha1.JolokiaSpec{CredentialsSecret: "jolokia"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.TLSConfig.Jolokia.CASecret = "jolokia-ca"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			obj.Spec.TLSConfig.Jolokia.Protocol = webserversorgv1alpha1.JolokiaProtocolHTTP
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.TLSConfig.Jolokia = nil
+
+			By("omitting the issuer")
+			obj.Spec.TLSConfig.CertManager.DNSNames = nil
+			obj.Spec.TLSConfig.CertManager.IssuerRef.Name = ""
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should check the configuration files of tomcat", func() {
+			obj.Spec.TomcatConfig = &webserversorgv1alpha1.TomcatConfigSpec{
+				ServerXml:      &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config"},
+				TomcatUsersXml: &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config", Key: "users.xml", Mode: webserversorgv1alpha1.TomcatConfigFragment},
+			}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("referencing a missing key and a file not well-formed")
+			obj.Spec.TomcatConfig.ServerXml.Key = "broken.xml"
+			obj.Spec.TomcatConfig.ContextXml = &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config"}
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(2))
+
+			By("building web.xml from fragments")
+			obj.Spec.TomcatConfig.ServerXml.Key = ""
+			obj.Spec.TomcatConfig.ContextXml = nil
+			obj.Spec.TomcatConfig.WebXml = &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config", Mode: webserversorgv1alpha1.TomcatConfigFragment}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+
+			By("using an invalid key")
+			obj.Spec.TomcatConfig.WebXml = &webserversorgv1alpha1.TomcatConfigFile{ConfigMap: "tomcat-config", Key: "conf/web.xml"}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should check the tuning of the connectors", func() {
+			obj.Spec.Connectors = &webserversorgv1alpha1.ConnectorsSpec{
+				HTTPS: &webserversorgv1alpha1.HTTPSConnectorSpec{Protocols: []string{"TLSv1.3"}},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.TLSConfig.RouteHostname = "tls"
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("referencing the Secret of the AJP connector")
+			obj.Spec.Connectors.AJP = &webserversorgv1alpha1.AJPConnectorSpec{SecretName: "existing-secret"}
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty(), "the keys of the Secret aren't read")
+			obj.Spec.Connectors.AJP.SecretName = "missing-secret"
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+
+			By("using an invalid name of Secret")
+			obj.Spec.Connectors.AJP.SecretName = "AJP_Secret"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should check the options of the session clustering", func() {
+			obj.Spec.SessionClustering = &webserversorgv1alpha1.SessionClusteringSpec{
+				MembershipProvider: webserversorgv1alpha1.MembershipProviderDNS,
+				Manager:            webserversorgv1alpha1.SessionManagerBackup,
+				StaticMembers:      []string{"tomcat-0.webserver-tomcat.other.svc", "10.0.0.1"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.UseSessionClustering = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("using an invalid static member")
+			obj.Spec.SessionClustering.StaticMembers = []string{"tomcat_0"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+
+			By("replicating the sessions saved by the Persistent manager")
+			obj.Spec.SessionClustering.Manager = webserversorgv1alpha1.SessionManagerPersistent
+			obj.Spec.SessionClustering.StaticMembers = nil
+			obj.Spec.SessionClustering.PersistentDirectory = "/sessions"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			obj.Spec.SessionClustering.MembershipProvider = ""
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			By("saving the sessions in a relative directory")
+			obj.Spec.SessionClustering.PersistentDirectory = "sessions"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should check the external session store", func() {
+			obj.Spec.SessionStore = &webserversorgv1alpha1.SessionStoreSpec{
+				Type:       webserversorgv1alpha1.SessionStoreJDBC,
+				SecretName: "existing-secret",
+				DriverName: "org.postgresql.Driver",
+			}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("enabling the session clustering too")
+			obj.Spec.SessionStore.Type = webserversorgv1alpha1.SessionStoreRedis
+			obj.Spec.SessionStore.DriverName = ""
+			obj.Spec.UseSessionClustering = true
+			warnings, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+
+			By("setting the JDBC driver of another store")
+			obj.Spec.SessionStore.DriverName = "org.postgresql.Driver"
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should deny changes of the volumeClaimTemplates", func() {
+			template := corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			}
+			oldObj = obj.DeepCopy()
+			oldObj.Spec.Volume = &webserversorgv1alpha1.VolumeSpec{VolumeClaimTemplates: []corev1.PersistentVolumeClaimSpec{template}}
+			obj = oldObj.DeepCopy()
+			obj.Spec.Volume.VolumeClaimTemplates[0].Resources.Requests[corev1.ResourceStorage] = resource.MustParse("2Gi")
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+
+			By("switching from a StatefulSet to a Deployment")
+			obj.Spec.Volume = nil
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
 	})
 
 })
@@ -31,8 +31,10 @@ import (
 
 	//	kbappsv1 "k8s.io/api/apps/v1"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("WebServerControllerTest", Ordered, func() {
@@ -113,28 +115,35 @@ var _ = Describe("WebServerControllerTest", Ordered, func() {
 	})
 })
 
-func builderPodLogCheck(podName string, expectedString string) {
+func builderPodLogCheck(jobName string, expectedString string) {
 	container := "war"
 
 	Eventually(func() bool {
-		podLogOptions := &corev1.PodLogOptions{
-			Container: container,
-		}
-
-		podLogs, err := clientset.CoreV1().Pods(namespace).GetLogs(podName, podLogOptions).Stream(ctx)
-
+		podList := &corev1.PodList{}
+		err := k8sClient.List(ctx, podList, client.InNamespace(namespace), client.MatchingLabels{batchv1.JobNameLabel: jobName})
 		if err != nil {
 			return false
 		}
 
-		var buffer bytes.Buffer
-		_, err = io.Copy(&buffer, podLogs)
+		for _, pod := range podList.Items {
+			podLogOptions := &corev1.PodLogOptions{
+				Container: container,
+			}
 
-		if err != nil {
-			return false
-		}
+			podLogs, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, podLogOptions).Stream(ctx)
+
+			if err != nil {
+				continue
+			}
 
-		Expect(podLogs.Close()).ShouldNot(HaveOccurred())
-		return bytes.Contains(bytes.ToLower(buffer.Bytes()), []byte(expectedString))
+			var buffer bytes.Buffer
+			_, err = io.Copy(&buffer, podLogs)
+			Expect(podLogs.Close()).ShouldNot(HaveOccurred())
+
+			if err == nil && bytes.Contains(bytes.ToLower(buffer.Bytes()), []byte(expectedString)) {
+				return true
+			}
+		}
+		return false
 	}, time.Minute*10, time.Second*1).Should(BeTrue(), "Build Pod: Expected log was not found.")
 }